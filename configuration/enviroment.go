@@ -6,18 +6,45 @@ import (
 )
 
 type EnvConfigModel struct {
-	DBHost              string `mapstructure:"POSTGRES_HOST"`
-	DBUserName          string `mapstructure:"POSTGRES_USER"`
-	DBUserPassword      string `mapstructure:"POSTGRES_PASSWORD"`
-	DBName              string `mapstructure:"POSTGRES_DB"`
-	DBPort              string `mapstructure:"POSTGRES_PORT"`
-	SSLMode             string `mapstructure:"SSL_MODE"`
-	Host                string `mapstructure:"SERVER_HOST"`
-	Port                string `mapstructure:"SERVER_PORT"`
-	STRATZToken         string `mapstructure:"STRATZ_TOKEN"`
-	SteamLoginUsernames string `mapstructure:"STEAM_LOGIN_USERNAMES"`
-	SteamLoginPasswords string `mapstructure:"STEAM_LOGIN_PASSWORDS"`
-	CorsAllowedOrigins  string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	DBHost                     string `mapstructure:"POSTGRES_HOST"`
+	DBUserName                 string `mapstructure:"POSTGRES_USER"`
+	DBUserPassword             string `mapstructure:"POSTGRES_PASSWORD"`
+	DBName                     string `mapstructure:"POSTGRES_DB"`
+	DBPort                     string `mapstructure:"POSTGRES_PORT"`
+	SSLMode                    string `mapstructure:"SSL_MODE"`
+	Host                       string `mapstructure:"SERVER_HOST"`
+	Port                       string `mapstructure:"SERVER_PORT"`
+	RequestTimeout             int    `mapstructure:"SERVER_REQUEST_TIMEOUT"`
+	ShutdownTimeout            int    `mapstructure:"SERVER_SHUTDOWN_TIMEOUT"`
+	STRATZToken                string `mapstructure:"STRATZ_TOKEN"`
+	SteamLoginUsernames        string `mapstructure:"STEAM_LOGIN_USERNAMES"`
+	SteamLoginPasswords        string `mapstructure:"STEAM_LOGIN_PASSWORDS"`
+	SteamSharedSecrets         string `mapstructure:"STEAM_SHARED_SECRETS"`
+	SteamAccountsFile          string `mapstructure:"STEAM_ACCOUNTS_FILE"`
+	SteamSessionKey            string `mapstructure:"STEAM_SESSION_KEY"`
+	SteamCircuitThreshold      int    `mapstructure:"STEAM_CIRCUIT_FAILURE_THRESHOLD"`
+	SteamCircuitOpenSeconds    int    `mapstructure:"STEAM_CIRCUIT_OPEN_SECONDS"`
+	SteamCircuitMaxOpenSeconds int    `mapstructure:"STEAM_CIRCUIT_MAX_OPEN_SECONDS"`
+	CorsAllowedOrigins         string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	ParserExtractors           string `mapstructure:"PARSER_EXTRACTORS"`
+	ReplayUploadLimitMB        int64  `mapstructure:"REPLAY_UPLOAD_LIMIT_MB"`
+	ReplaySources              string `mapstructure:"REPLAY_SOURCES"`
+	ReplayMirrorURL            string `mapstructure:"REPLAY_MIRROR_URL"`
+	ReplayLocalDir             string `mapstructure:"REPLAY_LOCAL_DIR"`
+	ReplayCacheDir             string `mapstructure:"REPLAY_CACHE_DIR"`
+	ReplayCacheMaxMB           int64  `mapstructure:"REPLAY_CACHE_MAX_MB"`
+	DownloadRetries            int    `mapstructure:"REPLAY_DOWNLOAD_RETRIES"`
+	DownloadStallTimeout       int    `mapstructure:"REPLAY_DOWNLOAD_STALL_TIMEOUT"`
+	DownloadBandwidthKB        int64  `mapstructure:"REPLAY_DOWNLOAD_BANDWIDTH_KB"`
+	DownloadClusterConcurrency int    `mapstructure:"REPLAY_DOWNLOAD_CLUSTER_CONCURRENCY"`
+	DecompressWorkers          int    `mapstructure:"REPLAY_DECOMPRESS_WORKERS"`
+	AdminToken                 string `mapstructure:"ADMIN_TOKEN"`
+	HealthMinFreeDiskMB        int64  `mapstructure:"HEALTH_MIN_FREE_DISK_MB"`
+	TracingExporter            string `mapstructure:"TRACING_EXPORTER"`
+	TracingOTLPEndpoint        string `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	LogFormat                  string `mapstructure:"LOG_FORMAT"`
+	LogLevel                   string `mapstructure:"LOG_LEVEL"`
+	LogLevels                  string `mapstructure:"LOG_LEVELS"`
 }
 
 var EnvConfig EnvConfigModel
@@ -34,8 +61,14 @@ func LoadConfig(filePath string) (err error) {
 	} else {
 		envs := []string{
 			"POSTGRES_HOST", "POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB", "POSTGRES_PORT", "SSL_MODE",
-			"STEAM_LOGIN_USERNAMES", "STEAM_LOGIN_PASSWORDS", "STRATZ_TOKEN",
-			"CORS_ALLOWED_ORIGINS", "SERVER_HOST", "SERVER_PORT",
+			"STEAM_LOGIN_USERNAMES", "STEAM_LOGIN_PASSWORDS", "STEAM_SHARED_SECRETS", "STEAM_ACCOUNTS_FILE", "STEAM_SESSION_KEY", "STRATZ_TOKEN",
+			"STEAM_CIRCUIT_FAILURE_THRESHOLD", "STEAM_CIRCUIT_OPEN_SECONDS", "STEAM_CIRCUIT_MAX_OPEN_SECONDS",
+			"CORS_ALLOWED_ORIGINS", "SERVER_HOST", "SERVER_PORT", "SERVER_REQUEST_TIMEOUT", "SERVER_SHUTDOWN_TIMEOUT",
+			"ADMIN_TOKEN", "HEALTH_MIN_FREE_DISK_MB", "TRACING_EXPORTER", "TRACING_OTLP_ENDPOINT",
+			"LOG_FORMAT", "LOG_LEVEL", "LOG_LEVELS", "PARSER_EXTRACTORS",
+			"REPLAY_UPLOAD_LIMIT_MB", "REPLAY_SOURCES", "REPLAY_MIRROR_URL", "REPLAY_LOCAL_DIR", "REPLAY_CACHE_DIR", "REPLAY_CACHE_MAX_MB",
+			"REPLAY_DOWNLOAD_RETRIES", "REPLAY_DOWNLOAD_STALL_TIMEOUT",
+			"REPLAY_DOWNLOAD_BANDWIDTH_KB", "REPLAY_DOWNLOAD_CLUSTER_CONCURRENCY", "REPLAY_DECOMPRESS_WORKERS",
 		}
 		for _, env := range envs {
 			if err = viper.BindEnv(env); err != nil {
@@ -0,0 +1,230 @@
+package configuration
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+const steamAccountEnvPrefix = "STEAM_ACCOUNT_"
+
+// SteamAccount is an account of the Steam client pool
+type SteamAccount struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// SharedSecret of the mobile authenticator, base64 encoded
+	SharedSecret string `mapstructure:"shared_secret"`
+	// Priority orders the accounts requests are sent to, higher first
+	Priority int `mapstructure:"priority"`
+	// Enabled is true if unset
+	Enabled *bool `mapstructure:"enabled"`
+}
+
+func (a SteamAccount) IsEnabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+// LogValue keeps the password and shared secret out of the logs
+func (a SteamAccount) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", a.Username), slog.Int("priority", a.Priority), slog.Bool("enabled", a.IsEnabled()))
+}
+
+type steamAccountsFile struct {
+	Accounts []SteamAccount `mapstructure:"accounts"`
+}
+
+// LoadSteamAccounts reads the accounts from STEAM_ACCOUNTS_FILE if it is set, from indexed
+// STEAM_ACCOUNT_<n>_USERNAME, _PASSWORD, _SHARED_SECRET, _PRIORITY and _ENABLED variables otherwise,
+// and from the space separated STEAM_LOGIN_USERNAMES and STEAM_LOGIN_PASSWORDS lists if there are none.
+// The accounts are validated.
+func LoadSteamAccounts(c *EnvConfigModel) ([]SteamAccount, error) {
+	var accounts []SteamAccount
+	var err error
+	switch {
+	case c.SteamAccountsFile != "":
+		accounts, err = readSteamAccountsFile(viper.New(), c.SteamAccountsFile)
+	case len(steamAccountEnv()) > 0:
+		accounts, err = steamAccountsFromEnv(steamAccountEnv())
+	default:
+		accounts, err = steamAccountsFromLists(c.SteamLoginUsernames, c.SteamLoginPasswords, c.SteamSharedSecrets)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return accounts, ValidateSteamAccounts(accounts)
+}
+
+// WatchSteamAccounts calls onChange with the accounts of the file whenever it changes.
+// Changes that cannot be read or are invalid are logged and skipped, keeping the accounts as they were.
+func WatchSteamAccounts(path string, logger *slog.Logger, onChange func([]SteamAccount)) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		logger.Error("Cannot watch Steam accounts file", "path", path, "error", err)
+		return
+	}
+	// viper has read the changed file when it calls back
+	v.OnConfigChange(func(event fsnotify.Event) {
+		accounts, err := unmarshalSteamAccounts(v, path)
+		if err == nil {
+			err = ValidateSteamAccounts(accounts)
+		}
+		if err != nil {
+			logger.Error("Steam accounts were not reloaded", "path", path, "error", err)
+			return
+		}
+		logger.Info("Steam accounts reloaded", "path", path)
+		onChange(accounts)
+	})
+	v.WatchConfig()
+}
+
+// ValidateSteamAccounts reports every invalid account, without their credentials
+func ValidateSteamAccounts(accounts []SteamAccount) error {
+	var errs []error
+	usernames := make(map[string]bool)
+	enabled := 0
+	for i, account := range accounts {
+		name := fmt.Sprintf("steam account %d", i+1)
+		if account.Username == "" {
+			errs = append(errs, fmt.Errorf("%s: username is required", name))
+		} else {
+			name = fmt.Sprintf("steam account %d (%s)", i+1, account.Username)
+			// Steam logins are case insensitive
+			if usernames[strings.ToLower(account.Username)] {
+				errs = append(errs, fmt.Errorf("%s: username is configured more than once", name))
+			}
+			usernames[strings.ToLower(account.Username)] = true
+		}
+		if account.Password == "" {
+			errs = append(errs, fmt.Errorf("%s: password is required", name))
+		}
+		if account.SharedSecret != "" {
+			if _, err := base64.StdEncoding.DecodeString(account.SharedSecret); err != nil {
+				errs = append(errs, fmt.Errorf("%s: shared secret is not base64", name))
+			}
+		}
+		if account.IsEnabled() {
+			enabled++
+		}
+	}
+	if enabled == 0 {
+		errs = append(errs, errors.New("no enabled Steam account is configured"))
+	}
+	return errors.Join(errs...)
+}
+
+// readSteamAccountsFile reads a YAML or JSON file with a list of accounts, the format is told by the extension
+func readSteamAccountsFile(v *viper.Viper, path string) ([]SteamAccount, error) {
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read Steam accounts file: %w", err)
+	}
+	return unmarshalSteamAccounts(v, path)
+}
+
+func unmarshalSteamAccounts(v *viper.Viper, path string) ([]SteamAccount, error) {
+	var file steamAccountsFile
+	if err := v.Unmarshal(&file); err != nil {
+		return nil, fmt.Errorf("read Steam accounts file %s: %w", path, err)
+	}
+	return file.Accounts, nil
+}
+
+// steamAccountEnv collects the indexed account variables of the environment and the .env file by index
+func steamAccountEnv() map[int]map[string]string {
+	values := make(map[string]string)
+	for _, env := range os.Environ() {
+		if key, value, ok := strings.Cut(env, "="); ok {
+			values[key] = value
+		}
+	}
+	// Keys of the .env file are lower case in viper
+	for _, key := range viper.AllKeys() {
+		values[strings.ToUpper(key)] = viper.GetString(key)
+	}
+
+	accounts := make(map[int]map[string]string)
+	for key, value := range values {
+		indexAndField, ok := strings.CutPrefix(key, steamAccountEnvPrefix)
+		if !ok {
+			continue
+		}
+		index, field, ok := strings.Cut(indexAndField, "_")
+		n, err := strconv.Atoi(index)
+		if !ok || err != nil {
+			continue
+		}
+		if accounts[n] == nil {
+			accounts[n] = make(map[string]string)
+		}
+		accounts[n][field] = value
+	}
+	return accounts
+}
+
+func steamAccountsFromEnv(env map[int]map[string]string) ([]SteamAccount, error) {
+	indexes := make([]int, 0, len(env))
+	for index := range env {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var errs []error
+	accounts := make([]SteamAccount, 0, len(indexes))
+	for _, index := range indexes {
+		fields := env[index]
+		account := SteamAccount{
+			Username:     fields["USERNAME"],
+			Password:     fields["PASSWORD"],
+			SharedSecret: fields["SHARED_SECRET"],
+		}
+		if priority := fields["PRIORITY"]; priority != "" {
+			var err error
+			if account.Priority, err = strconv.Atoi(priority); err != nil {
+				errs = append(errs, fmt.Errorf("%s%d_PRIORITY is not an integer", steamAccountEnvPrefix, index))
+			}
+		}
+		if enabled := fields["ENABLED"]; enabled != "" {
+			value, err := strconv.ParseBool(enabled)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%d_ENABLED is not a boolean", steamAccountEnvPrefix, index))
+			}
+			account.Enabled = &value
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, errors.Join(errs...)
+}
+
+// steamAccountsFromLists reads space separated usernames, passwords and shared secrets,
+// "-" is an account without a shared secret
+func steamAccountsFromLists(usernames, passwords, sharedSecrets string) ([]SteamAccount, error) {
+	u := strings.Fields(usernames)
+	p := strings.Fields(passwords)
+	secrets := strings.Fields(sharedSecrets)
+	if len(p) != len(u) {
+		return nil, fmt.Errorf("STEAM_LOGIN_USERNAMES has %d accounts but STEAM_LOGIN_PASSWORDS has %d passwords, "+
+			"configure accounts in STEAM_ACCOUNTS_FILE if a password contains a space", len(u), len(p))
+	}
+	if len(secrets) > len(u) {
+		return nil, fmt.Errorf("STEAM_SHARED_SECRETS has %d secrets for %d accounts", len(secrets), len(u))
+	}
+
+	accounts := make([]SteamAccount, len(u))
+	for i := range u {
+		accounts[i] = SteamAccount{Username: u[i], Password: p[i]}
+		if i < len(secrets) && secrets[i] != "-" {
+			accounts[i].SharedSecret = secrets[i]
+		}
+	}
+	return accounts, nil
+}
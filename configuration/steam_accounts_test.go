@@ -0,0 +1,156 @@
+package configuration
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSteamAccountsFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("cannot write accounts file: %v", err)
+	}
+	return path
+}
+
+func TestLoadSteamAccountsFromFile(t *testing.T) {
+	files := map[string]string{
+		"accounts.yaml": `
+accounts:
+  - username: first
+    password: "pass word"
+    shared_secret: MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=
+    priority: 10
+  - username: second
+    password: secret
+    enabled: false
+`,
+		"accounts.json": `{"accounts": [
+  {"username": "first", "password": "pass word", "shared_secret": "MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=", "priority": 10},
+  {"username": "second", "password": "secret", "enabled": false}
+]}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			accounts, err := LoadSteamAccounts(&EnvConfigModel{SteamAccountsFile: writeSteamAccountsFile(t, name, content)})
+			if err != nil {
+				t.Fatalf("LoadSteamAccounts returned error: %v", err)
+			}
+			if len(accounts) != 2 {
+				t.Fatalf("expected 2 accounts, got %d", len(accounts))
+			}
+			first, second := accounts[0], accounts[1]
+			if first.Username != "first" || first.Password != "pass word" || first.Priority != 10 ||
+				first.SharedSecret == "" || !first.IsEnabled() {
+				t.Fatalf("unexpected first account %+v", first)
+			}
+			if second.Username != "second" || second.IsEnabled() {
+				t.Fatalf("expected the second account to be disabled, got %+v", second)
+			}
+		})
+	}
+}
+
+func TestLoadSteamAccountsFromIndexedEnv(t *testing.T) {
+	t.Setenv("STEAM_ACCOUNT_2_USERNAME", "second")
+	t.Setenv("STEAM_ACCOUNT_2_PASSWORD", "secret")
+	t.Setenv("STEAM_ACCOUNT_2_PRIORITY", "5")
+	t.Setenv("STEAM_ACCOUNT_1_USERNAME", "first")
+	t.Setenv("STEAM_ACCOUNT_1_PASSWORD", "pass word")
+	t.Setenv("STEAM_ACCOUNT_1_ENABLED", "false")
+
+	accounts, err := LoadSteamAccounts(&EnvConfigModel{SteamLoginUsernames: "ignored", SteamLoginPasswords: "ignored"})
+	if err != nil {
+		t.Fatalf("LoadSteamAccounts returned error: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].Username != "first" || accounts[0].Password != "pass word" || accounts[0].IsEnabled() ||
+		accounts[1].Username != "second" || accounts[1].Priority != 5 || !accounts[1].IsEnabled() {
+		t.Fatalf("unexpected accounts %+v", accounts)
+	}
+
+	t.Setenv("STEAM_ACCOUNT_2_PRIORITY", "high")
+	if _, err := LoadSteamAccounts(&EnvConfigModel{}); err == nil || !strings.Contains(err.Error(), "STEAM_ACCOUNT_2_PRIORITY") {
+		t.Fatalf("expected an invalid priority error, got %v", err)
+	}
+}
+
+func TestLoadSteamAccountsFromLists(t *testing.T) {
+	accounts, err := LoadSteamAccounts(&EnvConfigModel{
+		SteamLoginUsernames: "first second",
+		SteamLoginPasswords: "one two",
+		SteamSharedSecrets:  "- MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=",
+	})
+	if err != nil {
+		t.Fatalf("LoadSteamAccounts returned error: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].SharedSecret != "" || accounts[1].SharedSecret == "" || accounts[1].Password != "two" {
+		t.Fatalf("unexpected accounts %+v", accounts)
+	}
+
+	if _, err := LoadSteamAccounts(&EnvConfigModel{SteamLoginUsernames: "first second", SteamLoginPasswords: "pass word with spaces"}); err == nil {
+		t.Fatal("expected mismatched usernames and passwords to fail")
+	}
+}
+
+func TestValidateSteamAccounts(t *testing.T) {
+	disabled := false
+	err := ValidateSteamAccounts([]SteamAccount{
+		{Password: "hunter2"},
+		{Username: "glyph", Password: "hunter2", SharedSecret: "not base64!"},
+		{Username: "GLYPH", Enabled: &disabled},
+	})
+	if err == nil {
+		t.Fatal("expected invalid accounts to fail")
+	}
+
+	for _, want := range []string{
+		"steam account 1: username is required",
+		"steam account 2 (glyph): shared secret is not base64",
+		"steam account 3 (GLYPH): username is configured more than once",
+		"steam account 3 (GLYPH): password is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q in:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Error("expected errors without passwords")
+	}
+
+	if err := ValidateSteamAccounts([]SteamAccount{{Username: "glyph", Password: "hunter2", Enabled: &disabled}}); err == nil ||
+		!strings.Contains(err.Error(), "no enabled Steam account") {
+		t.Fatalf("expected an error without enabled accounts, got %v", err)
+	}
+}
+
+func TestWatchSteamAccountsReloadsValidChanges(t *testing.T) {
+	path := writeSteamAccountsFile(t, "accounts.yaml", "accounts:\n  - {username: first, password: one}\n")
+	reloaded := make(chan []SteamAccount, 10)
+	WatchSteamAccounts(path, slog.Default(), func(accounts []SteamAccount) {
+		reloaded <- accounts
+	})
+
+	// An invalid change is skipped, the next valid one is reloaded
+	if err := os.WriteFile(path, []byte("accounts:\n  - {username: first}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("accounts:\n  - {username: second, password: two}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case accounts := <-reloaded:
+		if len(accounts) != 1 || accounts[0].Username != "second" {
+			t.Fatalf("expected the second account, got %+v", accounts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("accounts were not reloaded")
+	}
+}
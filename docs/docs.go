@@ -15,6 +15,141 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/admin/steam/accounts": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Get the state, load and last error of every Steam account in the pool",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get Steam accounts",
+                "responses": {
+                    "200": {
+                        "description": "Steam accounts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.SteamAccountHealth"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/steam/accounts/{username}/guard-code": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Submit the Steam Guard code sent to the email of an account waiting for it to log in",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Submit Steam Guard code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Steam account username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Steam Guard code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.SteamGuardCode"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Code submitted",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing code",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown account",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "409": {
+                        "description": "Account is not waiting for a code",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/steam/circuit": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Get the state of the circuit breaker of the Dota GC path and how often it changed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get Steam circuit breaker",
+                "responses": {
+                    "200": {
+                        "description": "Circuit breaker",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.SteamCircuit"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
         "/api/glyph/{matchID}": {
             "post": {
                 "description": "Get glyphs using match id",
@@ -35,6 +170,12 @@ const docTemplate = `{
                         "name": "matchID",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated extractors to run besides glyphs (draft, scoreboard, objectives, wards), server defaults if empty",
+                        "name": "extractors",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -67,47 +208,901 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/dtos.MessageResponseType"
                         }
+                    },
+                    "404": {
+                        "description": "No glyphs were used in the match, or its replay is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "503": {
+                        "description": "Dota servers are unavailable, retry after the Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "504": {
+                        "description": "Request timed out",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dtos.MessageResponseType": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
+        },
+        "/api/matches/{matchID}": {
+            "get": {
+                "description": "Get outcome, scoreboard and draft of a parsed match using match id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "match"
+                ],
+                "summary": "Get match",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Match ID",
+                        "name": "matchID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Match from database",
+                        "schema": {
+                            "$ref": "#/definitions/models.Match"
+                        }
+                    },
+                    "400": {
+                        "description": "Match ID is not an integer",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "404": {
+                        "description": "Match is not parsed yet",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
                 }
             }
         },
-        "models.Glyph": {
-            "type": "object",
-            "properties": {
-                "heroID": {
-                    "description": "ID of hero (https://liquipedia.net/dota2/MediaWiki:Dota2webapi-heroes.json)",
-                    "type": "integer"
-                },
-                "matchID": {
-                    "type": "integer"
-                },
-                "minute": {
-                    "type": "integer"
-                },
-                "second": {
-                    "type": "integer"
-                },
-                "team": {
-                    "description": "Radiant team is 2 and dire team is 3",
-                    "type": "integer"
-                },
-                "userSteamID": {
-                    "type": "string"
-                },
-                "username": {
-                    "type": "string"
+        "/api/matches/{matchID}/players": {
+            "get": {
+                "description": "Get lineup and draft of a parsed match using match id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "match"
+                ],
+                "summary": "Get match players",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Match ID",
+                        "name": "matchID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Players and draft from database",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MatchPlayers"
+                        }
+                    },
+                    "400": {
+                        "description": "Match ID is not an integer",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "404": {
+                        "description": "Match is not parsed yet",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/matches/{matchID}/timeline": {
+            "get": {
+                "description": "Get glyphs together with Roshan, Aegis and Tormentor events of a parsed match ordered by game time",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "match"
+                ],
+                "summary": "Get match timeline",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Match ID",
+                        "name": "matchID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Timeline from database",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.TimelineEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Match ID is not an integer",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "404": {
+                        "description": "Match is not parsed yet",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
                 }
             }
+        },
+        "/api/matches/{matchID}/wards": {
+            "get": {
+                "description": "Get observer and sentry wards with placer, killer and lifetime of a parsed match",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "match"
+                ],
+                "summary": "Get match wards",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Match ID",
+                        "name": "matchID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wards from database",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Ward"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Match ID is not an integer",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "404": {
+                        "description": "Match is not parsed yet",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/players/{steamID}/wards/heatmap": {
+            "get": {
+                "description": "Get number of wards placed by a player in each cell of the map across all parsed matches",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "player"
+                ],
+                "summary": "Get ward heatmap",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Steam ID of the player",
+                        "name": "steamID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ward type (observer or sentry), all wards if empty",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Cell size in world units (64-4096), 512 by default",
+                        "name": "cellSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Heatmap from database",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.WardHeatmap"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/replays": {
+            "post": {
+                "description": "Parse glyphs from an uploaded .dem or .dem.bz2 replay, for matches Valve no longer serves.\nThe body is streamed to the parser, so the matchID field must come before the file field.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Upload replay",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Match ID, must be the one recorded in the replay",
+                        "name": "matchID",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Replay (.dem or .dem.bz2)",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated extractors to run besides glyphs (draft, scoreboard, objectives, wards), server defaults if empty",
+                        "name": "extractors",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Glyphs from database",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Glyph"
+                            }
+                        }
+                    },
+                    "201": {
+                        "description": "Glyphs parsed and save to database",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Glyph"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Match is already being processed",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid upload or replay of another match",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "404": {
+                        "description": "No glyphs were used in the match",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "413": {
+                        "description": "Replay is too large",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/replays/downloads": {
+            "get": {
+                "description": "Get current downloads, downloaded bytes and throughput over the last 10 seconds per replay cluster and mirror",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Get replay downloads",
+                "responses": {
+                    "200": {
+                        "description": "Downloads per cluster",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.DownloadStats"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/status": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Get the state, details and last error of every dependency, with the admin token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Get status",
+                "responses": {
+                    "200": {
+                        "description": "Dependencies",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.HealthStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Answers as long as the process serves requests, whatever the state of its dependencies",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Process is alive",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Ready when the database is reachable, a Steam account has a Dota GC session, demos can be written\nand enough disk space is free. Not ready once the server is shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Ready",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependencies that are not ready",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MessageResponseType"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dtos.DependencyStatus": {
+            "type": "object",
+            "properties": {
+                "checkedAt": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "description": "Most recent failure, kept once the dependency recovered",
+                    "type": "string"
+                },
+                "lastErrorAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "\"database\", \"steam\", \"demos\" or \"disk\"",
+                    "type": "string"
+                },
+                "ready": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dtos.DownloadStats": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "bytes": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "bytesPerSecond": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.HealthStatus": {
+            "type": "object",
+            "properties": {
+                "dependencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.DependencyStatus"
+                    }
+                },
+                "ready": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dtos.HeatmapCell": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "x": {
+                    "description": "World coordinates of the lower left corner of the cell",
+                    "type": "number",
+                    "format": "float32"
+                },
+                "y": {
+                    "type": "number",
+                    "format": "float32"
+                }
+            }
+        },
+        "dtos.MatchPlayers": {
+            "type": "object",
+            "properties": {
+                "draft": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DraftPick"
+                    }
+                },
+                "gameMode": {
+                    "type": "integer",
+                    "format": "int32"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "players": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MatchPlayer"
+                    }
+                }
+            }
+        },
+        "dtos.MessageResponseType": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.SteamAccountHealth": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "description": "Consecutive failures since the account was last ready",
+                    "type": "integer"
+                },
+                "inFlight": {
+                    "type": "integer"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "requests": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "retryAt": {
+                    "description": "When a quarantined account reconnects",
+                    "type": "string"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "\"connecting\", \"awaiting_guard_code\", \"ready\" or \"quarantined\"",
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.SteamCircuit": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "description": "Consecutive failed lookups while closed",
+                    "type": "integer"
+                },
+                "rejected": {
+                    "description": "Lookups failed fast while open",
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "retryAt": {
+                    "description": "When an open circuit lets a probe through",
+                    "type": "string"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "\"closed\", \"open\" or \"half_open\"",
+                    "type": "string"
+                },
+                "transitions": {
+                    "description": "Times the circuit entered each state",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                }
+            }
+        },
+        "dtos.SteamGuardCode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.TimelineEvent": {
+            "type": "object",
+            "properties": {
+                "heroID": {
+                    "type": "integer",
+                    "format": "int32"
+                },
+                "minute": {
+                    "type": "integer",
+                    "format": "int32"
+                },
+                "second": {
+                    "type": "integer",
+                    "format": "int32"
+                },
+                "team": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "type": {
+                    "description": "\"glyph\" or one of the objective types",
+                    "type": "string"
+                },
+                "userSteamID": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.WardHeatmap": {
+            "type": "object",
+            "properties": {
+                "cellSize": {
+                    "type": "integer"
+                },
+                "cells": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.HeatmapCell"
+                    }
+                },
+                "matches": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "userSteamID": {
+                    "type": "string"
+                },
+                "wards": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.DraftPick": {
+            "type": "object",
+            "properties": {
+                "heroID": {
+                    "type": "integer"
+                },
+                "isPick": {
+                    "type": "boolean"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "order": {
+                    "description": "Order in which the hero was picked or banned, starting from 0",
+                    "type": "integer"
+                },
+                "team": {
+                    "description": "Radiant team is 2 and dire team is 3, 0 if unknown",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Glyph": {
+            "type": "object",
+            "properties": {
+                "heroID": {
+                    "description": "ID of hero (https://liquipedia.net/dota2/MediaWiki:Dota2webapi-heroes.json)",
+                    "type": "integer"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "minute": {
+                    "type": "integer"
+                },
+                "second": {
+                    "type": "integer"
+                },
+                "team": {
+                    "description": "Radiant team is 2 and dire team is 3",
+                    "type": "integer"
+                },
+                "userSteamID": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Match": {
+            "type": "object",
+            "properties": {
+                "draft": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DraftPick"
+                    }
+                },
+                "duration": {
+                    "description": "Game time in seconds from the horn to the end of the game",
+                    "type": "integer"
+                },
+                "gameMode": {
+                    "description": "Captains Mode is 2 (https://github.com/odota/dotaconstants/blob/master/json/game_mode.json)",
+                    "type": "integer"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "objectives": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Objective"
+                    }
+                },
+                "players": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MatchPlayer"
+                    }
+                },
+                "wards": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Ward"
+                    }
+                },
+                "winner": {
+                    "description": "Radiant team is 2 and dire team is 3, 0 if unknown",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.MatchPlayer": {
+            "type": "object",
+            "properties": {
+                "assists": {
+                    "type": "integer"
+                },
+                "deaths": {
+                    "type": "integer"
+                },
+                "heroID": {
+                    "description": "ID of hero (https://liquipedia.net/dota2/MediaWiki:Dota2webapi-heroes.json)",
+                    "type": "integer"
+                },
+                "kills": {
+                    "type": "integer"
+                },
+                "lastHits": {
+                    "type": "integer"
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "netWorth": {
+                    "type": "integer"
+                },
+                "playerSlot": {
+                    "description": "Index of player in CDOTA_PlayerResource (0-9)",
+                    "type": "integer"
+                },
+                "team": {
+                    "description": "Radiant team is 2 and dire team is 3",
+                    "type": "integer"
+                },
+                "userSteamID": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Objective": {
+            "type": "object",
+            "properties": {
+                "heroID": {
+                    "type": "integer"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "minute": {
+                    "type": "integer"
+                },
+                "second": {
+                    "type": "integer"
+                },
+                "team": {
+                    "description": "Radiant team is 2 and dire team is 3, 0 if unknown",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "One of roshan_kill, aegis_pickup, aegis_stolen, aegis_denied, aegis_expired, tormentor_kill",
+                    "type": "string"
+                },
+                "userSteamID": {
+                    "type": "string"
+                },
+                "username": {
+                    "description": "Empty if no player is involved (e.g. Roshan killed by creeps)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Ward": {
+            "type": "object",
+            "properties": {
+                "heroID": {
+                    "type": "integer"
+                },
+                "killerSteamID": {
+                    "type": "string"
+                },
+                "killerTeam": {
+                    "description": "0 if the ward was not killed",
+                    "type": "integer"
+                },
+                "killerUsername": {
+                    "description": "Empty if the ward expired or the killer is not a hero",
+                    "type": "string"
+                },
+                "lifetime": {
+                    "description": "Seconds the ward stayed on the map",
+                    "type": "integer"
+                },
+                "matchID": {
+                    "type": "integer"
+                },
+                "minute": {
+                    "description": "Game time when the ward was placed, negative before the horn (-0:45 is 0 and -45)",
+                    "type": "integer"
+                },
+                "second": {
+                    "type": "integer"
+                },
+                "team": {
+                    "description": "Radiant team is 2 and dire team is 3",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "observer or sentry",
+                    "type": "string"
+                },
+                "userSteamID": {
+                    "type": "string"
+                },
+                "username": {
+                    "description": "Player who placed the ward",
+                    "type": "string"
+                },
+                "x": {
+                    "description": "World coordinates, map center is 0,0",
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "\"Bearer\" followed by the ADMIN_TOKEN",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -1,44 +1,173 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"go-glyph/configuration"
 	"go-glyph/internal/api/controllers"
 	"go-glyph/internal/api/middleware"
 	"go-glyph/internal/api/routers"
+	"go-glyph/internal/core/logging"
+	"go-glyph/internal/core/metrics"
 	"go-glyph/internal/core/services"
+	"go-glyph/internal/core/tracing"
 	"go-glyph/internal/data/database"
 	"go-glyph/internal/data/repository"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultReplayUploadLimitMB = 512
+	defaultRequestTimeout      = 5 * time.Minute
+	defaultShutdownTimeout     = 30 * time.Second
+	// shutdownCancelGrace is how long requests canceled at the end of the shutdown timeout are given to clean up
+	shutdownCancelGrace = 5 * time.Second
 )
 
 func Run(c *configuration.EnvConfigModel) {
-	db := database.ConnectDB(c)
+	loggers, err := logging.New(os.Stderr, logging.Config{Format: c.LogFormat, Level: c.LogLevel, Levels: c.LogLevels})
+	if err != nil {
+		log.Fatal("Invalid logging:\n", err.Error())
+	}
+	// Lines of the log package and of libraries logging to slog.Default() are logged by the app subsystem
+	logger := loggers.Logger(logging.SubsystemApp)
+	slog.SetDefault(logger)
+	steamLogger := loggers.Logger(logging.SubsystemSteam)
+	replayLogger := loggers.Logger(logging.SubsystemReplay)
+	httpLogger := loggers.Logger(logging.SubsystemHTTP)
+
+	shutdownTracing, err := tracing.Setup(c.TracingExporter, c.TracingOTLPEndpoint)
+	if err != nil {
+		log.Fatal("Invalid tracing:\n", err.Error())
+	}
+
+	db := database.ConnectDB(c, loggers.Logger(logging.SubsystemDatabase))
 
 	glyphRepository := repository.NewGlyphRepository(db)
+	matchRepository := repository.NewMatchRepository(db)
 
-	glyphService := services.NewGlyphService(glyphRepository)
+	glyphService := services.NewGlyphService(glyphRepository, matchRepository)
+	matchService := services.NewMatchService(matchRepository, glyphRepository)
+	parseService := services.NewParseService(matchService)
 	// stratzService := services.NewStratzService(c.STRATZToken)
 	// opendotaService := services.NewOpendotaService()
-	goSteamService := services.NewGoSteamService(c.SteamLoginUsernames, c.SteamLoginPasswords)
-	valveService := services.NewValveService()
-	mantaService := services.NewMantaService()
+	steamSessionRepository := repository.NewSteamSessionRepository(db)
+	steamSessionService := services.NewSteamSessionService(steamSessionRepository, c.SteamSessionKey, steamLogger)
+	if !steamSessionService.Enabled() {
+		steamLogger.Warn("STEAM_SESSION_KEY is not set, Steam accounts log in with their password on every start")
+	}
+	steamAccounts, err := configuration.LoadSteamAccounts(c)
+	if err != nil {
+		log.Fatal("Invalid Steam accounts:\n", err.Error())
+	}
+	goSteamService := services.NewGoSteamService(steamAccountConfigs(steamAccounts), steamSessionService, services.CircuitBreakerConfig{
+		FailureThreshold: c.SteamCircuitThreshold,
+		BaseDelay:        time.Duration(c.SteamCircuitOpenSeconds) * time.Second,
+		MaxDelay:         time.Duration(c.SteamCircuitMaxOpenSeconds) * time.Second,
+	}, steamLogger)
+	if c.SteamAccountsFile != "" {
+		configuration.WatchSteamAccounts(c.SteamAccountsFile, steamLogger, func(accounts []configuration.SteamAccount) {
+			goSteamService.SetAccounts(steamAccountConfigs(accounts))
+		})
+	}
+	downloader := services.NewDownloader(services.DownloadConfig{
+		Retries:           c.DownloadRetries,
+		StallTimeout:      time.Duration(c.DownloadStallTimeout) * time.Second,
+		BandwidthLimit:    c.DownloadBandwidthKB << 10,
+		TargetConcurrency: c.DownloadClusterConcurrency,
+		Logger:            replayLogger,
+	})
+	replaySources, replayCache, err := services.NewReplaySources(services.ReplaySourcesConfig{
+		Order:         splitList(c.ReplaySources),
+		MirrorURL:     c.ReplayMirrorURL,
+		LocalDir:      c.ReplayLocalDir,
+		CacheDir:      c.ReplayCacheDir,
+		CacheMaxBytes: c.ReplayCacheMaxMB << 20,
+		Downloader:    downloader,
+	})
+	if err != nil {
+		log.Fatal("Invalid replay sources:\n", err.Error())
+	}
+	valveService := services.NewValveService(replaySources, replayCache, downloader, c.DecompressWorkers, replayLogger)
+	mantaService := services.NewMantaService(splitList(c.ParserExtractors))
+
+	glyphController := controllers.NewGlyphController(glyphService, parseService, goSteamService, valveService, mantaService, httpLogger)
+	matchController := controllers.NewMatchController(matchService)
+	playerController := controllers.NewPlayerController(matchService)
+
+	replayUploadLimitMB := c.ReplayUploadLimitMB
+	if replayUploadLimitMB <= 0 {
+		replayUploadLimitMB = defaultReplayUploadLimitMB
+	}
+	replayController := controllers.NewReplayController(glyphService, parseService, mantaService, valveService, replayUploadLimitMB<<20, httpLogger)
+	adminController := controllers.NewAdminController(goSteamService)
 
-	glyphController := controllers.NewGlyphController(glyphService, goSteamService, valveService, mantaService)
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("Invalid database:\n", err.Error())
+	}
+	healthService := services.NewHealthService(sqlDB, goSteamService, uint64(c.HealthMinFreeDiskMB)<<20)
+	// Requests in flight are waited for on shutdown, readiness fails meanwhile
+	drainer := middleware.NewDrainer()
+	healthController := controllers.NewHealthController(healthService, drainer)
 
 	glyphRouter := routers.NewGlyphRouter(glyphController)
+	matchRouter := routers.NewMatchRouter(matchController)
+	playerRouter := routers.NewPlayerRouter(playerController)
+	replayRouter := routers.NewReplayRouter(replayController)
+	adminRouter := routers.NewAdminRouter(adminController, c.AdminToken)
+	probeRouter := routers.NewProbeRouter(healthController)
+	healthRouter := routers.NewHealthRouter(healthController, c.AdminToken)
+	metricsRouter := routers.NewMetricsRouter()
+	metrics.Registry.MustRegister(goSteamService)
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler:            middleware.ErrorHandler,
 		ProxyHeader:             fiber.HeaderXForwardedFor,
 		EnableTrustedProxyCheck: true,
 		TrustedProxies:          []string{"127.0.0.1", "::1"},
+		// Uploaded replays are streamed to the parser instead of being buffered,
+		// the upload size limit is enforced by the replay controller
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
-	//	Logger middleware for logging HTTP request/response details
-	app.Use(logger.New())
+	//	Logger middleware for logging HTTP request/response details, with the request and trace IDs
+	app.Use(middleware.AccessLog(httpLogger))
+
+	// Requests are measured per route for /metrics
+	app.Use(middleware.Metrics())
+
+	// Probes are answered during shutdown, only readiness reports it
+	probeRouter(app)
+
+	app.Use(drainer.Handler())
+
+	// Requests are stopped by their deadline, and by the server context once the server stops
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	defer stopServer()
+	requestTimeout := time.Duration(c.RequestTimeout) * time.Second
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+	app.Use(middleware.RequestContext(serverCtx, requestTimeout))
+
+	// Requests get a span the spans of the pipeline belong to
+	app.Use(middleware.Tracing())
+
+	// Requests get an ID carried by their log lines
+	app.Use(middleware.RequestID())
+
+	// Errors are answered here, the middlewares above read the answered status
+	app.Use(middleware.HandleErrors())
 
 	//	CORS middleware
 	allowedOrigins := "http://127.0.0.1:8000,http://localhost:5173,http://localhost:4173"
@@ -51,7 +180,7 @@ func Run(c *configuration.EnvConfigModel) {
 		AllowHeaders: "POST",
 	}))
 
-	routers.SetupRoutes(app, glyphRouter)
+	routers.SetupRoutes(app, glyphRouter, matchRouter, playerRouter, replayRouter, adminRouter, healthRouter, metricsRouter)
 
 	port := c.Port
 	if port == "" {
@@ -63,6 +192,108 @@ func Run(c *configuration.EnvConfigModel) {
 		host = "127.0.0.1"
 	}
 
-	log.Printf("Starting server on port %s", port)
-	log.Fatal(app.Listen(host + ":" + port))
+	// SIGTERM and SIGINT stop the server once the running requests are done
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	listenErrors := make(chan error, 1)
+	go func() {
+		logger.Info("Starting server", "host", host, "port", port)
+		listenErrors <- app.Listen(host + ":" + port)
+	}()
+
+	drained := false
+	select {
+	case err := <-listenErrors:
+		log.Fatal("Server stopped:\n", err.Error())
+	case sig := <-signals:
+		signal.Stop(signals)
+		shutdownTimeout := time.Duration(c.ShutdownTimeout) * time.Second
+		if shutdownTimeout <= 0 {
+			shutdownTimeout = defaultShutdownTimeout
+		}
+		logger.Info("Waiting for running requests before shutting down", "signal", sig.String(), "timeout", shutdownTimeout)
+		drained = shutdown(app, drainer, stopServer, shutdownTimeout, logger)
+	}
+
+	// Steam logs the accounts off, so they are not kept in game until they time out
+	goSteamService.Close()
+	// Requests that did not stop may still be writing or parsing their demos
+	if !drained {
+		logger.Warn("Keeping temporary replay files, requests still running may use them")
+	} else if err := valveService.RemoveTempFiles(); err != nil {
+		logger.Error("Cannot remove temporary replay files", "error", err)
+	}
+	if err := database.CloseDB(db); err != nil {
+		logger.Error("Cannot close the database", "error", err)
+	}
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), shutdownCancelGrace)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Error("Cannot export the remaining spans", "error", err)
+	}
+	logger.Info("Server stopped")
+}
+
+// shutdown stops accepting requests and waits for the running ones until timeout, canceling those still running after it.
+// It returns false if requests were still running after being canceled.
+func shutdown(app *fiber.App, drainer *middleware.Drainer, cancelRequests context.CancelFunc, timeout time.Duration, logger *slog.Logger) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Closes the listener and the idle connections, connections in use are closed once their request is answered
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- app.ShutdownWithContext(ctx)
+	}()
+
+	drained := true
+	if err := drainer.Drain(ctx); err != nil {
+		logger.Warn("Canceling the requests still running", "timeout", timeout)
+		cancelRequests()
+
+		graceCtx, cancelGrace := context.WithTimeout(context.Background(), shutdownCancelGrace)
+		defer cancelGrace()
+		if err := drainer.Drain(graceCtx); err != nil {
+			logger.Error("Requests did not stop after being canceled")
+			drained = false
+		}
+	}
+
+	select {
+	case err := <-shutdownDone:
+		// Connections still open at the timeout were already reported with their requests
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			logger.Error("Cannot close the connections of the server", "error", err)
+		}
+	case <-time.After(shutdownCancelGrace):
+		logger.Warn("Connections of the server were not closed in time")
+	}
+	return drained
+}
+
+// splitList splits a comma separated configuration value, empty values are skipped
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// steamAccountConfigs returns the logins of the enabled accounts
+func steamAccountConfigs(accounts []configuration.SteamAccount) []services.SteamAccountConfig {
+	var configs []services.SteamAccountConfig
+	for _, account := range accounts {
+		if account.IsEnabled() {
+			configs = append(configs, services.SteamAccountConfig{
+				Username:     account.Username,
+				Password:     account.Password,
+				SharedSecret: account.SharedSecret,
+				Priority:     account.Priority,
+			})
+		}
+	}
+	return configs
 }
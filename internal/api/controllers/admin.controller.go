@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/services"
+	"strings"
+)
+
+type SteamAccountService interface {
+	AccountHealth() []dtos.SteamAccountHealth
+	CircuitStats() dtos.SteamCircuit
+	SubmitGuardCode(username, code string) error
+}
+
+type AdminController struct {
+	SteamAccountService SteamAccountService
+}
+
+func NewAdminController(steamAccountService SteamAccountService) *AdminController {
+	return &AdminController{
+		SteamAccountService: steamAccountService,
+	}
+}
+
+// GetSteamAccounts
+//
+//	@Summary		Get Steam accounts
+//	@Description	Get the state, load and last error of every Steam account in the pool
+//	@Tags			admin
+//	@Produce		json
+//	@Security		AdminToken
+//	@Success		200								{object}	[]dtos.SteamAccountHealth	"Steam accounts"
+//	@Failure		401								{object}	dtos.MessageResponseType	"Invalid admin token"
+//	@Router			/api/admin/steam/accounts		[get]
+func (ac *AdminController) GetSteamAccounts(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(ac.SteamAccountService.AccountHealth())
+}
+
+// GetSteamCircuit
+//
+//	@Summary		Get Steam circuit breaker
+//	@Description	Get the state of the circuit breaker of the Dota GC path and how often it changed
+//	@Tags			admin
+//	@Produce		json
+//	@Security		AdminToken
+//	@Success		200								{object}	dtos.SteamCircuit			"Circuit breaker"
+//	@Failure		401								{object}	dtos.MessageResponseType	"Invalid admin token"
+//	@Router			/api/admin/steam/circuit		[get]
+func (ac *AdminController) GetSteamCircuit(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(ac.SteamAccountService.CircuitStats())
+}
+
+// SubmitSteamGuardCode
+//
+//	@Summary		Submit Steam Guard code
+//	@Description	Submit the Steam Guard code sent to the email of an account waiting for it to log in
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param			username											path		string						true	"Steam account username"
+//	@Param			code												body		dtos.SteamGuardCode			true	"Steam Guard code"
+//	@Success		202													{object}	dtos.MessageResponseType	"Code submitted"
+//	@Failure		400													{object}	dtos.MessageResponseType	"Missing code"
+//	@Failure		401													{object}	dtos.MessageResponseType	"Invalid admin token"
+//	@Failure		404													{object}	dtos.MessageResponseType	"Unknown account"
+//	@Failure		409													{object}	dtos.MessageResponseType	"Account is not waiting for a code"
+//	@Router			/api/admin/steam/accounts/{username}/guard-code		[post]
+func (ac *AdminController) SubmitSteamGuardCode(c *fiber.Ctx) error {
+	var body dtos.SteamGuardCode
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Code) == "" {
+		return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Steam Guard code is required"}
+	}
+	if err := ac.SteamAccountService.SubmitGuardCode(c.Params("username"), strings.TrimSpace(body.Code)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(dtos.MessageResponseType{Message: "Steam Guard code submitted"})
+}
@@ -1,21 +1,36 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/extractors"
+	"go-glyph/internal/core/logging"
+	"go-glyph/internal/core/metrics"
 	"go-glyph/internal/core/models"
 	"go-glyph/internal/core/services"
+	"go-glyph/internal/core/tracing"
+	"io"
+	"log/slog"
 	"strconv"
-	"sync"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type GlyphService interface {
 	GetGlyphs(getGlyphs *dtos.GetGlyphs) (dtos.GlyphParse, error)
-	CreateGlyphs(createGlyphs *dtos.CreateGlyphs) error
+}
+
+type ParseService interface {
+	Start(matchID int) bool
+	Finish(matchID int)
+	Save(ctx context.Context, glyphs []models.Glyph, match models.Match) error
 }
 
 type GoSteamService interface {
-	GetMatchDetails(matchID int) (dtos.Match, error)
+	GetMatchDetails(ctx context.Context, matchID int) (dtos.Match, error)
 }
 
 // type StratzService interface {
@@ -27,48 +42,43 @@ type GoSteamService interface {
 // }
 
 type ValveService interface {
-	RetrieveFile(match dtos.Match) error
+	RetrieveFile(ctx context.Context, match dtos.Match) (string, error)
+	RemoveFile(filename string) error
+	DownloadStats() []dtos.DownloadStats
 }
 
 type MantaService interface {
-	GetGlyphsFromDem(match dtos.Match) ([]models.Glyph, error)
+	GetGlyphsFromDem(ctx context.Context, filename string, extractorNames []string) ([]models.Glyph, models.Match, error)
+	GetGlyphsFromReplay(ctx context.Context, r io.Reader, matchID int, extractorNames []string) ([]models.Glyph, models.Match, error)
 }
 
 type GlyphController struct {
 	GlyphService   GlyphService
+	ParseService   ParseService
 	GoSteamService GoSteamService
 	// OpendotaService OpendotaService
 	// StratzService   StratzService
 	ValveService ValveService
 	MantaService MantaService
 
-	activeMatches sync.Map
+	logger *slog.Logger
 }
 
-func NewGlyphController(glyphService GlyphService, goSteamService GoSteamService,
+func NewGlyphController(glyphService GlyphService, parseService ParseService, goSteamService GoSteamService,
 	// opendotaService OpendotaService, stratzService StratzService,
-	valveService ValveService, mantaService MantaService) *GlyphController {
+	valveService ValveService, mantaService MantaService, logger *slog.Logger) *GlyphController {
 	return &GlyphController{
 		GlyphService:   glyphService,
+		ParseService:   parseService,
 		GoSteamService: goSteamService,
 		// OpendotaService: opendotaService,
 		// StratzService:   stratzService,
-		ValveService:  valveService,
-		MantaService:  mantaService,
-		activeMatches: sync.Map{},
+		ValveService: valveService,
+		MantaService: mantaService,
+		logger:       logger,
 	}
 }
 
-// Returns true if we were able to store (meaning it wasn't already there)
-func (cr *GlyphController) markIfNotProcessing(matchID int) bool {
-	_, loaded := cr.activeMatches.LoadOrStore(matchID, true)
-	return !loaded
-}
-
-func (cr *GlyphController) markMatchAsFinished(matchID int) {
-	cr.activeMatches.Delete(matchID)
-}
-
 // GetGlyphs
 //
 //	@Summary		Get glyphs
@@ -77,28 +87,51 @@ func (cr *GlyphController) markMatchAsFinished(matchID int) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			matchID					path		string						true	"Match ID"
+//	@Param			extractors				query		string						false	"Comma separated extractors to run besides glyphs (draft, scoreboard, objectives, wards), server defaults if empty"
 //	@Success		200						{object}	[]models.Glyph				"Glyphs from database"
 //	@Success		201						{object}	[]models.Glyph				"Glyphs parsed and save to database"
 //	@Success		202						{object}	dtos.MessageResponseType	"Match is already being processed"
 //	@Failure		400						{object}	dtos.MessageResponseType	"Glyphs parse error"
+//	@Failure		404						{object}	dtos.MessageResponseType	"No glyphs were used in the match, or its replay is unavailable"
+//	@Failure		503						{object}	dtos.MessageResponseType	"Dota servers are unavailable, retry after the Retry-After header"
+//	@Failure		504						{object}	dtos.MessageResponseType	"Request timed out"
 //	@Router			/api/glyph/{matchID}	[post]
-func (cr *GlyphController) GetGlyphs(c *fiber.Ctx) error {
+func (cr *GlyphController) GetGlyphs(c *fiber.Ctx) (err error) {
 	matchIDString := c.Params("matchID")
 	matchID, err := strconv.Atoi(matchIDString)
 	if err != nil {
 		return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Match ID is not an integer"}
 	}
 
+	// The request context ends with the request deadline or server shutdown, stopping the lookup, download and parse.
+	// Its log lines carry the match ID.
+	ctx := logging.WithMatchID(c.UserContext(), matchID)
+	trace.SpanFromContext(ctx).SetAttributes(tracing.MatchIDKey.Int(matchID))
+
+	outcome := metrics.OutcomeParsed
+	defer func() {
+		recordMatch(ctx, cr.logger, outcome, err)
+	}()
+
 	// Check if parsed match is stored in db and retrieve if stored
+	_, span := tracing.Start(ctx, "glyphs.Lookup")
 	getGlyphes := &dtos.GetGlyphs{MatchID: matchID}
 	glyphParse, err := cr.GlyphService.GetGlyphs(getGlyphes)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+
+	// Extractors to run are checked before any download is done
+	extractorNames, err := getExtractorNames(c)
 	if err != nil {
 		return err
 	}
 
 	// If match is parsed -> return parsed match
 	if glyphParse.GlyphParsed == true {
-		return c.Status(fiber.StatusOK).JSON(glyphParse.Glyphs)
+		outcome = metrics.OutcomeCached
+		return sendGlyphs(c, fiber.StatusOK, glyphParse.Glyphs)
 	}
 
 	// // If not in db
@@ -111,38 +144,101 @@ func (cr *GlyphController) GetGlyphs(c *fiber.Ctx) error {
 	// 	}
 	// }
 
-	// Atomically try to mark this match as processing
-	if !cr.markIfNotProcessing(matchID) {
+	// Atomically try to mark this match as processing, uploads of the match included
+	if !cr.ParseService.Start(matchID) {
 		return services.UserFacingError{Code: fiber.StatusAccepted, Message: "Match is already being processed"}
 	}
 
 	// Make sure to mark as finished when we're done
-	defer cr.markMatchAsFinished(matchID)
+	defer cr.ParseService.Finish(matchID)
 
-	match, err := cr.GoSteamService.GetMatchDetails(matchID)
+	match, err := cr.GoSteamService.GetMatchDetails(ctx, matchID)
 	if err != nil {
 		return err
 	}
 
 	// Download from valve cluster
-	err = cr.ValveService.RetrieveFile(match)
+	filename, err := cr.ValveService.RetrieveFile(ctx, match)
 	if err != nil {
 		return err
 	}
 
-	// Parse using Manta(Dotabuff golang parser)
-	glyphs, err := cr.MantaService.GetGlyphsFromDem(match)
+	// Parse using Manta(Dotabuff golang parser), the demo is not needed afterwards
+	glyphs, matchRecord, err := cr.MantaService.GetGlyphsFromDem(ctx, filename, extractorNames)
+	if removeErr := cr.ValveService.RemoveFile(filename); removeErr != nil && err == nil {
+		err = removeErr
+	}
 	if err != nil {
 		return err
 	}
 
 	// Save parsed match to database
-	createGlyphs := dtos.CreateGlyphs{Glyphs: glyphs}
-	err = cr.GlyphService.CreateGlyphs(&createGlyphs)
-	if err != nil {
+	if err := cr.ParseService.Save(ctx, glyphs, matchRecord); err != nil {
 		return err
 	}
 
 	// Return parsed match
-	return c.Status(fiber.StatusCreated).JSON(glyphs)
+	return sendGlyphs(c, fiber.StatusCreated, glyphs)
+}
+
+// sendGlyphs answers the glyphs of a match with status. A match without glyphs is saved all the same,
+// but it is answered 404 like before.
+func sendGlyphs(c *fiber.Ctx, status int, glyphs []models.Glyph) error {
+	if len(glyphs) == 0 {
+		return services.UserFacingError{Code: fiber.StatusNotFound, Message: "No glyphs found lol"}
+	}
+	return c.Status(status).JSON(glyphs)
+}
+
+// getExtractorNames reads and validates the comma separated extractors query
+func getExtractorNames(c *fiber.Ctx) ([]string, error) {
+	var extractorNames []string
+	if extractorsQuery := c.Query("extractors"); extractorsQuery != "" {
+		extractorNames = strings.Split(extractorsQuery, ",")
+	}
+	if err := extractors.Validate(extractorNames); err != nil {
+		return nil, services.UserFacingError{Code: fiber.StatusBadRequest, Message: err.Error()}
+	}
+	return extractorNames, nil
+}
+
+// recordMatch counts and logs a processed match, outcome is the one of a request without error
+func recordMatch(ctx context.Context, logger *slog.Logger, outcome string, err error) {
+	outcome = matchOutcome(outcome, err)
+	metrics.Matches.WithLabelValues(outcome).Inc()
+
+	level := slog.LevelInfo
+	switch outcome {
+	case metrics.OutcomeError, metrics.OutcomeParseError:
+		level = slog.LevelError
+	case metrics.OutcomeTooOld, metrics.OutcomeGCUnavailable, metrics.OutcomeCanceled:
+		level = slog.LevelWarn
+	}
+	if err != nil {
+		logger.Log(ctx, level, "Match processed", "outcome", outcome, "error", err)
+		return
+	}
+	logger.Log(ctx, level, "Match processed", "outcome", outcome)
+}
+
+// matchOutcome classifies a processed match for the metrics, outcome is the one of a request without error
+func matchOutcome(outcome string, err error) string {
+	var userFacing services.UserFacingError
+	var unavailable services.ReplayUnavailableError
+	switch {
+	case err == nil:
+		return outcome
+	case errors.As(err, &userFacing) && userFacing.Code == fiber.StatusAccepted:
+		return metrics.OutcomeInProgress
+	case errors.As(err, &services.SteamUnavailableError{}):
+		return metrics.OutcomeGCUnavailable
+	case errors.As(err, &unavailable) && unavailable.Expired():
+		return metrics.OutcomeTooOld
+	case errors.As(err, &services.ParserError{}), errors.As(err, &services.ParserCreationError{}):
+		return metrics.OutcomeParseError
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return metrics.OutcomeCanceled
+	default:
+		return metrics.OutcomeError
+	}
 }
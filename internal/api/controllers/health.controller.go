@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+	"strings"
+)
+
+type HealthService interface {
+	Status(ctx context.Context) dtos.HealthStatus
+}
+
+type ShutdownState interface {
+	Draining() bool
+}
+
+type HealthController struct {
+	HealthService HealthService
+	ShutdownState ShutdownState
+}
+
+func NewHealthController(healthService HealthService, shutdownState ShutdownState) *HealthController {
+	return &HealthController{
+		HealthService: healthService,
+		ShutdownState: shutdownState,
+	}
+}
+
+// GetLiveness
+//
+//	@Summary		Liveness probe
+//	@Description	Answers as long as the process serves requests, whatever the state of its dependencies
+//	@Tags			health
+//	@Produce		json
+//	@Success		200			{object}	dtos.MessageResponseType	"Process is alive"
+//	@Router			/healthz	[get]
+func (hc *HealthController) GetLiveness(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(dtos.MessageResponseType{Message: "alive"})
+}
+
+// GetReadiness
+//
+//	@Summary		Readiness probe
+//	@Description	Ready when the database is reachable, a Steam account has a Dota GC session, demos can be written
+//	@Description	and enough disk space is free. Not ready once the server is shutting down.
+//	@Tags			health
+//	@Produce		json
+//	@Success		200			{object}	dtos.MessageResponseType	"Ready"
+//	@Failure		503			{object}	dtos.MessageResponseType	"Dependencies that are not ready"
+//	@Router			/readyz		[get]
+func (hc *HealthController) GetReadiness(c *fiber.Ctx) error {
+	// Load balancers stop sending requests while the running ones finish
+	if hc.ShutdownState.Draining() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(dtos.MessageResponseType{Message: "not ready: shutting down"})
+	}
+
+	status := hc.HealthService.Status(c.UserContext())
+	if status.Ready {
+		return c.Status(fiber.StatusOK).JSON(dtos.MessageResponseType{Message: "ready"})
+	}
+
+	var notReady []string
+	for _, dependency := range status.Dependencies {
+		if !dependency.Ready {
+			notReady = append(notReady, dependency.Name)
+		}
+	}
+	return c.Status(fiber.StatusServiceUnavailable).JSON(dtos.MessageResponseType{Message: "not ready: " + strings.Join(notReady, ", ")})
+}
+
+// GetStatus
+//
+//	@Summary		Get status
+//	@Description	Get the state, details and last error of every dependency, with the admin token
+//	@Tags			health
+//	@Produce		json
+//	@Security		AdminToken
+//	@Success		200				{object}	dtos.HealthStatus			"Dependencies"
+//	@Failure		401				{object}	dtos.MessageResponseType	"Invalid admin token"
+//	@Router			/api/status		[get]
+func (hc *HealthController) GetStatus(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(hc.HealthService.Status(c.UserContext()))
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+	"go-glyph/internal/core/services"
+	"strconv"
+)
+
+type MatchService interface {
+	GetMatch(getMatch *dtos.GetMatch) (dtos.MatchParse, error)
+	CreateMatch(createMatch *dtos.CreateMatch) error
+	GetTimeline(getMatch *dtos.GetMatch) ([]dtos.TimelineEvent, error)
+	GetWards(getMatch *dtos.GetMatch) ([]models.Ward, error)
+}
+
+type MatchController struct {
+	MatchService MatchService
+}
+
+func NewMatchController(matchService MatchService) *MatchController {
+	return &MatchController{
+		MatchService: matchService,
+	}
+}
+
+// GetMatch
+//
+//	@Summary		Get match
+//	@Description	Get outcome, scoreboard and draft of a parsed match using match id
+//	@Tags			match
+//	@Produce		json
+//	@Param			matchID					path		string						true	"Match ID"
+//	@Success		200						{object}	models.Match				"Match from database"
+//	@Failure		400						{object}	dtos.MessageResponseType	"Match ID is not an integer"
+//	@Failure		404						{object}	dtos.MessageResponseType	"Match is not parsed yet"
+//	@Router			/api/matches/{matchID}	[get]
+func (cr *MatchController) GetMatch(c *fiber.Ctx) error {
+	matchParse, err := cr.getParsedMatch(c)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(matchParse.Match)
+}
+
+// GetPlayers
+//
+//	@Summary		Get match players
+//	@Description	Get lineup and draft of a parsed match using match id
+//	@Tags			match
+//	@Produce		json
+//	@Param			matchID							path		string						true	"Match ID"
+//	@Success		200								{object}	dtos.MatchPlayers			"Players and draft from database"
+//	@Failure		400								{object}	dtos.MessageResponseType	"Match ID is not an integer"
+//	@Failure		404								{object}	dtos.MessageResponseType	"Match is not parsed yet"
+//	@Router			/api/matches/{matchID}/players	[get]
+func (cr *MatchController) GetPlayers(c *fiber.Ctx) error {
+	matchParse, err := cr.getParsedMatch(c)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dtos.MatchPlayers{
+		MatchID:  matchParse.Match.MatchID,
+		GameMode: matchParse.Match.GameMode,
+		Players:  matchParse.Match.Players,
+		Draft:    matchParse.Match.Draft,
+	})
+}
+
+// GetTimeline
+//
+//	@Summary		Get match timeline
+//	@Description	Get glyphs together with Roshan, Aegis and Tormentor events of a parsed match ordered by game time
+//	@Tags			match
+//	@Produce		json
+//	@Param			matchID							path		string						true	"Match ID"
+//	@Success		200								{object}	[]dtos.TimelineEvent		"Timeline from database"
+//	@Failure		400								{object}	dtos.MessageResponseType	"Match ID is not an integer"
+//	@Failure		404								{object}	dtos.MessageResponseType	"Match is not parsed yet"
+//	@Router			/api/matches/{matchID}/timeline	[get]
+func (cr *MatchController) GetTimeline(c *fiber.Ctx) error {
+	matchID, err := strconv.Atoi(c.Params("matchID"))
+	if err != nil {
+		return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Match ID is not an integer"}
+	}
+
+	timeline, err := cr.MatchService.GetTimeline(&dtos.GetMatch{MatchID: matchID})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(timeline)
+}
+
+// GetWards
+//
+//	@Summary		Get match wards
+//	@Description	Get observer and sentry wards with placer, killer and lifetime of a parsed match
+//	@Tags			match
+//	@Produce		json
+//	@Param			matchID						path		string						true	"Match ID"
+//	@Success		200							{object}	[]models.Ward				"Wards from database"
+//	@Failure		400							{object}	dtos.MessageResponseType	"Match ID is not an integer"
+//	@Failure		404							{object}	dtos.MessageResponseType	"Match is not parsed yet"
+//	@Router			/api/matches/{matchID}/wards	[get]
+func (cr *MatchController) GetWards(c *fiber.Ctx) error {
+	matchID, err := strconv.Atoi(c.Params("matchID"))
+	if err != nil {
+		return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Match ID is not an integer"}
+	}
+
+	wards, err := cr.MatchService.GetWards(&dtos.GetMatch{MatchID: matchID})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(wards)
+}
+
+func (cr *MatchController) getParsedMatch(c *fiber.Ctx) (dtos.MatchParse, error) {
+	matchID, err := strconv.Atoi(c.Params("matchID"))
+	if err != nil {
+		return dtos.MatchParse{}, services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Match ID is not an integer"}
+	}
+
+	matchParse, err := cr.MatchService.GetMatch(&dtos.GetMatch{MatchID: matchID})
+	if err != nil {
+		return dtos.MatchParse{}, err
+	}
+	if !matchParse.MatchParsed {
+		return dtos.MatchParse{}, services.UserFacingError{Code: fiber.StatusNotFound, Message: "Match is not parsed yet"}
+	}
+
+	return matchParse, nil
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+)
+
+const defaultHeatmapCellSize = 512
+
+type PlayerService interface {
+	GetWardHeatmap(getWardHeatmap *dtos.GetWardHeatmap) (dtos.WardHeatmap, error)
+}
+
+type PlayerController struct {
+	PlayerService PlayerService
+}
+
+func NewPlayerController(playerService PlayerService) *PlayerController {
+	return &PlayerController{
+		PlayerService: playerService,
+	}
+}
+
+// GetWardHeatmap
+//
+//	@Summary		Get ward heatmap
+//	@Description	Get number of wards placed by a player in each cell of the map across all parsed matches
+//	@Tags			player
+//	@Produce		json
+//	@Param			steamID								path		string						true	"Steam ID of the player"
+//	@Param			type								query		string						false	"Ward type (observer or sentry), all wards if empty"
+//	@Param			cellSize							query		int							false	"Cell size in world units (64-4096), 512 by default"
+//	@Success		200									{object}	dtos.WardHeatmap			"Heatmap from database"
+//	@Failure		400									{object}	dtos.MessageResponseType	"Invalid parameters"
+//	@Router			/api/players/{steamID}/wards/heatmap	[get]
+func (cr *PlayerController) GetWardHeatmap(c *fiber.Ctx) error {
+	getWardHeatmap := &dtos.GetWardHeatmap{
+		UserSteamID: c.Params("steamID"),
+		Type:        c.Query("type"),
+		CellSize:    c.QueryInt("cellSize", defaultHeatmapCellSize),
+	}
+
+	heatmap, err := cr.PlayerService.GetWardHeatmap(getWardHeatmap)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(heatmap)
+}
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/logging"
+	"go-glyph/internal/core/metrics"
+	"go-glyph/internal/core/services"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+const (
+	replayMatchIDField = "matchID"
+	replayFileField    = "file"
+)
+
+var errReplayTooLarge = errors.New("replay is too large")
+
+type ReplayController struct {
+	GlyphService GlyphService
+	ParseService ParseService
+	MantaService MantaService
+	ValveService ValveService
+
+	uploadLimit int64
+	logger      *slog.Logger
+}
+
+// NewReplayController creates a controller accepting replays of at most uploadLimit bytes
+func NewReplayController(glyphService GlyphService, parseService ParseService, mantaService MantaService,
+	valveService ValveService, uploadLimit int64, logger *slog.Logger) *ReplayController {
+	return &ReplayController{
+		GlyphService: glyphService,
+		ParseService: parseService,
+		MantaService: mantaService,
+		ValveService: valveService,
+		uploadLimit:  uploadLimit,
+		logger:       logger,
+	}
+}
+
+// UploadReplay
+//
+//	@Summary		Upload replay
+//	@Description	Parse glyphs from an uploaded .dem or .dem.bz2 replay, for matches Valve no longer serves.
+//	@Description	The body is streamed to the parser, so the matchID field must come before the file field.
+//	@Tags			replay
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			matchID			formData	int							true	"Match ID, must be the one recorded in the replay"
+//	@Param			file			formData	file						true	"Replay (.dem or .dem.bz2)"
+//	@Param			extractors		query		string						false	"Comma separated extractors to run besides glyphs (draft, scoreboard, objectives, wards), server defaults if empty"
+//	@Success		200				{object}	[]models.Glyph				"Glyphs from database"
+//	@Success		201				{object}	[]models.Glyph				"Glyphs parsed and save to database"
+//	@Success		202				{object}	dtos.MessageResponseType	"Match is already being processed"
+//	@Failure		400				{object}	dtos.MessageResponseType	"Invalid upload or replay of another match"
+//	@Failure		404				{object}	dtos.MessageResponseType	"No glyphs were used in the match"
+//	@Failure		413				{object}	dtos.MessageResponseType	"Replay is too large"
+//	@Router			/api/replays	[post]
+func (cr *ReplayController) UploadReplay(c *fiber.Ctx) error {
+	// An unread rest of the body is not drained, so the connection cannot be reused
+	c.Context().SetConnectionClose()
+
+	extractorNames, err := getExtractorNames(c)
+	if err != nil {
+		return err
+	}
+
+	boundary := string(c.Context().Request.Header.MultipartFormBoundary())
+	if boundary == "" {
+		return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Request is not multipart/form-data"}
+	}
+	if int64(c.Context().Request.Header.ContentLength()) > cr.uploadLimit {
+		return cr.tooLargeError()
+	}
+
+	// The body is read as a stream so the replay is never held in memory or written to disk
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	limited := &limitedReader{r: body, remaining: cr.uploadLimit}
+	reader := multipart.NewReader(limited, boundary)
+
+	matchID := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Replay file is missing"}
+		}
+		if err != nil {
+			if errors.Is(err, errReplayTooLarge) {
+				return cr.tooLargeError()
+			}
+			return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Cannot read multipart form"}
+		}
+
+		switch part.FormName() {
+		case replayMatchIDField:
+			value, err := io.ReadAll(io.LimitReader(part, 32))
+			if err != nil {
+				return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Cannot read match ID"}
+			}
+			matchID, err = strconv.Atoi(strings.TrimSpace(string(value)))
+			if err != nil {
+				return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Match ID is not an integer"}
+			}
+		case replayFileField:
+			if matchID == 0 {
+				return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Match ID must be sent before the file"}
+			}
+			err := cr.parseReplay(c, part, matchID, extractorNames)
+			if limited.exceeded {
+				return cr.tooLargeError()
+			}
+			return err
+		}
+	}
+}
+
+// parseReplay parses glyphs from the uploaded file and saves them unless the match is already parsed
+func (cr *ReplayController) parseReplay(c *fiber.Ctx, file *multipart.Part, matchID int, extractorNames []string) (err error) {
+	ctx := logging.WithMatchID(c.UserContext(), matchID)
+	outcome := metrics.OutcomeParsed
+	defer func() {
+		recordMatch(ctx, cr.logger, outcome, err)
+	}()
+
+	filename := file.FileName()
+	if !strings.HasSuffix(filename, ".dem") && !strings.HasSuffix(filename, ".dem.bz2") {
+		return services.UserFacingError{Code: fiber.StatusBadRequest, Message: "Replay must be a .dem or .dem.bz2 file"}
+	}
+
+	// Check if parsed match is stored in db and retrieve if stored
+	getGlyphes := &dtos.GetGlyphs{MatchID: matchID}
+	glyphParse, err := cr.GlyphService.GetGlyphs(getGlyphes)
+	if err != nil {
+		return err
+	}
+
+	// If match is parsed -> return parsed match
+	if glyphParse.GlyphParsed == true {
+		outcome = metrics.OutcomeCached
+		return sendGlyphs(c, fiber.StatusOK, glyphParse.Glyphs)
+	}
+
+	// Atomically try to mark this match as processing, downloads of the match included
+	if !cr.ParseService.Start(matchID) {
+		return services.UserFacingError{Code: fiber.StatusAccepted, Message: "Match is already being processed"}
+	}
+
+	// Make sure to mark as finished when we're done
+	defer cr.ParseService.Finish(matchID)
+
+	// Parse using Manta(Dotabuff golang parser)
+	glyphs, matchRecord, err := cr.MantaService.GetGlyphsFromReplay(ctx, file, matchID, extractorNames)
+	if err != nil {
+		return err
+	}
+
+	// Save parsed glyphs, lineup and draft to database
+	err = cr.ParseService.Save(ctx, glyphs, matchRecord)
+	if err != nil {
+		return err
+	}
+
+	// Return parsed match
+	return sendGlyphs(c, fiber.StatusCreated, glyphs)
+}
+
+// GetDownloadStats
+//
+//	@Summary		Get replay downloads
+//	@Description	Get current downloads, downloaded bytes and throughput over the last 10 seconds per replay cluster and mirror
+//	@Tags			replay
+//	@Produce		json
+//	@Success		200							{object}	[]dtos.DownloadStats	"Downloads per cluster"
+//	@Router			/api/replays/downloads		[get]
+func (cr *ReplayController) GetDownloadStats(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(cr.ValveService.DownloadStats())
+}
+
+func (cr *ReplayController) tooLargeError() error {
+	return services.UserFacingError{
+		Code:    fiber.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf("Replay is larger than %d MB", cr.uploadLimit>>20),
+	}
+}
+
+// limitedReader fails with errReplayTooLarge once more than remaining bytes are read.
+// Unlike io.LimitReader it remembers that the limit was hit, so a truncated replay is not reported as corrupt.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		l.exceeded = true
+		return 0, errReplayTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return n, errReplayTooLarge
+	}
+	return n, err
+}
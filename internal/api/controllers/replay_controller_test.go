@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/api/middleware"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+	"go-glyph/internal/core/services"
+)
+
+// fixtureDemo is the synthetic demo of the extractor fixtures, it records fixtureMatchID
+var fixtureDemo = filepath.Join("..", "..", "core", "extractors", "testdata", "demos", "synthetic_7000000001.dem")
+
+const fixtureMatchID = 7000000001
+
+// fakeGlyphService answers the stored glyphs of every match with parse
+type fakeGlyphService struct {
+	parse dtos.GlyphParse
+}
+
+func (s fakeGlyphService) GetGlyphs(getGlyphs *dtos.GetGlyphs) (dtos.GlyphParse, error) {
+	return s.parse, nil
+}
+
+// fakeParseService records the saved match
+type fakeParseService struct {
+	started, finished bool
+	saved             *models.Match
+	glyphs            []models.Glyph
+}
+
+func (s *fakeParseService) Start(matchID int) bool {
+	s.started = true
+	return true
+}
+
+func (s *fakeParseService) Finish(matchID int) {
+	s.finished = true
+}
+
+func (s *fakeParseService) Save(ctx context.Context, glyphs []models.Glyph, match models.Match) error {
+	s.glyphs, s.saved = glyphs, &match
+	return nil
+}
+
+// multipartField is a part of an upload, a file if filename is set
+type multipartField struct {
+	name, filename string
+	content        []byte
+}
+
+// uploadRequest builds a multipart upload with the fields in order. The body is chunked unless
+// contentLength is set, so the size cap is hit while the body is read.
+func uploadRequest(t *testing.T, contentLength bool, fields ...multipartField) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range fields {
+		var part io.Writer
+		var err error
+		if field.filename != "" {
+			part, err = writer.CreateFormFile(field.name, field.filename)
+		} else {
+			part, err = writer.CreateFormField(field.name)
+		}
+		if err != nil {
+			t.Fatalf("cannot create part %s: %v", field.name, err)
+		}
+		_, _ = part.Write(field.content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("cannot close multipart body: %v", err)
+	}
+
+	request := httptest.NewRequest(fiber.MethodPost, "/api/replays", &body)
+	request.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+	if !contentLength {
+		// app.Test adds a Content-Length header, which the chunked encoding takes precedence over
+		request.TransferEncoding = []string{"chunked"}
+		request.Header.Set(fiber.HeaderContentLength, "0")
+	}
+	return request
+}
+
+// uploadApp serves the upload endpoint with the parser of the server and the given upload limit, no match is stored
+func uploadApp(uploadLimit int64, parseService *fakeParseService) *fiber.App {
+	return uploadAppWithGlyphs(uploadLimit, parseService, fakeGlyphService{})
+}
+
+func uploadAppWithGlyphs(uploadLimit int64, parseService *fakeParseService, glyphService fakeGlyphService) *fiber.App {
+	controller := NewReplayController(glyphService, parseService, services.NewMantaService(nil), nil,
+		uploadLimit, slog.Default())
+	app := fiber.New(fiber.Config{
+		ErrorHandler:                 middleware.ErrorHandler,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+	app.Post("/api/replays", controller.UploadReplay)
+	return app
+}
+
+// readFixtureDemo returns the synthetic demo
+func readFixtureDemo(t *testing.T) []byte {
+	t.Helper()
+	demo, err := os.ReadFile(fixtureDemo)
+	if err != nil {
+		t.Fatalf("cannot read the fixture demo: %v", err)
+	}
+	return demo
+}
+
+// answer sends the request and returns the status and message of the response
+func answer(t *testing.T, app *fiber.App, request *http.Request) (int, string) {
+	t.Helper()
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("cannot read response: %v", err)
+	}
+	return response.StatusCode, string(body)
+}
+
+func TestUploadReplayParsesAndSavesTheReplay(t *testing.T) {
+	parseService := &fakeParseService{}
+	app := uploadApp(1<<30, parseService)
+
+	status, body := answer(t, app, uploadRequest(t, false,
+		multipartField{name: replayMatchIDField, content: []byte("7000000001")},
+		multipartField{name: replayFileField, filename: "synthetic.dem", content: readFixtureDemo(t)},
+	))
+	if status != fiber.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", status, body)
+	}
+
+	var glyphs []models.Glyph
+	if err := json.Unmarshal([]byte(body), &glyphs); err != nil {
+		t.Fatalf("cannot decode glyphs %q: %v", body, err)
+	}
+	if len(glyphs) != 2 || glyphs[0].MatchID != fixtureMatchID {
+		t.Fatalf("expected the 2 glyphs of the fixture, got %+v", glyphs)
+	}
+	if parseService.saved == nil || parseService.saved.MatchID != fixtureMatchID || len(parseService.glyphs) != 2 {
+		t.Fatalf("expected the match and its glyphs to be saved, got %+v", parseService.saved)
+	}
+	if !parseService.finished {
+		t.Fatal("expected the match to be finished")
+	}
+}
+
+func TestUploadReplayRejectsInvalidUploads(t *testing.T) {
+	demo := readFixtureDemo(t)
+	matchID := multipartField{name: replayMatchIDField, content: []byte("7000000001")}
+	file := multipartField{name: replayFileField, filename: "synthetic.dem", content: demo}
+
+	tests := []struct {
+		name          string
+		uploadLimit   int64
+		contentLength bool
+		fields        []multipartField
+		status        int
+		message       string
+	}{
+		{
+			name:          "declared size over the limit",
+			uploadLimit:   1 << 10,
+			contentLength: true,
+			fields:        []multipartField{matchID, file},
+			status:        fiber.StatusRequestEntityTooLarge,
+			message:       "Replay is larger than",
+		},
+		{
+			name:        "streamed size over the limit",
+			uploadLimit: 1 << 10,
+			fields:      []multipartField{matchID, file},
+			status:      fiber.StatusRequestEntityTooLarge,
+			message:     "Replay is larger than",
+		},
+		{
+			name:        "file before match ID",
+			uploadLimit: 1 << 30,
+			fields:      []multipartField{file, matchID},
+			status:      fiber.StatusBadRequest,
+			message:     "Match ID must be sent before the file",
+		},
+		{
+			name:        "wrong extension",
+			uploadLimit: 1 << 30,
+			fields:      []multipartField{matchID, {name: replayFileField, filename: "synthetic.zip", content: demo}},
+			status:      fiber.StatusBadRequest,
+			message:     "Replay must be a .dem or .dem.bz2 file",
+		},
+		{
+			name:        "replay of another match",
+			uploadLimit: 1 << 30,
+			fields:      []multipartField{{name: replayMatchIDField, content: []byte("1234")}, file},
+			status:      fiber.StatusBadRequest,
+			message:     "Replay is of match 7000000001, not 1234",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if int64(len(demo)) <= test.uploadLimit && test.status == fiber.StatusRequestEntityTooLarge {
+				t.Fatalf("the fixture demo of %d bytes is not over the limit", len(demo))
+			}
+			parseService := &fakeParseService{}
+			app := uploadApp(test.uploadLimit, parseService)
+
+			status, body := answer(t, app, uploadRequest(t, test.contentLength, test.fields...))
+			if status != test.status || !strings.Contains(body, test.message) {
+				t.Fatalf("expected status %d with %q, got %d: %s", test.status, test.message, status, body)
+			}
+			if parseService.saved != nil {
+				t.Fatalf("expected nothing to be saved, got %+v", parseService.saved)
+			}
+			if parseService.started && !parseService.finished {
+				t.Fatal("expected a started match to be finished")
+			}
+		})
+	}
+}
+
+func TestUploadReplayAnswersStoredMatchWithoutGlyphsNotFound(t *testing.T) {
+	parseService := &fakeParseService{}
+	app := uploadAppWithGlyphs(1<<30, parseService, fakeGlyphService{parse: dtos.GlyphParse{GlyphParsed: true}})
+
+	status, body := answer(t, app, uploadRequest(t, true,
+		multipartField{name: replayMatchIDField, content: []byte("7000000001")},
+		multipartField{name: replayFileField, filename: "synthetic.dem", content: readFixtureDemo(t)},
+	))
+	if status != fiber.StatusNotFound || !strings.Contains(body, "No glyphs found") {
+		t.Fatalf("expected status 404 for a match without glyphs, got %d: %s", status, body)
+	}
+	if parseService.started {
+		t.Fatal("expected a stored match not to be parsed again")
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"time"
+)
+
+// AccessLog logs every request once answered, with the request and trace IDs set by the later middlewares.
+// Server errors are logged as errors and client errors as warnings, errors are answered by HandleErrors.
+func AccessLog(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		// The query is left out, it could carry secrets
+		attributes := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.IP()),
+		}
+		if requestErr := RequestError(c); requestErr != nil {
+			attributes = append(attributes, slog.Any("error", requestErr))
+		}
+		// The context set by the later middlewares holds the request and trace IDs
+		logger.LogAttrs(c.UserContext(), level, "Request", attributes...)
+		return err
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/services"
+	"strings"
+)
+
+// AdminAuth allows requests with the admin token as bearer token, the admin API is disabled without a token
+func AdminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return services.UserFacingError{Code: fiber.StatusNotFound, Message: "Admin API is disabled"}
+		}
+
+		provided, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			return services.UserFacingError{Code: fiber.StatusUnauthorized, Message: "Invalid admin token"}
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+	"sync"
+)
+
+// Drainer tracks the requests in flight, so shutdown can wait for the running parses instead of killing them
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Handler counts the request until it is answered, requests arriving once draining started are rejected.
+// It runs before HandleErrors, so rejected requests are answered here.
+func (d *Drainer) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		d.mu.Lock()
+		if d.draining {
+			d.mu.Unlock()
+			c.Set(fiber.HeaderConnection, "close")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(dtos.MessageResponseType{Message: "Server is shutting down"})
+		}
+		d.inFlight.Add(1)
+		d.mu.Unlock()
+		defer d.inFlight.Done()
+
+		return c.Next()
+	}
+}
+
+// Draining tells whether shutdown started, readiness probes fail from then on
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Drain rejects new requests and waits until the requests in flight are answered or ctx is done
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,18 +1,61 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"go-glyph/internal/core/dtos"
 	"go-glyph/internal/core/services"
+	"math"
+	"strconv"
 )
 
+// requestErrorKey is the local holding the error a request was answered with
+const requestErrorKey = "requestError"
+
+// HandleErrors answers the errors of the handlers after it with the ErrorHandler of the app. It is the only
+// middleware answering errors, so the middlewares around it read the status from the response and the error
+// from RequestError.
+func HandleErrors() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if err == nil {
+			return nil
+		}
+		c.Locals(requestErrorKey, err)
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			_ = c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return nil
+	}
+}
+
+// RequestError returns the error the request was answered with by HandleErrors, nil if it succeeded
+func RequestError(c *fiber.Ctx) error {
+	err, _ := c.Locals(requestErrorKey).(error)
+	return err
+}
+
 func ErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 	message := "Internal Server Error"
 
+	// The request context ended before the match was processed
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return c.Status(fiber.StatusGatewayTimeout).JSON(dtos.MessageResponseType{Message: "Request timed out, please try again"})
+	case errors.Is(err, context.Canceled):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(dtos.MessageResponseType{Message: "Request was canceled, please try again"})
+	}
+
 	switch e := err.(type) {
 	case services.UserFacingError:
 		return c.Status(e.Code).JSON(dtos.MessageResponseType{Message: e.Message})
+	case services.SteamUnavailableError:
+		if e.RetryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(dtos.MessageResponseType{Message: e.Error()})
 	case services.ValidateError:
 		code = fiber.StatusBadRequest
 		message = e.Error()
@@ -55,6 +98,12 @@ func ErrorHandler(c *fiber.Ctx, err error) error {
 	case services.CloseFileError:
 		code = fiber.StatusInternalServerError
 		message = e.Error()
+	case services.ReplayUnavailableError:
+		code = e.StatusCode()
+		message = e.Error()
+	case services.DemoMatchIDError:
+		code = fiber.StatusInternalServerError
+		message = e.Error()
 	default:
 		message = err.Error()
 	}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/services"
+)
+
+func TestAccessLogReadsTheStatusAnsweredByHandleErrors(t *testing.T) {
+	var logs bytes.Buffer
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(AccessLog(slog.New(slog.NewTextHandler(&logs, nil))))
+	app.Use(Metrics())
+	app.Use(Tracing())
+	app.Use(HandleErrors())
+	app.Get("/missing", func(c *fiber.Ctx) error {
+		return services.UserFacingError{Code: fiber.StatusNotFound, Message: "No glyphs found"}
+	})
+
+	response, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/missing", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if response.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", response.StatusCode)
+	}
+
+	line := logs.String()
+	if !strings.Contains(line, "level=WARN") || !strings.Contains(line, "status=404") || !strings.Contains(line, "No glyphs found") {
+		t.Fatalf("expected a warning with the answered status and the error, got %q", line)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/metrics"
+	"strconv"
+	"time"
+)
+
+// Metrics counts the requests and their duration per route. Routes are the registered paths, so match IDs do not
+// create series, requests no route matched are counted as "unmatched".
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		own := c.Route()
+
+		// Errors are already answered by HandleErrors, so the status is the one answered
+		err := c.Next()
+
+		// The route stays the one of this middleware if no handler was found
+		route := c.Route().Path
+		if c.Route() == own {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Method(), route, strconv.Itoa(c.Response().StatusCode())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Method(), route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
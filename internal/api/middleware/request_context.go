@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// disconnectPollInterval is how often the connection of a running request is checked for a disconnected client
+const disconnectPollInterval = time.Second
+
+// RequestContext gives every request a context that ends after timeout, once parent is canceled on shutdown
+// or once the client disconnects. Handlers pass c.UserContext() to the services, so a request given up on stops
+// its lookups, downloads and parses.
+//
+// fasthttp does not report disconnects, so the socket is peeked at every disconnectPollInterval where the platform
+// allows it (see connClosedFunc). Elsewhere, and for TLS connections, a disconnected request runs until its timeout.
+func RequestContext(parent context.Context, timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		if closed := connClosedFunc(c.Context().Conn()); closed != nil {
+			stop := watchDisconnect(closed, disconnectPollInterval, cancel)
+			defer stop()
+		}
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// watchDisconnect calls cancel once closed reports the connection as closed, until stop is called
+func watchDisconnect(closed func() bool, interval time.Duration, cancel context.CancelFunc) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if closed() {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
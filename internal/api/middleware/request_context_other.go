@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package middleware
+
+import "net"
+
+// connClosedFunc cannot peek at sockets on this platform, disconnected requests run until their timeout
+func connClosedFunc(conn net.Conn) func() bool {
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin
+
+package middleware
+
+import (
+	"net"
+	"syscall"
+)
+
+// connClosedFunc returns a check whether the client closed conn, nil if it cannot be checked.
+// The socket is peeked at without blocking, so no data of the request or of the next one is consumed.
+// A client that only closed its sending side is reported as closed too.
+func connClosedFunc(conn net.Conn) func() bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	return func() bool {
+		closed := false
+		var b [1]byte
+		err := raw.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), b[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			// A read of nothing without an error is the end of the stream
+			closed = n == 0 && err == nil
+			return true
+		})
+		return err == nil && closed
+	}
+}
@@ -0,0 +1,51 @@
+//go:build linux || darwin
+
+package middleware
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestContextEndsWhenClientDisconnects(t *testing.T) {
+	started := make(chan struct{})
+	ended := make(chan error, 1)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(RequestContext(context.Background(), time.Minute))
+	app.Get("/", func(c *fiber.Ctx) error {
+		close(started)
+		select {
+		case <-c.UserContext().Done():
+			ended <- c.UserContext().Err()
+		case <-time.After(5 * time.Second):
+			ended <- nil
+		}
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: glyph\r\n\r\n")); err != nil {
+		t.Fatalf("cannot send the request: %v", err)
+	}
+	<-started
+	_ = conn.Close()
+
+	if err := <-ended; err != context.Canceled {
+		t.Fatalf("expected the request context to be canceled, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go-glyph/internal/core/logging"
+)
+
+// RequestIDHeader is the header of the ID of a request, answered in the response
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the request IDs taken over from callers, longer ones are replaced
+const maxRequestIDLength = 64
+
+// RequestID gives every request an ID that its log lines carry, taking over the X-Request-Id of a proxy in front
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = utils.UUIDv4()
+		}
+
+		c.Set(RequestIDHeader, requestID)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), requestID))
+		return c.Next()
+	}
+}
+
+// validRequestID accepts IDs of letters, digits, dashes and underscores only, so they cannot forge log lines
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/tracing"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDHeader is the response header echoing the trace ID of a request
+const TraceIDHeader = "X-Trace-Id"
+
+// Tracing starts a server span per request, continuing the trace of a traceparent header, and answers its trace ID
+// in the X-Trace-Id header. Spans are named after the registered route, so match IDs stay attributes.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		own := c.Route()
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestCarrier{c})
+		ctx, span := tracing.Tracer().Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("http.request.method", c.Method()), attribute.String("url.path", c.Path())))
+		defer span.End()
+
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			c.Set(TraceIDHeader, traceID)
+		}
+		c.SetUserContext(ctx)
+
+		// Errors are already answered by HandleErrors, they are recorded unless they are the client's fault
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= fiber.StatusInternalServerError {
+			if requestErr := RequestError(c); requestErr != nil {
+				span.RecordError(requestErr)
+			}
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+		if c.Route() != own {
+			span.SetName(c.Method() + " " + c.Route().Path)
+			span.SetAttributes(attribute.String("http.route", c.Route().Path))
+		}
+		return err
+	}
+}
+
+// requestCarrier reads the trace context propagated in the request headers
+type requestCarrier struct {
+	c *fiber.Ctx
+}
+
+func (r requestCarrier) Get(key string) string {
+	return r.c.Get(key)
+}
+
+// Set is not used, trace context is only extracted from requests
+func (r requestCarrier) Set(string, string) {}
+
+func (r requestCarrier) Keys() []string {
+	keys := make([]string, 0)
+	for key := range r.c.GetReqHeaders() {
+		keys = append(keys, key)
+	}
+	return keys
+}
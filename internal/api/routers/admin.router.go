@@ -0,0 +1,16 @@
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/api/controllers"
+	"go-glyph/internal/api/middleware"
+)
+
+func NewAdminRouter(c *controllers.AdminController, token string) func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Use(middleware.AdminAuth(token))
+		router.Get("/steam/accounts", c.GetSteamAccounts)
+		router.Get("/steam/circuit", c.GetSteamCircuit)
+		router.Post("/steam/accounts/:username/guard-code", c.SubmitSteamGuardCode)
+	}
+}
@@ -0,0 +1,24 @@
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/api/controllers"
+	"go-glyph/internal/api/middleware"
+)
+
+// NewProbeRouter serves the probes at the root, where load balancers expect them.
+// It is set up before the request middlewares, so the probes still answer while the server shuts down.
+func NewProbeRouter(c *controllers.HealthController) func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Get("/healthz", c.GetLiveness)
+		router.Get("/readyz", c.GetReadiness)
+	}
+}
+
+// NewHealthRouter serves the status of the dependencies under /api. Its errors can name hosts and paths,
+// so it takes the admin token.
+func NewHealthRouter(c *controllers.HealthController, token string) func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Get("/status", middleware.AdminAuth(token), c.GetStatus)
+	}
+}
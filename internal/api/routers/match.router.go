@@ -0,0 +1,15 @@
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/api/controllers"
+)
+
+func NewMatchRouter(c *controllers.MatchController) func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Get("/:matchID", c.GetMatch)
+		router.Get("/:matchID/players", c.GetPlayers)
+		router.Get("/:matchID/timeline", c.GetTimeline)
+		router.Get("/:matchID/wards", c.GetWards)
+	}
+}
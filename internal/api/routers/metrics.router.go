@@ -0,0 +1,15 @@
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go-glyph/internal/core/metrics"
+)
+
+// NewMetricsRouter serves the Prometheus metrics at the root, where scrapers expect them
+func NewMetricsRouter() func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+	}
+}
@@ -0,0 +1,12 @@
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/api/controllers"
+)
+
+func NewPlayerRouter(c *controllers.PlayerController) func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Get("/:steamID/wards/heatmap", c.GetWardHeatmap)
+	}
+}
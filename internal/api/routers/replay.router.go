@@ -0,0 +1,13 @@
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/api/controllers"
+)
+
+func NewReplayRouter(c *controllers.ReplayController) func(router fiber.Router) {
+	return func(router fiber.Router) {
+		router.Post("/", c.UploadReplay)
+		router.Get("/downloads", c.GetDownloadStats)
+	}
+}
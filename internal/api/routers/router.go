@@ -7,7 +7,13 @@ import (
 )
 
 func SetupRoutes(app *fiber.App,
-	glyphRouter func(router fiber.Router)) {
+	glyphRouter func(router fiber.Router),
+	matchRouter func(router fiber.Router),
+	playerRouter func(router fiber.Router),
+	replayRouter func(router fiber.Router),
+	adminRouter func(router fiber.Router),
+	healthRouter func(router fiber.Router),
+	metricsRouter func(router fiber.Router)) {
 
 	api := app.Group("/api")
 
@@ -20,4 +26,11 @@ func SetupRoutes(app *fiber.App,
 	})
 
 	api.Route("/glyph", glyphRouter)
+	api.Route("/matches", matchRouter)
+	api.Route("/players", playerRouter)
+	api.Route("/replays", replayRouter)
+	api.Route("/admin", adminRouter)
+
+	healthRouter(api)
+	metricsRouter(app)
 }
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Command is a subcommand of the go-glyph binary
+type Command struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+// Commands are the subcommands of the binary, serve is run when none is given
+var Commands = []Command{
+	{Name: "serve", Description: "Run the REST API server", Run: Serve},
+	{Name: "parse", Description: "Parse glyphs from a local .dem file without database, Steam or HTTP server", Run: Parse},
+	{Name: "migrate", Description: "Apply database migrations and exit", Run: Migrate},
+	{Name: "sessions", Description: "List or revoke the stored Steam sessions of the accounts", Run: Sessions},
+}
+
+const defaultCommand = "serve"
+
+// Run executes the subcommand named by the first argument
+func Run(args []string) error {
+	name := defaultCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage(os.Stdout)
+		return nil
+	}
+
+	for _, command := range Commands {
+		if command.Name == name {
+			if err := command.Run(args); !errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return nil
+		}
+	}
+
+	usage(os.Stderr)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func usage(w io.Writer) {
+	commands := make([]Command, len(Commands))
+	copy(commands, Commands)
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	_, _ = fmt.Fprintln(w, "Usage: go-glyph <command> [flags]")
+	_, _ = fmt.Fprintln(w, "\nCommands:")
+	for _, command := range commands {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", command.Name, command.Description)
+	}
+	_, _ = fmt.Fprintf(w, "\nRun 'go-glyph <command> -h' for the flags of a command, %s is run by default.\n", defaultCommand)
+}
+
+// parseFlags parses flags given before and after positional arguments and returns the positional ones
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
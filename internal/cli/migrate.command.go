@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"go-glyph/configuration"
+	"go-glyph/internal/data/database"
+	"log"
+	"log/slog"
+)
+
+// Migrate applies database migrations and exits
+func Migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	envFile := fs.String("env", ".env", "Environment file, environment variables are used if it does not exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := configuration.LoadConfig(*envFile); err != nil {
+		return fmt.Errorf("failed to load environment variables: %w", err)
+	}
+
+	// Migrations are applied on connect
+	db := database.ConnectDB(&configuration.EnvConfig, slog.Default())
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database pool: %w", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close database pool: %w", err)
+	}
+
+	log.Println("Migrations applied")
+	return nil
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go-glyph/internal/core/extractors"
+	"go-glyph/internal/core/services"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatTable = "table"
+)
+
+// Parse runs the extractors over a local demo and prints the glyphs
+func Parse(args []string) error {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	format := fs.String("format", formatTable, "Output format: json, csv or table")
+	extractorsFlag := fs.String("extractors", "",
+		"Comma separated extractors to run ("+strings.Join(extractors.Names(), ", ")+"), all if empty. "+
+			"Only the json format prints data besides glyphs")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: go-glyph parse path/to/1234.dem [flags]")
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		fs.Usage()
+		return errors.New("exactly one demo file is expected")
+	}
+
+	var write func(w io.Writer, result extractors.Result) error
+	switch *format {
+	case formatJSON:
+		write = writeJSON
+	case formatCSV:
+		write = writeCSV
+	case formatTable:
+		write = writeTable
+	default:
+		return fmt.Errorf("unknown format %q, expected json, csv or table", *format)
+	}
+
+	var extractorNames []string
+	if *extractorsFlag != "" {
+		extractorNames = strings.Split(*extractorsFlag, ",")
+	}
+
+	result, err := services.NewMantaService(nil).ParseFile(context.Background(), paths[0], extractorNames)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := write(w, result); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func writeJSON(w io.Writer, result extractors.Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func writeCSV(w io.Writer, result extractors.Result) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"match_id", "minute", "second", "team", "hero_id", "username", "user_steam_id"})
+	for _, glyph := range result.Glyphs {
+		_ = writer.Write([]string{
+			strconv.Itoa(glyph.MatchID),
+			strconv.FormatUint(uint64(glyph.Minute), 10),
+			strconv.FormatUint(uint64(glyph.Second), 10),
+			strconv.FormatUint(glyph.Team, 10),
+			strconv.FormatUint(uint64(glyph.HeroID), 10),
+			glyph.Username,
+			glyph.UserSteamID,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeTable(w io.Writer, result extractors.Result) error {
+	_, _ = fmt.Fprintf(w, "Match %d, %d glyphs\n\n", result.Match.MatchID, len(result.Glyphs))
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "TIME\tTEAM\tHERO\tUSERNAME\tSTEAM ID")
+	for _, glyph := range result.Glyphs {
+		_, _ = fmt.Fprintf(writer, "%02d:%02d\t%s\t%d\t%s\t%s\n",
+			glyph.Minute, glyph.Second, teamName(glyph.Team), glyph.HeroID, glyph.Username, glyph.UserSteamID)
+	}
+	return writer.Flush()
+}
+
+// teamName names a team number, Radiant team is 2 and dire team is 3
+func teamName(team uint64) string {
+	switch team {
+	case 2:
+		return "Radiant"
+	case 3:
+		return "Dire"
+	default:
+		return strconv.FormatUint(team, 10)
+	}
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"go-glyph/configuration"
+	"go-glyph/internal/api/app"
+)
+
+// Serve runs the REST API server
+func Serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	envFile := fs.String("env", ".env", "Environment file, environment variables are used if it does not exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := configuration.LoadConfig(*envFile); err != nil {
+		return fmt.Errorf("failed to load environment variables: %w", err)
+	}
+
+	app.Run(&configuration.EnvConfig)
+	return nil
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go-glyph/configuration"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/services"
+	"go-glyph/internal/data/database"
+	"go-glyph/internal/data/repository"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Sessions lists and revokes the stored refresh tokens of Steam accounts
+func Sessions(args []string) error {
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	envFile := fs.String("env", ".env", "Environment file, environment variables are used if it does not exist")
+	all := fs.Bool("all", false, "Revoke the sessions of every account")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: go-glyph sessions list [flags]")
+		_, _ = fmt.Fprintln(fs.Output(), "       go-glyph sessions revoke <username>... | --all [flags]")
+		_, _ = fmt.Fprintln(fs.Output(), "\nRevoked accounts log in with their password on the next start.")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errors.New("expected list or revoke")
+	}
+	action, usernames := positional[0], positional[1:]
+
+	switch {
+	case action == "list" && len(usernames) == 0 && !*all:
+	case action == "revoke" && (len(usernames) > 0) != *all:
+	default:
+		fs.Usage()
+		return fmt.Errorf("invalid arguments for sessions %s", action)
+	}
+
+	if err := configuration.LoadConfig(*envFile); err != nil {
+		return fmt.Errorf("failed to load environment variables: %w", err)
+	}
+	db := database.ConnectDB(&configuration.EnvConfig, slog.Default())
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	// The key is not needed to list or delete sessions
+	sessionService := services.NewSteamSessionService(repository.NewSteamSessionRepository(db), "", slog.Default())
+
+	if action == "list" {
+		sessions, err := sessionService.GetSessions()
+		if err != nil {
+			return err
+		}
+		return writeSessions(os.Stdout, sessions)
+	}
+
+	revoked, err := sessionService.RevokeSessions(usernames...)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Revoked %d stored sessions\n", revoked)
+	return nil
+}
+
+func writeSessions(w io.Writer, sessions []dtos.SteamSession) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "USERNAME\tEXPIRES\tCREATED\tUPDATED")
+	for _, session := range sessions {
+		expires := "unknown"
+		if !session.ExpiresAt.IsZero() {
+			expires = session.ExpiresAt.Format(time.DateTime)
+		}
+		if session.Expired {
+			expires += " (expired)"
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", session.Username, expires,
+			session.CreatedAt.Format(time.DateTime), session.UpdatedAt.Format(time.DateTime))
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,9 @@
+package dtos
+
+// DownloadStats describes the replay downloads from one target (a Valve cluster or the mirror)
+type DownloadStats struct {
+	Target         string
+	Active         int
+	Bytes          int64
+	BytesPerSecond float64
+}
@@ -12,16 +12,7 @@ type GetGlyphs struct {
 	MatchID int `validate:"required"`
 }
 
-type CreateGlyphs struct {
-	Glyphs []models.Glyph
-}
-
 type GlyphParse struct {
 	GlyphParsed bool
 	Glyphs      []models.Glyph
 }
-
-type HeroPlayer struct {
-	HeroID   uint32
-	PlayerID uint64
-}
@@ -0,0 +1,19 @@
+package dtos
+
+import "time"
+
+// DependencyStatus describes one dependency the server needs to process matches
+type DependencyStatus struct {
+	Name        string // "database", "steam", "demos" or "disk"
+	Ready       bool
+	Detail      string `json:",omitempty"`
+	CheckedAt   time.Time
+	LastError   string     `json:",omitempty"` // Most recent failure, kept once the dependency recovered
+	LastErrorAt *time.Time `json:",omitempty"`
+}
+
+// HealthStatus describes whether the server can process matches, ready if every dependency is
+type HealthStatus struct {
+	Ready        bool
+	Dependencies []DependencyStatus
+}
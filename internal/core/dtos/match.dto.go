@@ -0,0 +1,55 @@
+package dtos
+
+import "go-glyph/internal/core/models"
+
+type GetMatch struct {
+	MatchID int `validate:"required"`
+}
+
+type CreateMatch struct {
+	Match  models.Match
+	Glyphs []models.Glyph
+}
+
+type MatchParse struct {
+	MatchParsed bool
+	Match       models.Match
+}
+
+type MatchPlayers struct {
+	MatchID  int
+	GameMode int32
+	Players  []models.MatchPlayer
+	Draft    []models.DraftPick
+}
+
+type TimelineEvent struct {
+	Type        string // "glyph" or one of the objective types
+	Team        uint64
+	Username    string
+	UserSteamID string
+	HeroID      uint32
+	Minute      uint32
+	Second      uint32
+}
+
+type GetWardHeatmap struct {
+	UserSteamID string `validate:"required,numeric"`
+	Type        string `validate:"omitempty,oneof=observer sentry"`
+	CellSize    int    `validate:"min=64,max=4096"`
+}
+
+type WardHeatmap struct {
+	UserSteamID string
+	Type        string
+	CellSize    int
+	Matches     int
+	Wards       int
+	Cells       []HeatmapCell
+}
+
+type HeatmapCell struct {
+	X     float32 // World coordinates of the lower left corner of the cell
+	Y     float32
+	Count int
+}
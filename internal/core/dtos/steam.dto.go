@@ -0,0 +1,40 @@
+package dtos
+
+import "time"
+
+// SteamAccountHealth describes one account of the Steam client pool
+type SteamAccountHealth struct {
+	Username  string
+	Priority  int
+	State     string // "connecting", "awaiting_guard_code", "ready" or "quarantined"
+	Since     time.Time
+	InFlight  int
+	Requests  int64
+	Failures  int // Consecutive failures since the account was last ready
+	LastError string
+	RetryAt   *time.Time `json:",omitempty"` // When a quarantined account reconnects
+}
+
+// SteamSession describes a stored refresh token of an account, without the token
+type SteamSession struct {
+	Username  string
+	ExpiresAt time.Time // Zero if unknown
+	Expired   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SteamGuardCode is a Steam Guard code sent to the email of an account
+type SteamGuardCode struct {
+	Code string
+}
+
+// SteamCircuit describes the circuit breaker of the GC path
+type SteamCircuit struct {
+	State       string // "closed", "open" or "half_open"
+	Since       time.Time
+	Failures    int              // Consecutive failed lookups while closed
+	Rejected    int64            // Lookups failed fast while open
+	Transitions map[string]int64 // Times the circuit entered each state
+	RetryAt     *time.Time       `json:",omitempty"` // When an open circuit lets a probe through
+}
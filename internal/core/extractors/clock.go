@@ -0,0 +1,65 @@
+package extractors
+
+import (
+	"math"
+
+	"github.com/dotabuff/manta"
+)
+
+// GameClock follows the game clock of CDOTAGamerulesProxy with pauses excluded
+type GameClock struct {
+	startTime   float64
+	currentTime float64
+	endTime     float64
+}
+
+func (c *GameClock) update(e *manta.Entity, netTick uint32) {
+	c.startTime = float64(e.Get("m_pGameRules.m_flGameStartTime").(float32))
+	gamePaused := e.Get("m_pGameRules.m_bGamePaused").(bool)
+	pauseStartTick := e.Get("m_pGameRules.m_nPauseStartTick").(int32)
+	totalPausedTicks := e.Get("m_pGameRules.m_nTotalPausedTicks").(int32)
+	if gamePaused {
+		c.currentTime = float64((pauseStartTick - totalPausedTicks) / 30)
+	} else {
+		c.currentTime = float64((int32(netTick) - totalPausedTicks) / 30)
+	}
+	if endTime, ok := e.GetFloat32("m_pGameRules.m_flGameEndTime"); ok && endTime > 0 {
+		c.endTime = float64(endTime)
+	}
+}
+
+// Now returns a monotonic time in seconds that is only useful for measuring intervals
+func (c *GameClock) Now() float64 {
+	return c.currentTime
+}
+
+// GameTime returns seconds since the horn, negative before it
+func (c *GameClock) GameTime() float64 {
+	return c.currentTime - c.startTime
+}
+
+// MinuteSecond returns the current minute and second of the game clock for events after the horn, 0:00 before it
+func (c *GameClock) MinuteSecond() (uint32, uint32) {
+	minute, second := splitGameTime(math.Max(c.GameTime(), 0))
+	return uint32(minute), uint32(second)
+}
+
+// SignedMinuteSecond returns the current minute and second of the game clock, both negative before the horn
+func (c *GameClock) SignedMinuteSecond() (int32, int32) {
+	return splitGameTime(c.GameTime())
+}
+
+// Duration returns seconds from the horn to the end of the game, or to the last seen tick if the game has not ended
+func (c *GameClock) Duration() float64 {
+	if c.endTime > 0 {
+		return c.endTime - c.startTime
+	}
+	return c.GameTime()
+}
+
+// splitGameTime converts game time in seconds to minute and second of the game clock.
+// Both have the sign of the game time, so -0:45 is 0 and -45 and times still sort by minute, then second.
+func splitGameTime(seconds float64) (int32, int32) {
+	total := int32(math.Round(seconds))
+	return total / 60, total % 60
+}
@@ -0,0 +1,35 @@
+package extractors
+
+import "testing"
+
+func TestSplitGameTime(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		minute  int32
+		second  int32
+	}{
+		{seconds: 0, minute: 0, second: 0},
+		{seconds: 125.2, minute: 2, second: 5},
+		{seconds: 59.6, minute: 1, second: 0},
+		// Before the horn the clock counts down from -1:30
+		{seconds: -45, minute: 0, second: -45},
+		{seconds: -89.7, minute: -1, second: -30},
+	}
+	for _, tt := range tests {
+		minute, second := splitGameTime(tt.seconds)
+		if minute != tt.minute || second != tt.second {
+			t.Errorf("%v seconds: expected %d:%d, got %d:%d", tt.seconds, tt.minute, tt.second, minute, second)
+		}
+	}
+}
+
+func TestGameClockBeforeHorn(t *testing.T) {
+	clock := &GameClock{startTime: 100, currentTime: 55}
+
+	if minute, second := clock.SignedMinuteSecond(); minute != 0 || second != -45 {
+		t.Errorf("expected a ward placed at -0:45, got %d:%d", minute, second)
+	}
+	if minute, second := clock.MinuteSecond(); minute != 0 || second != 0 {
+		t.Errorf("expected 0:00 for events after the horn, got %d:%d", minute, second)
+	}
+}
@@ -0,0 +1,619 @@
+package extractors_test
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dotabuff/manta/dota"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/proto"
+)
+
+// demoMagic starts every Source 2 demo, it is followed by the offset of CDemoFileInfo and 4 unused bytes
+var demoMagic = []byte("PBDEMS2\x00")
+
+// Outer messages larger than this are snappy compressed, like the game does for packets
+const compressThreshold = 64
+
+// sendField is a networked property, fields of a table type hold the fields of that table
+type sendField struct {
+	name  string
+	typ   string
+	table string
+}
+
+// sendTable is a serializer, classes use the serializer with their own name
+type sendTable struct {
+	name   string
+	fields []sendField
+}
+
+// entityOp creates, updates or deletes the entity at index
+type entityOp struct {
+	index  int32
+	class  string
+	serial int32
+	create bool
+	delete bool
+	props  map[string]any
+}
+
+// innerMessage is a net, svc or user message sent in a packet
+type innerMessage struct {
+	typ int32
+	msg proto.Message
+}
+
+type stringTableEntry struct {
+	key   string
+	value []byte
+}
+
+// demoWriter writes a demo in the format manta reads. It supports what the fixture demos need:
+// flattened send tables, string tables without key history, entity creates, updates and deletes
+// and messages in packets.
+type demoWriter struct {
+	t    testing.TB
+	body bytes.Buffer
+	// fileInfoOffset is the offset of CDemoFileInfo from the start of the demo
+	fileInfoOffset int
+
+	tables      map[string]*sendTable
+	classIDs    map[string]int
+	classIDSize uint32
+}
+
+func newDemoWriter(t testing.TB, tables []sendTable, classes []string) *demoWriter {
+	w := &demoWriter{
+		t:        t,
+		tables:   make(map[string]*sendTable, len(tables)),
+		classIDs: make(map[string]int, len(classes)),
+		// Same as manta derives it from svc_ServerInfo
+		classIDSize: uint32(math.Log(float64(len(classes)))/math.Log(2)) + 1,
+	}
+	for i := range tables {
+		w.tables[tables[i].name] = &tables[i]
+	}
+	for id, class := range classes {
+		if _, ok := w.tables[class]; !ok {
+			t.Fatalf("class %s has no send table", class)
+		}
+		w.classIDs[class] = id
+	}
+	return w
+}
+
+// bytes returns the demo written so far
+func (w *demoWriter) bytes() []byte {
+	header := make([]byte, 0, len(demoMagic)+8)
+	header = append(header, demoMagic...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.fileInfoOffset))
+	header = append(header, 0, 0, 0, 0)
+	return append(header, w.body.Bytes()...)
+}
+
+// outer writes a demo command, ticks before the first packet are written as -1 like the game does
+func (w *demoWriter) outer(command dota.EDemoCommands, tick uint32, msg proto.Message) {
+	data := w.marshal(msg)
+	if len(data) > compressThreshold {
+		data = snappy.Encode(nil, data)
+		command |= dota.EDemoCommands_DEM_IsCompressed
+	}
+	if command&^dota.EDemoCommands_DEM_IsCompressed == dota.EDemoCommands_DEM_FileInfo {
+		w.fileInfoOffset = len(demoMagic) + 8 + w.body.Len()
+	}
+
+	w.body.Write(binary.AppendUvarint(nil, uint64(command)))
+	w.body.Write(binary.AppendUvarint(nil, uint64(tick)))
+	w.body.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	w.body.Write(data)
+}
+
+// packet writes the messages in a CDemoPacket, or CDemoSignonPacket during signon
+func (w *demoWriter) packet(command dota.EDemoCommands, tick uint32, msgs ...innerMessage) {
+	var b bitWriter
+	for _, m := range msgs {
+		data := w.marshal(m.msg)
+		b.writeUBitVar(uint32(m.typ))
+		b.writeVarUint(uint64(len(data)))
+		b.writeBytes(data)
+	}
+	w.outer(command, tick, &dota.CDemoPacket{Data: b.buf})
+}
+
+func (w *demoWriter) marshal(msg proto.Message) []byte {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		w.t.Fatalf("cannot marshal %T: %v", msg, err)
+	}
+	return data
+}
+
+// sendTables writes the send tables as a flattened serializer, tables must come after the tables they use
+func (w *demoWriter) sendTables(tables []sendTable) {
+	msg := &dota.CSVCMsg_FlattenedSerializer{}
+	symbols := make(map[string]int32)
+	symbol := func(s string) *int32 {
+		if _, ok := symbols[s]; !ok {
+			symbols[s] = int32(len(msg.Symbols))
+			msg.Symbols = append(msg.Symbols, s)
+		}
+		return proto.Int32(symbols[s])
+	}
+
+	for _, table := range tables {
+		serializer := &dota.ProtoFlattenedSerializerT{
+			SerializerNameSym: symbol(table.name),
+			SerializerVersion: proto.Int32(0),
+		}
+		for _, f := range table.fields {
+			field := &dota.ProtoFlattenedSerializerFieldT{
+				VarNameSym:  symbol(f.name),
+				VarTypeSym:  symbol(f.typ),
+				SendNodeSym: symbol("(root)"),
+			}
+			if f.table != "" {
+				field.FieldSerializerNameSym = symbol(f.table)
+				field.FieldSerializerVersion = proto.Int32(0)
+			}
+			serializer.FieldsIndex = append(serializer.FieldsIndex, int32(len(msg.Fields)))
+			msg.Fields = append(msg.Fields, field)
+		}
+		msg.Serializers = append(msg.Serializers, serializer)
+	}
+
+	data := w.marshal(msg)
+	w.outer(dota.EDemoCommands_DEM_SendTables, math.MaxUint32,
+		&dota.CDemoSendTables{Data: append(binary.AppendUvarint(nil, uint64(len(data))), data...)})
+}
+
+// classInfo writes the class IDs of all classes
+func (w *demoWriter) classInfo(classes []string) {
+	msg := &dota.CDemoClassInfo{}
+	for _, class := range classes {
+		msg.Classes = append(msg.Classes, &dota.CDemoClassInfoClassT{
+			ClassId:     proto.Int32(int32(w.classIDs[class])),
+			NetworkName: proto.String(class),
+		})
+	}
+	w.outer(dota.EDemoCommands_DEM_ClassInfo, math.MaxUint32, msg)
+}
+
+// createStringTable returns the message creating a string table with keys and values of any size
+func createStringTable(name string, entries []stringTableEntry) innerMessage {
+	var b bitWriter
+	for _, entry := range entries {
+		// Incremented index, key without history
+		b.writeBool(true)
+		b.writeBool(true)
+		b.writeBool(false)
+		b.writeString(entry.key)
+		b.writeBool(entry.value != nil)
+		if entry.value != nil {
+			b.writeBits(uint64(len(entry.value)), 17)
+			b.writeBytes(entry.value)
+		}
+	}
+	return innerMessage{typ: int32(dota.SVC_Messages_svc_CreateStringTable), msg: &dota.CSVCMsg_CreateStringTable{
+		Name:              proto.String(name),
+		NumEntries:        proto.Int32(int32(len(entries))),
+		UserDataFixedSize: proto.Bool(false),
+		Flags:             proto.Int32(0),
+		StringData:        b.buf,
+	}}
+}
+
+// instanceBaseline returns the baseline string table, every class starts with the given properties
+func (w *demoWriter) instanceBaseline(baselines map[string]map[string]any) innerMessage {
+	classes := make([]string, 0, len(w.classIDs))
+	for class := range w.classIDs {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool { return w.classIDs[classes[i]] < w.classIDs[classes[j]] })
+
+	entries := make([]stringTableEntry, 0, len(classes))
+	for _, class := range classes {
+		var b bitWriter
+		w.writeFields(&b, class, baselines[class])
+		entries = append(entries, stringTableEntry{key: strconv.Itoa(w.classIDs[class]), value: b.buf})
+	}
+	return createStringTable("instancebaseline", entries)
+}
+
+// packetEntities returns the message applying the entity operations, at most one per entity
+func (w *demoWriter) packetEntities(ops []entityOp) innerMessage {
+	sort.Slice(ops, func(i, j int) bool { return ops[i].index < ops[j].index })
+
+	var b bitWriter
+	index := int32(-1)
+	for _, op := range ops {
+		b.writeUBitVar(uint32(op.index - index - 1))
+		index = op.index
+
+		switch {
+		case op.create:
+			b.writeBits(2, 2)
+			b.writeBits(uint64(w.classIDs[op.class]), w.classIDSize)
+			b.writeBits(uint64(op.serial), 17)
+			b.writeVarUint(0)
+			w.writeFields(&b, op.class, op.props)
+		case op.delete:
+			// Leave and delete
+			b.writeBits(3, 2)
+		default:
+			b.writeBits(0, 2)
+			w.writeFields(&b, op.class, op.props)
+		}
+	}
+
+	return innerMessage{typ: int32(dota.SVC_Messages_svc_PacketEntities), msg: &dota.CSVCMsg_PacketEntities{
+		MaxEntries:     proto.Int32(2048),
+		UpdatedEntries: proto.Int32(int32(len(ops))),
+		LegacyIsDelta:  proto.Bool(true),
+		EntityData:     b.buf,
+	}}
+}
+
+// fieldValue is a property resolved to its field path
+type fieldValue struct {
+	name     string
+	path     []int
+	encoding valueEncoding
+	value    any
+}
+
+// writeFields writes the field paths of the properties followed by their values
+func (w *demoWriter) writeFields(b *bitWriter, class string, props map[string]any) {
+	values := make([]fieldValue, 0, len(props))
+	for name, value := range props {
+		path, encoding := w.resolve(class, name)
+		values = append(values, fieldValue{name: name, path: path, encoding: encoding, value: value})
+	}
+	sort.Slice(values, func(i, j int) bool { return comparePaths(values[i].path, values[j].path) < 0 })
+
+	current := []int{-1}
+	for _, v := range values {
+		writeFieldPathOp(b, current, v.path)
+		current = v.path
+	}
+	b.writeFieldPathOp(opFieldPathEncodeFinish)
+
+	for _, v := range values {
+		if err := b.writeValue(v.encoding, v.value); err != nil {
+			w.t.Fatalf("cannot write %s.%s: %v", class, v.name, err)
+		}
+	}
+}
+
+// resolve returns the field path of a property name like m_vecPlayerData.0003.m_iszPlayerName
+// and how its value is encoded
+func (w *demoWriter) resolve(class, name string) ([]int, valueEncoding) {
+	table := w.tables[class]
+	parts := strings.Split(name, ".")
+	var path []int
+	for {
+		i := table.fieldIndex(parts[0])
+		if i < 0 {
+			w.t.Fatalf("%s has no property %s", class, name)
+		}
+		field := table.fields[i]
+		path = append(path, i)
+		parts = parts[1:]
+
+		if len(parts) == 0 {
+			switch {
+			case field.table != "" && field.isFixedTable():
+				return path, encodeBool
+			case field.table != "" || field.isArray():
+				// Length of the vector
+				return path, encodeVarUint
+			}
+			return path, encodingOf(field.typ)
+		}
+
+		if field.table != "" && field.isFixedTable() {
+			table = w.tables[field.table]
+			continue
+		}
+
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts[0]) != 4 {
+			w.t.Fatalf("%s of %s is not an index", parts[0], name)
+		}
+		path = append(path, index)
+		parts = parts[1:]
+
+		if field.table == "" {
+			if len(parts) != 0 || !field.isArray() {
+				w.t.Fatalf("%s of %s is not an array", field.name, name)
+			}
+			return path, encodingOf(field.typ)
+		}
+		if len(parts) == 0 {
+			w.t.Fatalf("%s names an element of a table, not a property", name)
+		}
+		table = w.tables[field.table]
+	}
+}
+
+func (s *sendTable) fieldIndex(name string) int {
+	for i, field := range s.fields {
+		if field.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// isFixedTable tells whether the nested table is embedded, otherwise it is a vector of tables
+func (f sendField) isFixedTable() bool {
+	return strings.HasSuffix(f.typ, "*") || f.typ == "CBodyComponent"
+}
+
+func (f sendField) isArray() bool {
+	return strings.HasPrefix(f.typ, "CUtlVector<") || (strings.HasSuffix(f.typ, "]") && !strings.HasPrefix(f.typ, "char["))
+}
+
+func comparePaths(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
+// writeFieldPathOp moves the field path from current to next with a single operation, as manta
+// emits a field path after every operation
+func writeFieldPathOp(b *bitWriter, current, next []int) {
+	if len(next) >= len(current) {
+		b.writeFieldPathOp(opPushNAndNonTopological)
+		for i := range current {
+			// The delta of a component is written minus one
+			delta := next[i] - current[i]
+			b.writeBool(delta != 0)
+			if delta != 0 {
+				b.writeVarInt(int64(delta - 1))
+			}
+		}
+		b.writeUBitVar(uint32(len(next) - len(current)))
+		for _, component := range next[len(current):] {
+			b.writeUBitVarFieldPath(uint32(component))
+		}
+		return
+	}
+
+	b.writeFieldPathOp(opPopNAndNonTopographical)
+	b.writeUBitVarFieldPath(uint32(len(current) - len(next)))
+	for i := range next {
+		// Popped components are reset to zero
+		delta := next[i] - current[i]
+		b.writeBool(delta != 0)
+		if delta != 0 {
+			b.writeVarInt(int64(delta))
+		}
+	}
+}
+
+type valueEncoding int
+
+const (
+	encodeVarInt valueEncoding = iota
+	encodeVarUint
+	encodeVarUint64
+	encodeBool
+	encodeString
+	encodeFloat
+)
+
+// encodingOf returns how manta decodes a property of the networked type, or the element type of an array
+func encodingOf(typ string) valueEncoding {
+	base := typ
+	if i := strings.IndexAny(typ, "<[*"); i >= 0 {
+		base = strings.TrimSpace(typ[:i])
+	}
+	switch base {
+	case "bool":
+		return encodeBool
+	case "uint8", "uint16", "uint32", "CHandle":
+		return encodeVarUint
+	case "uint64":
+		return encodeVarUint64
+	case "char", "CUtlString", "CUtlSymbolLarge":
+		return encodeString
+	case "float32", "GameTime_t":
+		return encodeFloat
+	}
+	return encodeVarInt
+}
+
+// bitWriter writes bits least significant first, in the order manta reads them
+type bitWriter struct {
+	buf   []byte
+	nbits uint
+}
+
+func (b *bitWriter) writeBits(v uint64, n uint32) {
+	for i := uint32(0); i < n; i++ {
+		if b.nbits%8 == 0 {
+			b.buf = append(b.buf, 0)
+		}
+		if v>>i&1 == 1 {
+			b.buf[len(b.buf)-1] |= 1 << (b.nbits % 8)
+		}
+		b.nbits++
+	}
+}
+
+func (b *bitWriter) writeBool(v bool) {
+	if v {
+		b.writeBits(1, 1)
+	} else {
+		b.writeBits(0, 1)
+	}
+}
+
+func (b *bitWriter) writeBytes(data []byte) {
+	for _, c := range data {
+		b.writeBits(uint64(c), 8)
+	}
+}
+
+func (b *bitWriter) writeString(s string) {
+	b.writeBytes([]byte(s))
+	b.writeBits(0, 8)
+}
+
+func (b *bitWriter) writeVarUint(v uint64) {
+	b.writeBytes(binary.AppendUvarint(nil, v))
+}
+
+func (b *bitWriter) writeVarInt(v int64) {
+	b.writeBytes(binary.AppendVarint(nil, v))
+}
+
+// writeUBitVar writes 4 bits of the value and 0, 4, 8 or 28 more bits as told by the next 2 bits
+func (b *bitWriter) writeUBitVar(v uint32) {
+	switch {
+	case v < 1<<4:
+		b.writeBits(uint64(v), 6)
+	case v < 1<<8:
+		b.writeBits(uint64(v&15|16), 6)
+		b.writeBits(uint64(v>>4), 4)
+	case v < 1<<12:
+		b.writeBits(uint64(v&15|32), 6)
+		b.writeBits(uint64(v>>4), 8)
+	default:
+		b.writeBits(uint64(v&15|48), 6)
+		b.writeBits(uint64(v>>4), 28)
+	}
+}
+
+// writeUBitVarFieldPath writes a field path component in 2, 4, 10, 17 or 31 bits
+func (b *bitWriter) writeUBitVarFieldPath(v uint32) {
+	for _, bits := range []uint32{2, 4, 10, 17} {
+		if v < 1<<bits {
+			b.writeBool(true)
+			b.writeBits(uint64(v), bits)
+			return
+		}
+		b.writeBool(false)
+	}
+	b.writeBits(uint64(v), 31)
+}
+
+func (b *bitWriter) writeFieldPathOp(op int) {
+	for _, right := range fieldPathOpCodes[op] {
+		b.writeBool(right)
+	}
+}
+
+func (b *bitWriter) writeValue(encoding valueEncoding, value any) error {
+	switch v := value.(type) {
+	case int:
+		switch encoding {
+		case encodeVarInt:
+			b.writeVarInt(int64(v))
+			return nil
+		case encodeVarUint, encodeVarUint64:
+			b.writeVarUint(uint64(v))
+			return nil
+		}
+	case uint64:
+		if encoding == encodeVarUint || encoding == encodeVarUint64 {
+			b.writeVarUint(v)
+			return nil
+		}
+	case bool:
+		if encoding == encodeBool {
+			b.writeBool(v)
+			return nil
+		}
+	case string:
+		if encoding == encodeString {
+			b.writeString(v)
+			return nil
+		}
+	case float64:
+		if encoding == encodeFloat {
+			b.writeBits(uint64(math.Float32bits(float32(v))), 32)
+			return nil
+		}
+	}
+	return fmt.Errorf("%T value does not match the property type", value)
+}
+
+// Field path operations used by the writer, numbered as in manta's table
+const (
+	opPushNAndNonTopological  = 26
+	opPopNAndNonTopographical = 35
+	opFieldPathEncodeFinish   = 39
+)
+
+// fieldPathOpWeights are the weights manta builds the Huffman tree of field path operations from
+var fieldPathOpWeights = []int{
+	36271, 10334, 1375, 646, 4128, 35, 3, 521, 2942, 560, 471, 10530, 251,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	310, 2, 0, 1837, 149, 300, 634, 0, 0, 1, 76, 271, 99, 25474,
+}
+
+// fieldPathOpCodes holds the Huffman code of every field path operation, true is a right branch
+var fieldPathOpCodes = buildFieldPathOpCodes()
+
+type huffmanNode struct {
+	weight, value int
+	left, right   *huffmanNode
+}
+
+// huffmanHeap orders nodes as manta does, so ties are broken the same way
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].weight == h[j].weight {
+		return h[i].value >= h[j].value
+	}
+	return h[i].weight < h[j].weight
+}
+func (h huffmanHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x any)   { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() any {
+	x := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return x
+}
+
+func buildFieldPathOpCodes() [][]bool {
+	nodes := make(huffmanHeap, 0, len(fieldPathOpWeights))
+	for op, weight := range fieldPathOpWeights {
+		nodes = append(nodes, &huffmanNode{weight: max(weight, 1), value: op})
+	}
+
+	// Internal nodes are numbered from 40 like in manta
+	heap.Init(&nodes)
+	for value := 40; nodes.Len() > 1; value++ {
+		a := heap.Pop(&nodes).(*huffmanNode)
+		b := heap.Pop(&nodes).(*huffmanNode)
+		heap.Push(&nodes, &huffmanNode{weight: a.weight + b.weight, value: value, left: a, right: b})
+	}
+
+	codes := make([][]bool, len(fieldPathOpWeights))
+	var walk func(n *huffmanNode, code []bool)
+	walk = func(n *huffmanNode, code []bool) {
+		if n.left == nil {
+			codes[n.value] = append([]bool(nil), code...)
+			return
+		}
+		walk(n.left, append(code, false))
+		walk(n.right, append(code, true))
+	}
+	walk(nodes[0], nil)
+	return codes
+}
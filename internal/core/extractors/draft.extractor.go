@@ -0,0 +1,79 @@
+package extractors
+
+import (
+	"fmt"
+
+	"github.com/dotabuff/manta"
+
+	"go-glyph/internal/core/models"
+)
+
+const captainsModeGameMode = 2
+
+var draftLists = []struct {
+	field  string
+	isPick bool
+}{
+	{field: "m_pGameRules.m_BannedHeroes", isPick: false},
+	{field: "m_pGameRules.m_SelectedHeroes", isPick: true},
+}
+
+// DraftExtractor records Captains Mode picks and bans from the game rules entity
+type DraftExtractor struct {
+	draft   []models.DraftPick
+	drafted map[string]bool
+}
+
+func (x *DraftExtractor) Register(ctx *Context) {
+	x.drafted = make(map[string]bool)
+	ctx.Parser.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
+		if e.GetClassName() == "CDOTAGamerulesProxy" && ctx.GameMode == captainsModeGameMode {
+			x.appendDraftPicks(e, ctx.MatchID)
+		}
+		return nil
+	})
+}
+
+func (x *DraftExtractor) Finish(ctx *Context, result *Result) {
+	// Team of a pick is the team of the player who ended up with the hero
+	for k := range x.draft {
+		if !x.draft[k].IsPick {
+			continue
+		}
+		if player, ok := ctx.Lineup.FindByHeroID(x.draft[k].HeroID); ok {
+			x.draft[k].Team = player.Team
+		}
+	}
+	result.Match.Draft = x.draft
+}
+
+// appendDraftPicks appends heroes that appeared in the game rules pick and ban lists since the last update.
+// Bans are attributed to the team whose turn it currently is.
+func (x *DraftExtractor) appendDraftPicks(e *manta.Entity, matchID int) {
+	activeTeam, _ := getEntityInt(e, "m_pGameRules.m_iActiveTeam")
+
+	for _, list := range draftLists {
+		for i := 0; ; i++ {
+			heroID, ok := getEntityInt(e, fmt.Sprintf("%s.%04d", list.field, i))
+			if !ok {
+				break
+			}
+			key := fmt.Sprintf("%t/%d", list.isPick, heroID)
+			if heroID <= 0 || x.drafted[key] {
+				continue
+			}
+			x.drafted[key] = true
+
+			pick := models.DraftPick{
+				MatchID: matchID,
+				Order:   uint32(len(x.draft)),
+				IsPick:  list.isPick,
+				HeroID:  uint32(heroID),
+			}
+			if !list.isPick {
+				pick.Team = uint64(activeTeam)
+			}
+			x.draft = append(x.draft, pick)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package extractors
+
+import (
+	"fmt"
+
+	"github.com/dotabuff/manta"
+)
+
+const (
+	// Entity positions are networked as a cell plus an offset inside the cell
+	cellWidth       = 128
+	worldHalfExtent = 16384
+)
+
+// getEntityInt reads an integer property regardless of how it is encoded in the send tables
+func getEntityInt(e *manta.Entity, name string) (int64, bool) {
+	switch v := e.Get(name).(type) {
+	case int32:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// getEntityPosition returns world coordinates of an entity
+func getEntityPosition(e *manta.Entity) (float32, float32) {
+	cellX, _ := getEntityInt(e, "CBodyComponent.m_cellX")
+	cellY, _ := getEntityInt(e, "CBodyComponent.m_cellY")
+	vecX, _ := e.GetFloat32("CBodyComponent.m_vecX")
+	vecY, _ := e.GetFloat32("CBodyComponent.m_vecY")
+	return float32(cellX*cellWidth-worldHalfExtent) + vecX, float32(cellY*cellWidth-worldHalfExtent) + vecY
+}
+
+// vectorField returns the name of a property of an element of a networked vector, like m_vecPlayerData.0003.m_iPlayerTeam
+func vectorField(vector string, i int, field string) string {
+	return fmt.Sprintf("%s.%04d.%s", vector, i, field)
+}
+
+// findEntityByClassName returns an entity of the class, nil if there is none
+func findEntityByClassName(p *manta.Parser, className string) *manta.Entity {
+	entities := p.FilterEntity(func(e *manta.Entity) bool {
+		// Deleted entities are kept as nil
+		return e != nil && e.GetClassName() == className
+	})
+	if len(entities) == 0 {
+		return nil
+	}
+	return entities[0]
+}
@@ -0,0 +1,92 @@
+package extractors
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+
+	"go-glyph/internal/core/models"
+)
+
+// Extractor pulls one kind of data out of a replay. Extractors of a job share a single pass over the demo.
+type Extractor interface {
+	// Register subscribes the extractor to parser callbacks before the pass starts
+	Register(ctx *Context)
+	// Finish writes extracted data into the result after the pass ends
+	Finish(ctx *Context, result *Result)
+}
+
+// Result holds everything extracted from a replay
+type Result struct {
+	Glyphs []models.Glyph
+	Match  models.Match
+	// DemoMatchID is the match ID recorded in CDemoFileInfo, 0 if the demo has none
+	DemoMatchID int
+}
+
+// Context is the state shared by all extractors of a pass.
+// Clock and Lineup are updated before any extractor callback sees an entity.
+type Context struct {
+	MatchID  int
+	Parser   *manta.Parser
+	Clock    *GameClock
+	Lineup   *Lineup
+	GameMode int32
+	// DemoMatchID is set from CDemoFileInfo, which is only sent at the end of the demo
+	DemoMatchID int
+}
+
+func NewContext(p *manta.Parser, matchID int) *Context {
+	ctx := &Context{
+		MatchID: matchID,
+		Parser:  p,
+		Clock:   &GameClock{},
+		Lineup:  newLineup(matchID),
+	}
+
+	p.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
+		switch e.GetClassName() {
+		case "CDOTAGamerulesProxy":
+			ctx.Clock.update(e, p.NetTick)
+			if mode, ok := getEntityInt(e, "m_pGameRules.m_iGameMode"); ok {
+				ctx.GameMode = int32(mode)
+			}
+		case "CDOTA_PlayerResource":
+			ctx.Lineup.update(e)
+		}
+		return nil
+	})
+
+	p.Callbacks.OnCDemoFileInfo(func(m *dota.CDemoFileInfo) error {
+		ctx.DemoMatchID = int(m.GetGameInfo().GetDota().GetMatchId())
+		return nil
+	})
+
+	return ctx
+}
+
+// Run parses the whole demo once with the given extractors and collects their results
+func Run(p *manta.Parser, matchID int, extractors []Extractor) (Result, error) {
+	ctx := NewContext(p, matchID)
+	for _, extractor := range extractors {
+		extractor.Register(ctx)
+	}
+
+	if err := p.Start(); err != nil {
+		return Result{}, err
+	}
+	ctx.Lineup.finish(p)
+
+	result := Result{
+		DemoMatchID: ctx.DemoMatchID,
+		Match: models.Match{
+			MatchID:  matchID,
+			GameMode: ctx.GameMode,
+			Players:  ctx.Lineup.Players(),
+		},
+	}
+	for _, extractor := range extractors {
+		extractor.Finish(ctx, &result)
+	}
+
+	return result, nil
+}
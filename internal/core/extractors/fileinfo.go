@@ -0,0 +1,81 @@
+package extractors
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dotabuff/manta/dota"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/proto"
+)
+
+// demoMagic starts every Source 2 demo, it is followed by the offset of CDemoFileInfo
+var demoMagic = []byte("PBDEMS2\x00")
+
+// maxFileInfoSize guards against allocating a corrupt size, CDemoFileInfo is a few kilobytes at most
+const maxFileInfoSize = 1 << 20
+
+var ErrNoDemoMatchID = errors.New("demo has no match ID in CDemoFileInfo")
+
+// ReadDemoMatchID reads the match ID from CDemoFileInfo without parsing the demo.
+// The header points at CDemoFileInfo, so only a seekable demo can be read this way.
+// The reader is left at an unspecified position.
+func ReadDemoMatchID(r io.ReadSeeker) (int, error) {
+	header := make([]byte, len(demoMagic)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, fmt.Errorf("cannot read demo header: %w", err)
+	}
+	if !bytes.Equal(header[:len(demoMagic)], demoMagic) {
+		return 0, fmt.Errorf("unexpected demo magic %q", header[:len(demoMagic)])
+	}
+
+	offset := binary.LittleEndian.Uint32(header[len(demoMagic):])
+	if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+		return 0, fmt.Errorf("cannot seek to CDemoFileInfo: %w", err)
+	}
+
+	// Same layout as every outer message: command, tick and size varints, then the (snappy compressed) message
+	br := bufio.NewReader(r)
+	command, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read CDemoFileInfo command: %w", err)
+	}
+	if dota.EDemoCommands(command)&^dota.EDemoCommands_DEM_IsCompressed != dota.EDemoCommands_DEM_FileInfo {
+		return 0, fmt.Errorf("unexpected command %d at CDemoFileInfo offset", command)
+	}
+	if _, err := binary.ReadUvarint(br); err != nil {
+		return 0, fmt.Errorf("cannot read CDemoFileInfo tick: %w", err)
+	}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read CDemoFileInfo size: %w", err)
+	}
+	if size > maxFileInfoSize {
+		return 0, fmt.Errorf("CDemoFileInfo size %d exceeds maximum %d", size, maxFileInfoSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return 0, fmt.Errorf("cannot read CDemoFileInfo: %w", err)
+	}
+	if dota.EDemoCommands(command)&dota.EDemoCommands_DEM_IsCompressed != 0 {
+		if data, err = snappy.Decode(nil, data); err != nil {
+			return 0, fmt.Errorf("cannot decompress CDemoFileInfo: %w", err)
+		}
+	}
+
+	fileInfo := &dota.CDemoFileInfo{}
+	if err := proto.Unmarshal(data, fileInfo); err != nil {
+		return 0, fmt.Errorf("cannot decode CDemoFileInfo: %w", err)
+	}
+
+	matchID := int(fileInfo.GetGameInfo().GetDota().GetMatchId())
+	if matchID == 0 {
+		return 0, ErrNoDemoMatchID
+	}
+	return matchID, nil
+}
@@ -0,0 +1,64 @@
+package extractors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/dotabuff/manta/dota"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/proto"
+)
+
+// buildDemo writes a demo with padding in place of packets and CDemoFileInfo at the end
+func buildDemo(t *testing.T, matchID uint64, compressed bool) []byte {
+	t.Helper()
+
+	data, err := proto.Marshal(&dota.CDemoFileInfo{
+		GameInfo: &dota.CGameInfo{Dota: &dota.CGameInfo_CDotaGameInfo{MatchId: proto.Uint64(matchID)}},
+	})
+	if err != nil {
+		t.Fatalf("cannot marshal CDemoFileInfo: %v", err)
+	}
+	command := uint64(dota.EDemoCommands_DEM_FileInfo)
+	if compressed {
+		data = snappy.Encode(nil, data)
+		command |= uint64(dota.EDemoCommands_DEM_IsCompressed)
+	}
+
+	padding := make([]byte, 100)
+	offset := len(demoMagic) + 8 + len(padding)
+
+	var demo bytes.Buffer
+	demo.Write(demoMagic)
+	demo.Write(binary.LittleEndian.AppendUint32(nil, uint32(offset)))
+	demo.Write(make([]byte, 4))
+	demo.Write(padding)
+	demo.Write(binary.AppendUvarint(nil, command))
+	demo.Write(binary.AppendUvarint(nil, 12345))
+	demo.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	demo.Write(data)
+	return demo.Bytes()
+}
+
+func TestReadDemoMatchID(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		matchID, err := ReadDemoMatchID(bytes.NewReader(buildDemo(t, 7890123456, compressed)))
+		if err != nil {
+			t.Fatalf("ReadDemoMatchID returned error (compressed %v): %v", compressed, err)
+		}
+		if matchID != 7890123456 {
+			t.Fatalf("expected match ID 7890123456, got %d (compressed %v)", matchID, compressed)
+		}
+	}
+}
+
+func TestReadDemoMatchIDRejectsInvalidDemos(t *testing.T) {
+	if _, err := ReadDemoMatchID(bytes.NewReader(buildDemo(t, 0, false))); !errors.Is(err, ErrNoDemoMatchID) {
+		t.Fatalf("expected ErrNoDemoMatchID, got %v", err)
+	}
+	if _, err := ReadDemoMatchID(bytes.NewReader([]byte("BZh91AY&SY not a demo"))); err == nil {
+		t.Fatal("expected error for a file without demo magic")
+	}
+}
@@ -0,0 +1,481 @@
+package extractors_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotabuff/manta/dota"
+	"google.golang.org/protobuf/proto"
+)
+
+// syntheticDemo is written by writeSyntheticDemo, TestSyntheticDemo keeps the committed file up to date
+const (
+	syntheticDemo    = "synthetic_7000000001.dem"
+	syntheticMatchID = 7000000001
+	syntheticWinner  = 2
+)
+
+// Entity indexes, player controllers are at the player ID plus one like in the game
+const (
+	gameRulesIndex      = 64
+	playerResourceIndex = 65
+	dataRadiantIndex    = 66
+	dataDireIndex       = 67
+	firstHeroIndex      = 300
+	observerWardIndex   = 500
+	sentryWardIndex     = 501
+
+	heroSerial = 7
+)
+
+// syntheticSendTables holds the properties the extractors read, with the types the game networks them as
+var syntheticSendTables = []sendTable{
+	{name: "CBodyComponentBaseAnimGraph", fields: []sendField{
+		{name: "m_cellX", typ: "uint16"},
+		{name: "m_cellY", typ: "uint16"},
+		{name: "m_vecX", typ: "float32"},
+		{name: "m_vecY", typ: "float32"},
+	}},
+	{name: "CDOTAGamerules", fields: []sendField{
+		{name: "m_fGameTime", typ: "GameTime_t"},
+		{name: "m_flGameStartTime", typ: "GameTime_t"},
+		{name: "m_flGameEndTime", typ: "GameTime_t"},
+		{name: "m_bGamePaused", typ: "bool"},
+		{name: "m_nPauseStartTick", typ: "int32"},
+		{name: "m_nTotalPausedTicks", typ: "int32"},
+		{name: "m_iGameMode", typ: "int32"},
+		{name: "m_iActiveTeam", typ: "int32"},
+		{name: "m_BannedHeroes", typ: "HeroID_t[24]"},
+		{name: "m_SelectedHeroes", typ: "HeroID_t[24]"},
+	}},
+	{name: "PlayerResourcePlayerData_t", fields: []sendField{
+		{name: "m_iPlayerTeam", typ: "int32"},
+		{name: "m_iPlayerSteamID", typ: "uint64"},
+		{name: "m_iszPlayerName", typ: "CUtlSymbolLarge"},
+	}},
+	{name: "PlayerResourcePlayerTeamData_t", fields: []sendField{
+		{name: "m_nSelectedHeroID", typ: "HeroID_t"},
+		{name: "m_hSelectedHero", typ: "CHandle< CDOTA_BaseNPC_Hero >"},
+		{name: "m_iKills", typ: "int32"},
+		{name: "m_iDeaths", typ: "int32"},
+		{name: "m_iAssists", typ: "int32"},
+		{name: "m_iLevel", typ: "int32"},
+		{name: "m_iTeamSlot", typ: "int32"},
+	}},
+	{name: "DataTeamPlayer_t", fields: []sendField{
+		{name: "m_iNetWorth", typ: "int32"},
+		{name: "m_iLastHitCount", typ: "int32"},
+	}},
+	{name: "CDOTAGamerulesProxy", fields: []sendField{
+		{name: "m_pGameRules", typ: "CDOTAGamerules*", table: "CDOTAGamerules"},
+	}},
+	{name: "CDOTA_PlayerResource", fields: []sendField{
+		{name: "m_vecPlayerData", typ: "CUtlVectorEmbeddedNetworkVar< PlayerResourcePlayerData_t >", table: "PlayerResourcePlayerData_t"},
+		{name: "m_vecPlayerTeamData", typ: "CUtlVectorEmbeddedNetworkVar< PlayerResourcePlayerTeamData_t >", table: "PlayerResourcePlayerTeamData_t"},
+	}},
+	{name: "CDOTA_DataRadiant", fields: []sendField{
+		{name: "m_vecDataTeam", typ: "CUtlVectorEmbeddedNetworkVar< DataTeamPlayer_t >", table: "DataTeamPlayer_t"},
+	}},
+	{name: "CDOTA_DataDire", fields: []sendField{
+		{name: "m_vecDataTeam", typ: "CUtlVectorEmbeddedNetworkVar< DataTeamPlayer_t >", table: "DataTeamPlayer_t"},
+	}},
+	{name: "CDOTAPlayerController", fields: []sendField{
+		{name: "m_iszPlayerName", typ: "char[128]"},
+		{name: "m_steamID", typ: "uint64"},
+		{name: "m_iTeamNum", typ: "uint8"},
+	}},
+	{name: "CDOTA_NPC_Observer_Ward", fields: unitFields(sendField{name: "m_hOwnerEntity", typ: "CHandle< CBaseEntity >"})},
+	{name: "CDOTA_NPC_Observer_Ward_TrueSight", fields: unitFields(sendField{name: "m_hOwnerEntity", typ: "CHandle< CBaseEntity >"})},
+}
+
+// unitFields are the properties of a unit on the map
+func unitFields(fields ...sendField) []sendField {
+	return append([]sendField{
+		{name: "CBodyComponent", typ: "CBodyComponent", table: "CBodyComponentBaseAnimGraph"},
+		{name: "m_iTeamNum", typ: "uint8"},
+	}, fields...)
+}
+
+type syntheticPlayer struct {
+	name    string
+	steamID uint64
+	team    int
+	// hero is the hero class without the CDOTA_Unit_Hero_ prefix
+	hero   string
+	heroID int
+
+	kills, deaths, assists, level int
+	netWorth, lastHits            int
+}
+
+var syntheticPlayers = []syntheticPlayer{
+	{"Kestrel", 76561198000000101, 2, "Juggernaut", 8, 9, 2, 7, 25, 24310, 412},
+	{"Moss", 76561198000000102, 2, "Lina", 25, 7, 4, 12, 24, 19870, 298},
+	{"Quill", 76561198000000103, 2, "Axe", 2, 5, 6, 15, 22, 15240, 201},
+	{"Tarn", 76561198000000104, 2, "ShadowShaman", 27, 2, 7, 19, 19, 9120, 58},
+	{"Wren", 76561198000000105, 2, "CrystalMaiden", 5, 1, 8, 21, 18, 7650, 31},
+	{"Ash", 76561198000000106, 3, "Sniper", 35, 6, 5, 4, 24, 21980, 385},
+	{"Brine", 76561198000000107, 3, "Invoker", 74, 8, 6, 5, 23, 18420, 311},
+	{"Cinder", 76561198000000108, 3, "Pudge", 14, 3, 7, 6, 21, 12880, 144},
+	{"Dusk", 76561198000000109, 3, "Lion", 26, 1, 9, 8, 18, 8230, 47},
+	{"Ember", 76561198000000110, 3, "Earthshaker", 7, 2, 7, 9, 19, 9540, 62},
+}
+
+// syntheticDraft is the Captains Mode draft, one step per tick
+var syntheticDraft = []struct {
+	tick       uint32
+	activeTeam int
+	bans       []int
+	picks      []int
+}{
+	{tick: 420, activeTeam: 2, bans: []int{34}},
+	{tick: 480, activeTeam: 3, bans: []int{17}},
+	{tick: 540, activeTeam: 2, picks: []int{8}},
+	{tick: 600, activeTeam: 3, picks: []int{35, 74}},
+	{tick: 660, activeTeam: 2, picks: []int{25, 2}},
+	{tick: 720, activeTeam: 3, bans: []int{10}},
+	{tick: 780, activeTeam: 2, bans: []int{105}},
+	{tick: 840, activeTeam: 3, picks: []int{14, 26}},
+	{tick: 900, activeTeam: 2, picks: []int{27, 5}},
+	{tick: 960, activeTeam: 3, picks: []int{7}},
+}
+
+// Combat log names of the synthetic demo
+var syntheticCombatLogNames = []string{
+	"npc_dota_observer_wards",
+	"npc_dota_sentry_wards",
+	"npc_dota_hero_earthshaker",
+	"npc_dota_hero_juggernaut",
+}
+
+// TestSyntheticDemo checks that the committed synthetic demo is the one written by writeSyntheticDemo.
+// It runs before TestGolden, so -update regenerates the demo before its golden files.
+func TestSyntheticDemo(t *testing.T) {
+	got := writeSyntheticDemo(t)
+	path := filepath.Join(fixtureDemosDir, syntheticDemo)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("cannot write synthetic demo: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read synthetic demo, regenerate it with -update: %v", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("%s differs from the demo written by writeSyntheticDemo, regenerate it with -update", path)
+	}
+}
+
+// writeSyntheticDemo writes a half hour Captains Mode match with pre-horn wards, glyphs, a pause,
+// Roshan and a Tormentor. The horn is at tick 4500 and the pause takes the ticks 12000 to 12900.
+func writeSyntheticDemo(t testing.TB) []byte {
+	classes := []string{"CDOTAGamerulesProxy", "CDOTA_PlayerResource", "CDOTA_DataRadiant", "CDOTA_DataDire",
+		"CDOTAPlayerController", "CDOTA_NPC_Observer_Ward", "CDOTA_NPC_Observer_Ward_TrueSight"}
+	tables := append([]sendTable(nil), syntheticSendTables...)
+	for _, player := range syntheticPlayers {
+		class := "CDOTA_Unit_Hero_" + player.hero
+		classes = append(classes, class)
+		tables = append(tables, sendTable{name: class, fields: unitFields()})
+	}
+
+	w := newDemoWriter(t, tables, classes)
+	w.outer(dota.EDemoCommands_DEM_FileHeader, math.MaxUint32, &dota.CDemoFileHeader{
+		DemoFileStamp: proto.String(string(demoMagic)),
+		ServerName:    proto.String("Valve Dota 2 synthetic fixture"),
+		MapName:       proto.String("dota"),
+		GameDirectory: proto.String("dota"),
+	})
+	w.packet(dota.EDemoCommands_DEM_SignonPacket, math.MaxUint32, innerMessage{
+		typ: int32(dota.SVC_Messages_svc_ServerInfo),
+		msg: &dota.CSVCMsg_ServerInfo{
+			MaxClients:   proto.Int32(64),
+			MaxClasses:   proto.Int32(int32(len(classes))),
+			TickInterval: proto.Float32(1.0 / 30),
+			GameDir:      proto.String("/dota_v6751/"),
+			MapName:      proto.String("dota"),
+		},
+	})
+	w.sendTables(tables)
+	w.classInfo(classes)
+
+	combatLogNames := make([]stringTableEntry, len(syntheticCombatLogNames))
+	for i, name := range syntheticCombatLogNames {
+		combatLogNames[i] = stringTableEntry{key: name}
+	}
+	w.packet(dota.EDemoCommands_DEM_SignonPacket, math.MaxUint32,
+		w.instanceBaseline(map[string]map[string]any{
+			"CDOTAGamerulesProxy": {"m_pGameRules.m_iGameMode": 0, "m_pGameRules.m_bGamePaused": false},
+		}),
+		createStringTable("CombatLogNames", combatLogNames),
+	)
+	w.outer(dota.EDemoCommands_DEM_SyncTick, math.MaxUint32, &dota.CDemoSyncTick{})
+
+	m := &syntheticMatch{w: w, classes: make(map[int32]string)}
+	m.play()
+
+	w.outer(dota.EDemoCommands_DEM_FileInfo, m.tick, &dota.CDemoFileInfo{
+		PlaybackTime:  proto.Float32(float32(m.tick) / 30),
+		PlaybackTicks: proto.Int32(int32(m.tick)),
+		GameInfo: &dota.CGameInfo{Dota: &dota.CGameInfo_CDotaGameInfo{
+			MatchId:    proto.Uint64(syntheticMatchID),
+			GameMode:   proto.Int32(2),
+			GameWinner: proto.Int32(syntheticWinner),
+		}},
+	})
+	w.outer(dota.EDemoCommands_DEM_Stop, m.tick, &dota.CDemoStop{})
+	return w.bytes()
+}
+
+// syntheticMatch writes the packets of the synthetic match
+type syntheticMatch struct {
+	w       *demoWriter
+	tick    uint32
+	classes map[int32]string
+	ops     []entityOp
+	msgs    []innerMessage
+}
+
+func (m *syntheticMatch) play() {
+	// Draft, players join before the first pick
+	m.at(300)
+	m.create(gameRulesIndex, "CDOTAGamerulesProxy", map[string]any{
+		"m_pGameRules.m_fGameTime":           10.0,
+		"m_pGameRules.m_flGameStartTime":     0.0,
+		"m_pGameRules.m_nPauseStartTick":     0,
+		"m_pGameRules.m_nTotalPausedTicks":   0,
+		"m_pGameRules.m_iGameMode":           2,
+		"m_pGameRules.m_iActiveTeam":         2,
+		"m_pGameRules.m_BannedHeroes.0000":   0,
+		"m_pGameRules.m_SelectedHeroes.0000": 0,
+	})
+	resource := map[string]any{"m_vecPlayerData": len(syntheticPlayers), "m_vecPlayerTeamData": len(syntheticPlayers)}
+	dataTeams := map[int]map[string]any{2: {"m_vecDataTeam": 5}, 3: {"m_vecDataTeam": 5}}
+	for id, player := range syntheticPlayers {
+		data := fmt.Sprintf("m_vecPlayerData.%04d.", id)
+		resource[data+"m_iPlayerTeam"] = player.team
+		resource[data+"m_iPlayerSteamID"] = player.steamID
+		resource[data+"m_iszPlayerName"] = player.name
+		resource[fmt.Sprintf("m_vecPlayerTeamData.%04d.m_iLevel", id)] = 0
+		resource[fmt.Sprintf("m_vecPlayerTeamData.%04d.m_iTeamSlot", id)] = syntheticTeamSlot(id)
+		m.create(int32(id+1), "CDOTAPlayerController", map[string]any{
+			"m_iszPlayerName": player.name,
+			"m_steamID":       player.steamID,
+			"m_iTeamNum":      player.team,
+		})
+	}
+	m.create(playerResourceIndex, "CDOTA_PlayerResource", resource)
+	for slot := 0; slot < 5; slot++ {
+		dataTeams[2][fmt.Sprintf("m_vecDataTeam.%04d.m_iNetWorth", slot)] = 600
+		dataTeams[3][fmt.Sprintf("m_vecDataTeam.%04d.m_iNetWorth", slot)] = 600
+	}
+	m.create(dataRadiantIndex, "CDOTA_DataRadiant", dataTeams[2])
+	m.create(dataDireIndex, "CDOTA_DataDire", dataTeams[3])
+
+	bans, picks := 0, 0
+	for _, step := range syntheticDraft {
+		m.at(step.tick)
+		rules := map[string]any{"m_pGameRules.m_iActiveTeam": step.activeTeam}
+		for _, heroID := range step.bans {
+			rules[fmt.Sprintf("m_pGameRules.m_BannedHeroes.%04d", bans)] = heroID
+			bans++
+		}
+		for _, heroID := range step.picks {
+			rules[fmt.Sprintf("m_pGameRules.m_SelectedHeroes.%04d", picks)] = heroID
+			picks++
+		}
+		m.update(gameRulesIndex, rules)
+	}
+
+	// Heroes spawn once the horn time is known
+	m.at(1500)
+	m.update(gameRulesIndex, map[string]any{"m_pGameRules.m_flGameStartTime": 150.0})
+	heroes := map[string]any{}
+	for id, player := range syntheticPlayers {
+		m.create(heroIndex(id), "CDOTA_Unit_Hero_"+player.hero, map[string]any{
+			"m_iTeamNum":             player.team,
+			"CBodyComponent.m_cellX": 64,
+			"CBodyComponent.m_cellY": 64,
+		})
+		teamData := fmt.Sprintf("m_vecPlayerTeamData.%04d.", id)
+		heroes[teamData+"m_nSelectedHeroID"] = player.heroID
+		heroes[teamData+"m_hSelectedHero"] = heroHandle(id)
+		heroes[teamData+"m_iLevel"] = 1
+	}
+	m.update(playerResourceIndex, heroes)
+
+	// Wards before the horn, -0:30 and -0:10
+	m.at(3600)
+	m.create(observerWardIndex, "CDOTA_NPC_Observer_Ward", map[string]any{
+		"m_iTeamNum":             2,
+		"m_hOwnerEntity":         heroHandle(3),
+		"CBodyComponent.m_cellX": 70,
+		"CBodyComponent.m_cellY": 72,
+		"CBodyComponent.m_vecX":  32.5,
+		"CBodyComponent.m_vecY":  96.25,
+	})
+	m.at(4200)
+	m.create(sentryWardIndex, "CDOTA_NPC_Observer_Ward_TrueSight", map[string]any{
+		"m_iTeamNum":             3,
+		"m_hOwnerEntity":         heroHandle(8),
+		"CBodyComponent.m_cellX": 140,
+		"CBodyComponent.m_cellY": 137,
+		"CBodyComponent.m_vecX":  64.0,
+		"CBodyComponent.m_vecY":  12.75,
+	})
+
+	// Glyphs at 0:50, twice in a row as spectators see orders of the same second repeated, and 2:30
+	m.at(6000)
+	m.glyph(7)
+	m.glyph(7)
+	m.at(9000)
+	m.glyph(0)
+
+	// The observer ward is destroyed by Earthshaker at 3:20
+	m.at(10500)
+	m.delete(observerWardIndex)
+	m.message(int32(dota.EDotaUserMessages_DOTA_UM_CombatLogDataHLTV), &dota.CMsgDOTACombatLogEntry{
+		Type:           dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DEATH.Enum(),
+		TargetName:     proto.Uint32(0),
+		AttackerName:   proto.Uint32(2),
+		IsAttackerHero: proto.Bool(true),
+		TargetTeam:     proto.Uint32(2),
+		AttackerTeam:   proto.Uint32(3),
+	})
+
+	// A 30 second pause at 4:00
+	m.at(12000)
+	m.update(gameRulesIndex, map[string]any{"m_pGameRules.m_bGamePaused": true, "m_pGameRules.m_nPauseStartTick": 12000})
+	m.at(12900)
+	m.update(gameRulesIndex, map[string]any{"m_pGameRules.m_bGamePaused": false, "m_pGameRules.m_nTotalPausedTicks": 900})
+
+	// Radiant kills Roshan at 12:30, Moss takes the Aegis and does not die with it
+	m.at(27900)
+	m.chatEvent(dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_ROSHAN_KILL, 2, 225)
+	m.at(27960)
+	m.chatEvent(dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_AEGIS, 1, 0)
+	m.at(36960)
+
+	// Dire kills a Tormentor at 22:30
+	m.at(45900)
+	m.chatEvent(dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_MINIBOSS_KILL, 3, 238)
+
+	// Radiant wins at 30:01, the sentry ward is still up
+	m.at(54930)
+	m.update(gameRulesIndex, map[string]any{"m_pGameRules.m_flGameEndTime": 1951.4})
+	stats := map[string]any{}
+	for id, player := range syntheticPlayers {
+		teamData := fmt.Sprintf("m_vecPlayerTeamData.%04d.", id)
+		stats[teamData+"m_iKills"] = player.kills
+		stats[teamData+"m_iDeaths"] = player.deaths
+		stats[teamData+"m_iAssists"] = player.assists
+		stats[teamData+"m_iLevel"] = player.level
+	}
+	m.update(playerResourceIndex, stats)
+	for _, team := range []int{2, 3} {
+		index, teamStats := int32(dataRadiantIndex), map[string]any{}
+		if team == 3 {
+			index = dataDireIndex
+		}
+		for id, player := range syntheticPlayers {
+			if player.team == team {
+				slot := syntheticTeamSlot(id)
+				teamStats[fmt.Sprintf("m_vecDataTeam.%04d.m_iNetWorth", slot)] = player.netWorth
+				teamStats[fmt.Sprintf("m_vecDataTeam.%04d.m_iLastHitCount", slot)] = player.lastHits
+			}
+		}
+		m.update(index, teamStats)
+	}
+	m.flush()
+}
+
+// syntheticTeamSlot is the slot of the player within the team, Dire slots are in reverse order of the player IDs
+func syntheticTeamSlot(playerID int) int {
+	if syntheticPlayers[playerID].team == 3 {
+		return 9 - playerID
+	}
+	return playerID
+}
+
+func heroIndex(playerID int) int32 {
+	return int32(firstHeroIndex + playerID)
+}
+
+// heroHandle is the entity handle of the hero of the player, serial and index
+func heroHandle(playerID int) uint64 {
+	return heroSerial<<14 | uint64(heroIndex(playerID))
+}
+
+// at writes the packets of the previous tick and moves to the given one.
+// Game rules are updated on every tick, so the game clock follows the net tick.
+func (m *syntheticMatch) at(tick uint32) {
+	m.flush()
+	m.tick = tick
+	if _, ok := m.classes[gameRulesIndex]; ok {
+		m.update(gameRulesIndex, map[string]any{"m_pGameRules.m_fGameTime": float64(tick) / 30})
+	}
+}
+
+// flush writes the entity operations of the tick in a packet, messages follow in another packet of the same tick
+// so they are handled once entities are updated
+func (m *syntheticMatch) flush() {
+	netTick := innerMessage{typ: int32(dota.NET_Messages_net_Tick), msg: &dota.CNETMsg_Tick{Tick: proto.Uint32(m.tick)}}
+	if len(m.ops) > 0 {
+		m.w.packet(dota.EDemoCommands_DEM_Packet, m.tick, netTick, m.w.packetEntities(m.ops))
+	}
+	if len(m.msgs) > 0 {
+		m.w.packet(dota.EDemoCommands_DEM_Packet, m.tick, append([]innerMessage{netTick}, m.msgs...)...)
+	}
+	m.ops, m.msgs = nil, nil
+}
+
+func (m *syntheticMatch) create(index int32, class string, props map[string]any) {
+	m.classes[index] = class
+	m.ops = append(m.ops, entityOp{index: index, class: class, serial: heroSerial, create: true, props: props})
+}
+
+// update merges the properties into an update of the entity in the current tick
+func (m *syntheticMatch) update(index int32, props map[string]any) {
+	for i := range m.ops {
+		if m.ops[i].index == index && !m.ops[i].delete {
+			for name, value := range props {
+				m.ops[i].props[name] = value
+			}
+			return
+		}
+	}
+	m.ops = append(m.ops, entityOp{index: index, class: m.classes[index], props: props})
+}
+
+func (m *syntheticMatch) delete(index int32) {
+	delete(m.classes, index)
+	m.ops = append(m.ops, entityOp{index: index, delete: true})
+}
+
+func (m *syntheticMatch) message(typ int32, msg proto.Message) {
+	m.msgs = append(m.msgs, innerMessage{typ: typ, msg: msg})
+}
+
+func (m *syntheticMatch) glyph(playerID int) {
+	m.message(int32(dota.EDotaUserMessages_DOTA_UM_SpectatorPlayerUnitOrders), &dota.CDOTAUserMsg_SpectatorPlayerUnitOrders{
+		Entindex:       proto.Int32(int32(playerID + 1)),
+		OrderType:      proto.Int32(int32(dota.DotaunitorderT_DOTA_UNIT_ORDER_GLYPH)),
+		Units:          []int32{heroIndex(playerID)},
+		SequenceNumber: proto.Int32(int32(m.tick)),
+	})
+}
+
+func (m *syntheticMatch) chatEvent(typ dota.DOTA_CHAT_MESSAGE, playerID int32, value uint32) {
+	m.message(int32(dota.EDotaUserMessages_DOTA_UM_ChatEvent), &dota.CDOTAUserMsg_ChatEvent{
+		Type:       typ.Enum(),
+		Value:      proto.Uint32(value),
+		Playerid_1: proto.Int32(playerID),
+		Playerid_2: proto.Int32(-1),
+	})
+}
@@ -0,0 +1,117 @@
+package extractors_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-glyph/internal/core/extractors"
+	"go-glyph/internal/core/models"
+)
+
+// parseSyntheticDemo runs a single extractor over the synthetic demo
+func parseSyntheticDemo(t *testing.T, name string) extractors.Result {
+	t.Helper()
+	result, err := parseFixtureDemo(filepath.Join(fixtureDemosDir, syntheticDemo), name)
+	if err != nil {
+		t.Fatalf("cannot parse the synthetic demo with %s: %v", name, err)
+	}
+	return result
+}
+
+func TestGlyphExtractorOnFixture(t *testing.T) {
+	result := parseSyntheticDemo(t, "glyphs")
+
+	// The repeated order of Cinder is recorded once
+	want := []models.Glyph{
+		{MatchID: syntheticMatchID, Username: "Cinder", UserSteamID: "76561198000000108", Minute: 0, Second: 50, Team: 3, HeroID: 14},
+		{MatchID: syntheticMatchID, Username: "Kestrel", UserSteamID: "76561198000000101", Minute: 2, Second: 30, Team: 2, HeroID: 8},
+	}
+	if len(result.Glyphs) != len(want) {
+		t.Fatalf("expected %d glyphs, got %+v", len(want), result.Glyphs)
+	}
+	for i := range want {
+		if result.Glyphs[i] != want[i] {
+			t.Errorf("glyph %d: expected %+v, got %+v", i, want[i], result.Glyphs[i])
+		}
+	}
+	if result.DemoMatchID != syntheticMatchID {
+		t.Errorf("expected the match ID of the file info, got %d", result.DemoMatchID)
+	}
+}
+
+func TestDraftExtractorOnFixture(t *testing.T) {
+	draft := parseSyntheticDemo(t, "draft").Match.Draft
+
+	if len(draft) != 14 {
+		t.Fatalf("expected 4 bans and 10 picks, got %+v", draft)
+	}
+	if first := draft[0]; first.IsPick || first.Team != 2 || first.HeroID != 34 || first.Order != 0 {
+		t.Errorf("expected the first ban of Radiant, got %+v", first)
+	}
+	if last := draft[13]; !last.IsPick || last.Team != 3 || last.HeroID != 7 || last.Order != 13 {
+		t.Errorf("expected the last pick of Dire, got %+v", last)
+	}
+}
+
+func TestScoreboardExtractorOnFixture(t *testing.T) {
+	match := parseSyntheticDemo(t, "scoreboard").Match
+
+	if match.Winner != syntheticWinner || match.Duration != 1801 {
+		t.Errorf("expected Radiant to win after 30:01, got winner %d after %d seconds", match.Winner, match.Duration)
+	}
+	if len(match.Players) != len(syntheticPlayers) {
+		t.Fatalf("expected %d players, got %d", len(syntheticPlayers), len(match.Players))
+	}
+	// Dire slots of the team data are in reverse order of the player IDs
+	for i, player := range match.Players {
+		want := syntheticPlayers[i]
+		if player.Username != want.name || player.Kills != uint32(want.kills) || player.Deaths != uint32(want.deaths) ||
+			player.Assists != uint32(want.assists) || player.Level != uint32(want.level) ||
+			player.NetWorth != uint32(want.netWorth) || player.LastHits != uint32(want.lastHits) {
+			t.Errorf("player %d: unexpected scoreboard %+v", i, player)
+		}
+	}
+}
+
+func TestObjectiveExtractorOnFixture(t *testing.T) {
+	objectives := parseSyntheticDemo(t, "objectives").Match.Objectives
+
+	want := []struct {
+		objectiveType  string
+		team           uint64
+		username       string
+		minute, second uint32
+	}{
+		{models.ObjectiveRoshanKill, 2, "", 12, 30},
+		{models.ObjectiveAegisPickup, 2, "Moss", 12, 32},
+		{models.ObjectiveAegisExpired, 2, "Moss", 17, 32},
+		{models.ObjectiveTormentorKill, 3, "", 22, 30},
+	}
+	if len(objectives) != len(want) {
+		t.Fatalf("expected %d objectives, got %+v", len(want), objectives)
+	}
+	for i, w := range want {
+		got := objectives[i]
+		if got.Type != w.objectiveType || got.Team != w.team || got.Username != w.username || got.Minute != w.minute || got.Second != w.second {
+			t.Errorf("objective %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+}
+
+func TestWardExtractorOnFixture(t *testing.T) {
+	wards := parseSyntheticDemo(t, "wards").Match.Wards
+
+	if len(wards) != 2 {
+		t.Fatalf("expected 2 wards, got %+v", wards)
+	}
+	observer, sentry := wards[0], wards[1]
+	if observer.Type != models.WardObserver || observer.Username != "Tarn" || observer.Minute != 0 || observer.Second != -30 {
+		t.Errorf("expected the observer ward of Tarn at -0:30, got %+v", observer)
+	}
+	if observer.KillerUsername != "Ember" || observer.KillerTeam != 3 || observer.Lifetime != 230 {
+		t.Errorf("expected the observer ward to be killed by Ember after 230 seconds, got %+v", observer)
+	}
+	if sentry.Type != models.WardSentry || sentry.Username != "Dusk" || sentry.KillerTeam != 0 || sentry.Lifetime != 1661 {
+		t.Errorf("expected the sentry ward of Dusk to live until the end, got %+v", sentry)
+	}
+}
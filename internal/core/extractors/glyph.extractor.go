@@ -0,0 +1,59 @@
+package extractors
+
+import (
+	"strconv"
+
+	"github.com/dotabuff/manta/dota"
+	"golang.org/x/exp/slices"
+
+	"go-glyph/internal/core/models"
+)
+
+// GlyphExtractor records glyph of fortification usages from spectator unit orders
+type GlyphExtractor struct {
+	glyphs []models.Glyph
+}
+
+func (x *GlyphExtractor) Register(ctx *Context) {
+	p := ctx.Parser
+	p.Callbacks.OnCDOTAUserMsg_SpectatorPlayerUnitOrders(func(m *dota.CDOTAUserMsg_SpectatorPlayerUnitOrders) error {
+		if m.GetOrderType() != int32(dota.DotaunitorderT_DOTA_UNIT_ORDER_GLYPH) {
+			return nil
+		}
+		// The player controller may be gone or not networked yet
+		entity := p.FindEntity(m.GetEntindex())
+		if entity == nil {
+			return nil
+		}
+		username, ok := entity.GetString("m_iszPlayerName")
+		if !ok {
+			return nil
+		}
+		steamID, ok := entity.GetUint64("m_steamID")
+		if !ok {
+			return nil
+		}
+		team, _ := getEntityInt(entity, "m_iTeamNum")
+
+		glyph := models.Glyph{
+			MatchID:     ctx.MatchID,
+			Username:    username,
+			UserSteamID: strconv.FormatUint(steamID, 10),
+			Team:        uint64(team),
+		}
+		glyph.Minute, glyph.Second = ctx.Clock.MinuteSecond()
+		if !slices.Contains(x.glyphs, glyph) {
+			x.glyphs = append(x.glyphs, glyph)
+		}
+		return nil
+	})
+}
+
+func (x *GlyphExtractor) Finish(ctx *Context, result *Result) {
+	for k := range x.glyphs {
+		if player, ok := ctx.Lineup.FindBySteamID(x.glyphs[k].UserSteamID); ok {
+			x.glyphs[k].HeroID = player.HeroID
+		}
+	}
+	result.Glyphs = x.glyphs
+}
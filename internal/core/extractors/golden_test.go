@@ -0,0 +1,150 @@
+package extractors_test
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"go-glyph/internal/core/extractors"
+	"go-glyph/internal/core/services"
+)
+
+// Regenerate the synthetic demo and golden files with: go test ./internal/core/extractors -update
+var update = flag.Bool("update", false, "regenerate golden files of the replay parser")
+
+const (
+	fixtureDemosDir = "testdata/demos"
+	goldenDir       = "testdata/golden"
+)
+
+// TestGolden runs every extractor over every fixture demo and compares the result with testdata/golden/<demo>/<extractor>.json
+func TestGolden(t *testing.T) {
+	demos, err := fixtureDemos()
+	if err != nil {
+		t.Fatalf("cannot list fixture demos: %v", err)
+	}
+	if len(demos) == 0 {
+		t.Skipf("no fixture demos in %s", fixtureDemosDir)
+	}
+
+	for _, demo := range demos {
+		demoName := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(demo), ".bz2"), ".dem")
+		filename, err := fixtureDemoFile(t, demo)
+		if err != nil {
+			t.Fatalf("cannot read fixture demo %s: %v", demo, err)
+		}
+
+		for _, name := range extractors.Names() {
+			t.Run(demoName+"/"+name, func(t *testing.T) {
+				result, err := parseFixtureDemo(filename, name)
+				if err != nil {
+					t.Fatalf("cannot parse %s: %v", demo, err)
+				}
+
+				got, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					t.Fatalf("cannot marshal result: %v", err)
+				}
+				got = append(got, '\n')
+
+				golden := filepath.Join(goldenDir, demoName, name+".json")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+						t.Fatalf("cannot create golden directory: %v", err)
+					}
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatalf("cannot write golden file: %v", err)
+					}
+					return
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("cannot read golden file, regenerate it with -update: %v", err)
+				}
+				if !bytes.Equal(want, got) {
+					t.Errorf("%s differs from the parser output, regenerate it with -update if the change is intended\n%s",
+						golden, firstDifference(want, got))
+				}
+			})
+		}
+	}
+}
+
+// fixtureDemos lists the .dem and .dem.bz2 files of the fixture directory
+func fixtureDemos() ([]string, error) {
+	entries, err := os.ReadDir(fixtureDemosDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var demos []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && (strings.HasSuffix(name, ".dem") || strings.HasSuffix(name, ".dem.bz2")) {
+			demos = append(demos, filepath.Join(fixtureDemosDir, name))
+		}
+	}
+	sort.Strings(demos)
+	return demos, nil
+}
+
+// fixtureDemoFile returns the path of the plain demo, a compressed demo is decompressed into a temporary file
+func fixtureDemoFile(t *testing.T, path string) (string, error) {
+	if !strings.HasSuffix(path, ".bz2") {
+		return path, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	filename := filepath.Join(t.TempDir(), strings.TrimSuffix(filepath.Base(path), ".bz2"))
+	out, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, bzip2.NewReader(f)); err != nil {
+		_ = out.Close()
+		return "", err
+	}
+	return filename, out.Close()
+}
+
+// parseFixtureDemo parses the demo with a single extractor through MantaService, as replays are parsed by the API
+func parseFixtureDemo(filename, name string) (extractors.Result, error) {
+	return services.NewMantaService(nil).ParseFile(context.Background(), filename, []string{name})
+}
+
+// firstDifference describes the first line that differs between want and got
+func firstDifference(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var wantLine, gotLine string
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if wantLine != gotLine {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, wantLine, gotLine)
+		}
+	}
+	return ""
+}
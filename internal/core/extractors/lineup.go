@@ -0,0 +1,165 @@
+package extractors
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dotabuff/manta"
+
+	"go-glyph/internal/core/models"
+)
+
+const (
+	maxPlayers = 10
+
+	// Entity handles keep the entity index in the lower bits
+	entityIndexMask = (1 << 14) - 1
+)
+
+// lineupFields are the CDOTA_PlayerResource properties of a player
+type lineupFields struct {
+	steamID, name, team, heroHandle, heroID string
+}
+
+// playerLineupFields is built once, the player resource is read on every update until all heroes spawned
+var playerLineupFields = func() (fields [maxPlayers]lineupFields) {
+	for i := range fields {
+		fields[i] = lineupFields{
+			steamID:    vectorField("m_vecPlayerData", i, "m_iPlayerSteamID"),
+			name:       vectorField("m_vecPlayerData", i, "m_iszPlayerName"),
+			team:       vectorField("m_vecPlayerData", i, "m_iPlayerTeam"),
+			heroHandle: vectorField("m_vecPlayerTeamData", i, "m_hSelectedHero"),
+			heroID:     vectorField("m_vecPlayerTeamData", i, "m_nSelectedHeroID"),
+		}
+	}
+	return fields
+}()
+
+// Lineup follows the ten players of CDOTA_PlayerResource
+type Lineup struct {
+	players     [maxPlayers]models.MatchPlayer
+	heroHandles [maxPlayers]uint64
+	// pendingHeroes holds the player IDs without a spawned hero, updates are ignored once it is empty
+	pendingHeroes map[int]bool
+}
+
+func newLineup(matchID int) *Lineup {
+	l := &Lineup{pendingHeroes: make(map[int]bool, maxPlayers)}
+	for i := range l.players {
+		l.players[i].MatchID = matchID
+		l.players[i].PlayerSlot = uint32(i)
+		l.pendingHeroes[i] = true
+	}
+	return l
+}
+
+// update follows the player resource during the pass, extractors look players up by their hero as it happens
+func (l *Lineup) update(e *manta.Entity) {
+	if len(l.pendingHeroes) == 0 {
+		return
+	}
+	for i := range l.pendingHeroes {
+		l.read(e, i)
+		if l.heroHandles[i] != 0 {
+			delete(l.pendingHeroes, i)
+		}
+	}
+}
+
+// finish reads the final lineup once the pass has ended
+func (l *Lineup) finish(p *manta.Parser) {
+	e := findEntityByClassName(p, "CDOTA_PlayerResource")
+	if e == nil {
+		return
+	}
+	for i := range l.players {
+		l.read(e, i)
+	}
+}
+
+func (l *Lineup) read(e *manta.Entity, i int) {
+	fields := playerLineupFields[i]
+	if steamID, ok := e.GetUint64(fields.steamID); ok {
+		l.players[i].UserSteamID = strconv.FormatUint(steamID, 10)
+	}
+	if name, ok := e.GetString(fields.name); ok {
+		l.players[i].Username = name
+	}
+	if team, ok := e.GetInt32(fields.team); ok {
+		l.players[i].Team = uint64(team)
+	}
+	if handle, ok := e.GetUint64(fields.heroHandle); ok {
+		l.heroHandles[i] = handle
+	}
+	newHeroID, ok := e.GetInt32(fields.heroID)
+	if ok && newHeroID > 0 && newHeroID < 1000000 {
+		l.players[i].HeroID = uint32(newHeroID)
+	}
+}
+
+// Player returns the player with the given player ID as used in user messages and the combat log
+func (l *Lineup) Player(playerID int32) (models.MatchPlayer, bool) {
+	if playerID < 0 || int(playerID) >= len(l.players) || l.players[playerID].UserSteamID == "" {
+		return models.MatchPlayer{}, false
+	}
+	return l.players[playerID], true
+}
+
+// Players returns all players that are present in the replay
+func (l *Lineup) Players() []models.MatchPlayer {
+	var players []models.MatchPlayer
+	for _, player := range l.players {
+		// Empty slots are never filled in by the player resource
+		if player.UserSteamID != "" {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// FindBySteamID returns the player with the given Steam ID
+func (l *Lineup) FindBySteamID(steamID string) (models.MatchPlayer, bool) {
+	for _, player := range l.players {
+		if player.UserSteamID == steamID {
+			return player, true
+		}
+	}
+	return models.MatchPlayer{}, false
+}
+
+// FindByHeroID returns the player who ended up with the given hero
+func (l *Lineup) FindByHeroID(heroID uint32) (models.MatchPlayer, bool) {
+	for _, player := range l.players {
+		if player.HeroID != 0 && player.HeroID == heroID {
+			return player, true
+		}
+	}
+	return models.MatchPlayer{}, false
+}
+
+// FindByHeroHandle returns the player controlling the hero entity with the given handle
+func (l *Lineup) FindByHeroHandle(handle uint64) (models.MatchPlayer, bool) {
+	for i, heroHandle := range l.heroHandles {
+		if heroHandle != 0 && heroHandle&entityIndexMask == handle&entityIndexMask {
+			return l.players[i], true
+		}
+	}
+	return models.MatchPlayer{}, false
+}
+
+// FindByHeroUnitName returns the player controlling a hero given its combat log unit name like npc_dota_hero_doom_bringer
+func (l *Lineup) FindByHeroUnitName(p *manta.Parser, unitName string) (models.MatchPlayer, bool) {
+	name := normalizeHeroName(strings.TrimPrefix(unitName, "npc_dota_hero_"))
+	for i, handle := range l.heroHandles {
+		hero := p.FindEntityByHandle(handle)
+		if hero != nil && normalizeHeroName(strings.TrimPrefix(hero.GetClassName(), "CDOTA_Unit_Hero_")) == name {
+			return l.players[i], true
+		}
+	}
+	return models.MatchPlayer{}, false
+}
+
+// normalizeHeroName makes hero class names (DoomBringer) comparable with unit names (doom_bringer)
+func normalizeHeroName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
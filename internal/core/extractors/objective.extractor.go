@@ -0,0 +1,97 @@
+package extractors
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+
+	"go-glyph/internal/core/models"
+)
+
+// Aegis disappears if it is not used within 5 minutes after pickup
+const aegisLifetime = 5 * 60
+
+// ObjectiveExtractor records Roshan, Aegis and Tormentor events
+type ObjectiveExtractor struct {
+	objectives      []models.Objective
+	aegisHolder     int32
+	aegisPickupTime float64
+}
+
+func (x *ObjectiveExtractor) Register(ctx *Context) {
+	p := ctx.Parser
+	x.aegisHolder = -1
+
+	p.Callbacks.OnCDOTAUserMsg_ChatEvent(func(m *dota.CDOTAUserMsg_ChatEvent) error {
+		x.onChatEvent(ctx, m)
+		return nil
+	})
+
+	// Aegis reincarnation is only visible in the combat log, it means the Aegis will not expire
+	p.Callbacks.OnCMsgDOTACombatLogEntry(func(m *dota.CMsgDOTACombatLogEntry) error {
+		if m.GetType() != dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_MODIFIER_ADD {
+			return nil
+		}
+		if name, ok := p.LookupStringByIndex("CombatLogNames", int32(m.GetInflictorName())); ok && name == "modifier_aegis_regen" {
+			x.aegisHolder = -1
+		}
+		return nil
+	})
+
+	p.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
+		if e.GetClassName() != "CDOTAGamerulesProxy" {
+			return nil
+		}
+		if x.aegisHolder >= 0 && ctx.Clock.Now()-x.aegisPickupTime >= aegisLifetime {
+			x.add(ctx, models.ObjectiveAegisExpired, 0, x.aegisHolder)
+			x.aegisHolder = -1
+		}
+		return nil
+	})
+}
+
+func (x *ObjectiveExtractor) onChatEvent(ctx *Context, m *dota.CDOTAUserMsg_ChatEvent) {
+	switch m.GetType() {
+	case dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_ROSHAN_KILL, dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_MINIBOSS_KILL:
+		// Playerid_1 holds the team that killed Roshan or the Tormentor, Value the gold bounty
+		objectiveType := models.ObjectiveRoshanKill
+		if m.GetType() == dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_MINIBOSS_KILL {
+			objectiveType = models.ObjectiveTormentorKill
+		}
+		team := uint64(m.GetPlayerid_1())
+		if team != radiantTeam && team != direTeam {
+			team = 0
+		}
+		x.add(ctx, objectiveType, team, -1)
+	case dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_AEGIS:
+		x.add(ctx, models.ObjectiveAegisPickup, 0, m.GetPlayerid_1())
+		x.aegisHolder, x.aegisPickupTime = m.GetPlayerid_1(), ctx.Clock.Now()
+	case dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_AEGIS_STOLEN:
+		x.add(ctx, models.ObjectiveAegisStolen, 0, m.GetPlayerid_1())
+		x.aegisHolder, x.aegisPickupTime = m.GetPlayerid_1(), ctx.Clock.Now()
+	case dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_DENIED_AEGIS:
+		x.add(ctx, models.ObjectiveAegisDenied, 0, m.GetPlayerid_1())
+		x.aegisHolder = -1
+	}
+}
+
+func (x *ObjectiveExtractor) Finish(ctx *Context, result *Result) {
+	result.Match.Objectives = x.objectives
+}
+
+func (x *ObjectiveExtractor) add(ctx *Context, objectiveType string, team uint64, playerID int32) {
+	objective := models.Objective{
+		MatchID: ctx.MatchID,
+		Type:    objectiveType,
+		Team:    team,
+	}
+	objective.Minute, objective.Second = ctx.Clock.MinuteSecond()
+	if player, ok := ctx.Lineup.Player(playerID); ok {
+		objective.Username = player.Username
+		objective.UserSteamID = player.UserSteamID
+		objective.HeroID = player.HeroID
+		if objective.Team == 0 {
+			objective.Team = player.Team
+		}
+	}
+	x.objectives = append(x.objectives, objective)
+}
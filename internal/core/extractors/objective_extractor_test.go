@@ -0,0 +1,44 @@
+package extractors
+
+import (
+	"testing"
+
+	"github.com/dotabuff/manta/dota"
+	"google.golang.org/protobuf/proto"
+
+	"go-glyph/internal/core/models"
+)
+
+func TestObjectiveExtractorTakesKillTeamFromPlayerID(t *testing.T) {
+	ctx := &Context{MatchID: 7000000001, Clock: &GameClock{}, Lineup: newLineup(7000000001)}
+	// Player 2 and 3 exist, the team in Playerid_1 must not be mistaken for them
+	ctx.Lineup.players[2] = models.MatchPlayer{Username: "radiant player", UserSteamID: "76561198000000002", Team: radiantTeam}
+	ctx.Lineup.players[3] = models.MatchPlayer{Username: "dire player", UserSteamID: "76561198000000003", Team: direTeam}
+
+	x := &ObjectiveExtractor{aegisHolder: -1}
+	x.onChatEvent(ctx, &dota.CDOTAUserMsg_ChatEvent{
+		Type:       dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_ROSHAN_KILL.Enum(),
+		Value:      proto.Uint32(225),
+		Playerid_1: proto.Int32(direTeam),
+		Playerid_2: proto.Int32(-1),
+	})
+	x.onChatEvent(ctx, &dota.CDOTAUserMsg_ChatEvent{
+		Type:       dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_MINIBOSS_KILL.Enum(),
+		Value:      proto.Uint32(200),
+		Playerid_1: proto.Int32(radiantTeam),
+		Playerid_2: proto.Int32(-1),
+	})
+
+	expected := []models.Objective{
+		{MatchID: 7000000001, Type: models.ObjectiveRoshanKill, Team: direTeam},
+		{MatchID: 7000000001, Type: models.ObjectiveTormentorKill, Team: radiantTeam},
+	}
+	if len(x.objectives) != len(expected) {
+		t.Fatalf("expected %d objectives, got %+v", len(expected), x.objectives)
+	}
+	for i := range expected {
+		if x.objectives[i] != expected[i] {
+			t.Errorf("objective %d: expected %+v, got %+v", i, expected[i], x.objectives[i])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package extractors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Factory func() Extractor
+
+// registryMu guards registry, extractors may be registered while jobs are running
+var registryMu sync.RWMutex
+
+var registry = map[string]Factory{
+	"glyphs":     func() Extractor { return &GlyphExtractor{} },
+	"draft":      func() Extractor { return &DraftExtractor{} },
+	"scoreboard": func() Extractor { return &ScoreboardExtractor{} },
+	"objectives": func() Extractor { return &ObjectiveExtractor{} },
+	"wards":      func() Extractor { return &WardExtractor{} },
+}
+
+type UnknownExtractorError struct {
+	Name string
+}
+
+func (e UnknownExtractorError) Error() string {
+	return fmt.Sprintf("Unknown extractor %q, available extractors: %s", e.Name, strings.Join(Names(), ", "))
+}
+
+// Register makes an extractor available by name, replacing an existing one with the same name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Names returns names of all registered extractors in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registeredNames()
+}
+
+// registeredNames returns names of all registered extractors in alphabetical order, registryMu must be held
+func registeredNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that all names refer to registered extractors
+func Validate(names []string) error {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if _, ok := registry[name]; name != "" && !ok {
+			return UnknownExtractorError{Name: name}
+		}
+	}
+	return nil
+}
+
+// New creates extractors by name. Duplicates are ignored and an empty list means all registered extractors.
+func New(names []string) ([]Extractor, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if len(names) == 0 {
+		names = registeredNames()
+	}
+
+	extractors := make([]Extractor, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		factory, ok := registry[name]
+		if !ok {
+			return nil, UnknownExtractorError{Name: name}
+		}
+		seen[name] = true
+		extractors = append(extractors, factory())
+	}
+
+	return extractors, nil
+}
@@ -0,0 +1,60 @@
+package extractors
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewReturnsAllExtractorsForEmptyList(t *testing.T) {
+	selected, err := New(nil)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if len(selected) != len(Names()) {
+		t.Fatalf("expected %d extractors, got %d", len(Names()), len(selected))
+	}
+}
+
+func TestNewSkipsDuplicatesAndRejectsUnknownNames(t *testing.T) {
+	selected, err := New([]string{"glyphs", " glyphs", "wards"})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if len(selected) != 2 {
+		t.Fatalf("expected 2 extractors, got %d", len(selected))
+	}
+
+	var unknown UnknownExtractorError
+	if _, err := New([]string{"glyphs", "couriers"}); !errors.As(err, &unknown) || unknown.Name != "couriers" {
+		t.Fatalf("expected UnknownExtractorError for couriers, got %v", err)
+	}
+	if err := Validate([]string{"couriers"}); !errors.As(err, &unknown) {
+		t.Fatalf("expected Validate to reject couriers, got %v", err)
+	}
+}
+
+func TestRegisterWhileCreatingExtractors(t *testing.T) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "glyphs again")
+		registryMu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			Register("glyphs again", func() Extractor { return &GlyphExtractor{} })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := New(nil); err != nil {
+			t.Fatalf("New returned error: %v", err)
+		}
+	}
+	<-done
+
+	if err := Validate([]string{"glyphs again"}); err != nil {
+		t.Fatalf("expected the registered extractor to be available, got %v", err)
+	}
+}
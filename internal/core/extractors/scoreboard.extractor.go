@@ -0,0 +1,113 @@
+package extractors
+
+import (
+	"math"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+
+	"go-glyph/internal/core/models"
+)
+
+const (
+	radiantTeam = 2
+	direTeam    = 3
+)
+
+// playerStats holds the final scoreboard values of a player
+type playerStats struct {
+	kills, deaths, assists, level uint32
+	netWorth, lastHits            uint32
+}
+
+// ScoreboardExtractor records the winner, duration and final scoreboard of the match.
+// The scoreboard is read once from the entities left at the end of the replay.
+type ScoreboardExtractor struct {
+	winner uint64
+}
+
+func (x *ScoreboardExtractor) Register(ctx *Context) {
+	ctx.Parser.Callbacks.OnCDemoFileInfo(func(m *dota.CDemoFileInfo) error {
+		x.winner = uint64(m.GetGameInfo().GetDota().GetGameWinner())
+		return nil
+	})
+}
+
+func (x *ScoreboardExtractor) Finish(ctx *Context, result *Result) {
+	result.Match.Winner = x.winner
+	result.Match.Duration = uint32(math.Max(math.Round(ctx.Clock.Duration()), 0))
+
+	p := ctx.Parser
+	playerResource := findEntityByClassName(p, "CDOTA_PlayerResource")
+	if playerResource == nil {
+		return
+	}
+	teamData := map[uint64]*manta.Entity{
+		radiantTeam: findEntityByClassName(p, "CDOTA_DataRadiant"),
+		direTeam:    findEntityByClassName(p, "CDOTA_DataDire"),
+	}
+	applyPlayerStats(result.Match.Players, readPlayerStats(playerResource, teamData))
+}
+
+// readPlayerStats reads the scoreboard of every player ID.
+// Values networked on CDOTA_DataRadiant and CDOTA_DataDire are indexed by the player's slot within the team.
+func readPlayerStats(playerResource *manta.Entity, teamData map[uint64]*manta.Entity) [maxPlayers]playerStats {
+	var (
+		stats     [maxPlayers]playerStats
+		teams     [maxPlayers]uint64
+		networked [maxPlayers]int
+	)
+	for i := range stats {
+		team, _ := getEntityInt(playerResource, vectorField("m_vecPlayerData", i, "m_iPlayerTeam"))
+		teams[i] = uint64(team)
+		networked[i] = -1
+		if slot, ok := getEntityInt(playerResource, vectorField("m_vecPlayerTeamData", i, "m_iTeamSlot")); ok {
+			networked[i] = int(slot)
+		}
+
+		kills, _ := getEntityInt(playerResource, vectorField("m_vecPlayerTeamData", i, "m_iKills"))
+		deaths, _ := getEntityInt(playerResource, vectorField("m_vecPlayerTeamData", i, "m_iDeaths"))
+		assists, _ := getEntityInt(playerResource, vectorField("m_vecPlayerTeamData", i, "m_iAssists"))
+		level, _ := getEntityInt(playerResource, vectorField("m_vecPlayerTeamData", i, "m_iLevel"))
+		stats[i] = playerStats{kills: uint32(kills), deaths: uint32(deaths), assists: uint32(assists), level: uint32(level)}
+	}
+
+	for i, slot := range teamSlots(teams, networked) {
+		data := teamData[teams[i]]
+		if data == nil {
+			continue
+		}
+		netWorth, _ := getEntityInt(data, vectorField("m_vecDataTeam", slot, "m_iNetWorth"))
+		lastHits, _ := getEntityInt(data, vectorField("m_vecDataTeam", slot, "m_iLastHitCount"))
+		stats[i].netWorth, stats[i].lastHits = uint32(netWorth), uint32(lastHits)
+	}
+	return stats
+}
+
+// teamSlots returns the slot within the team of every player ID. The slot networked on m_vecPlayerTeamData wins,
+// without it players take the slots of their team in the order of m_vecPlayerTeamData.
+func teamSlots(teams [maxPlayers]uint64, networked [maxPlayers]int) [maxPlayers]int {
+	var slots [maxPlayers]int
+	next := make(map[uint64]int)
+	for i, team := range teams {
+		slots[i] = next[team]
+		if networked[i] >= 0 {
+			slots[i] = networked[i]
+		}
+		next[team]++
+	}
+	return slots
+}
+
+// applyPlayerStats copies final stats to players by player slot
+func applyPlayerStats(players []models.MatchPlayer, stats [maxPlayers]playerStats) {
+	for i := range players {
+		player := &players[i]
+		if int(player.PlayerSlot) >= len(stats) {
+			continue
+		}
+		slotStats := stats[player.PlayerSlot]
+		player.Kills, player.Deaths, player.Assists, player.Level = slotStats.kills, slotStats.deaths, slotStats.assists, slotStats.level
+		player.NetWorth, player.LastHits = slotStats.netWorth, slotStats.lastHits
+	}
+}
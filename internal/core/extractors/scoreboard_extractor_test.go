@@ -0,0 +1,49 @@
+package extractors
+
+import (
+	"testing"
+
+	"go-glyph/internal/core/models"
+)
+
+func TestTeamSlotsFollowPlayerTeamData(t *testing.T) {
+	var teams [maxPlayers]uint64
+	var networked [maxPlayers]int
+	for i := range networked {
+		networked[i] = -1
+	}
+	// Player ID 1 is an empty slot, player IDs 5 and 7 have their slot networked
+	teams[0], teams[2] = radiantTeam, radiantTeam
+	teams[5], teams[6], teams[7] = direTeam, direTeam, direTeam
+	networked[5], networked[7] = 2, 0
+
+	slots := teamSlots(teams, networked)
+
+	if slots[0] != 0 || slots[2] != 1 {
+		t.Errorf("expected radiant players in the order of the player team data, got slots %d and %d", slots[0], slots[2])
+	}
+	if slots[5] != 2 || slots[6] != 1 || slots[7] != 0 {
+		t.Errorf("expected networked dire slots to win, got slots %d, %d and %d", slots[5], slots[6], slots[7])
+	}
+}
+
+func TestApplyPlayerStatsCopiesStatsByPlayerSlot(t *testing.T) {
+	players := []models.MatchPlayer{
+		{PlayerSlot: 0, Team: radiantTeam},
+		{PlayerSlot: 5, Team: direTeam},
+		{PlayerSlot: 6, Team: direTeam},
+	}
+	var stats [maxPlayers]playerStats
+	stats[0] = playerStats{netWorth: 1000}
+	stats[5] = playerStats{netWorth: 2000}
+	stats[6] = playerStats{kills: 7, netWorth: 3000, lastHits: 42}
+
+	applyPlayerStats(players, stats)
+
+	if players[0].NetWorth != 1000 || players[1].NetWorth != 2000 {
+		t.Errorf("unexpected net worth %d and %d", players[0].NetWorth, players[1].NetWorth)
+	}
+	if players[2].Kills != 7 || players[2].NetWorth != 3000 || players[2].LastHits != 42 {
+		t.Errorf("unexpected stats for second dire player: %+v", players[2])
+	}
+}
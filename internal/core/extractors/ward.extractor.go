@@ -0,0 +1,141 @@
+package extractors
+
+import (
+	"math"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+
+	"go-glyph/internal/core/models"
+)
+
+// Combat log and ward removal for the same ward are at most this many seconds apart
+const wardKillWindow = 1
+
+var wardClasses = map[string]string{
+	"CDOTA_NPC_Observer_Ward":           models.WardObserver,
+	"CDOTA_NPC_Observer_Ward_TrueSight": models.WardSentry,
+}
+
+var wardUnitNames = map[string]string{
+	"npc_dota_observer_wards": models.WardObserver,
+	"npc_dota_sentry_wards":   models.WardSentry,
+}
+
+// placedWard tracks a ward entity until it is removed from the map
+type placedWard struct {
+	ward     models.Ward
+	placedAt float64
+	removed  bool
+	removeAt float64
+}
+
+// wardKill is a ward death reported by the combat log
+type wardKill struct {
+	wardType   string
+	team       uint64
+	at         float64
+	killer     models.MatchPlayer
+	killerTeam uint64
+	used       bool
+}
+
+// WardExtractor records observer and sentry wards from entity create and delete operations
+type WardExtractor struct {
+	active map[int32]*placedWard
+	wards  []*placedWard
+	kills  []*wardKill
+}
+
+func (x *WardExtractor) Register(ctx *Context) {
+	p := ctx.Parser
+	x.active = make(map[int32]*placedWard)
+
+	p.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
+		wardType, ok := wardClasses[e.GetClassName()]
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case op.Flag(manta.EntityOpCreated):
+			ward := &placedWard{
+				ward:     models.Ward{MatchID: ctx.MatchID, Type: wardType},
+				placedAt: ctx.Clock.Now(),
+			}
+			if team, ok := getEntityInt(e, "m_iTeamNum"); ok {
+				ward.ward.Team = uint64(team)
+			}
+			ward.ward.X, ward.ward.Y = getEntityPosition(e)
+			// Wards are placed before the horn as well
+			ward.ward.Minute, ward.ward.Second = ctx.Clock.SignedMinuteSecond()
+			if owner, ok := e.GetUint64("m_hOwnerEntity"); ok {
+				if player, ok := ctx.Lineup.FindByHeroHandle(owner); ok {
+					ward.ward.Username = player.Username
+					ward.ward.UserSteamID = player.UserSteamID
+					ward.ward.HeroID = player.HeroID
+				}
+			}
+			x.active[e.GetIndex()] = ward
+			x.wards = append(x.wards, ward)
+		case op.Flag(manta.EntityOpDeleted):
+			if ward, ok := x.active[e.GetIndex()]; ok {
+				ward.removed, ward.removeAt = true, ctx.Clock.Now()
+				delete(x.active, e.GetIndex())
+			}
+		}
+		return nil
+	})
+
+	// Ward entities only disappear, the killer is known from the combat log
+	p.Callbacks.OnCMsgDOTACombatLogEntry(func(m *dota.CMsgDOTACombatLogEntry) error {
+		if m.GetType() != dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DEATH {
+			return nil
+		}
+		targetName, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+		wardType, ok := wardUnitNames[targetName]
+		if !ok {
+			return nil
+		}
+		attackerName, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+		killer, _ := ctx.Lineup.FindByHeroUnitName(p, attackerName)
+		x.kills = append(x.kills, &wardKill{
+			wardType:   wardType,
+			team:       uint64(m.GetTargetTeam()),
+			at:         ctx.Clock.Now(),
+			killer:     killer,
+			killerTeam: uint64(m.GetAttackerTeam()),
+		})
+		return nil
+	})
+}
+
+func (x *WardExtractor) Finish(ctx *Context, result *Result) {
+	result.Match.Wards = matchWardKills(x.wards, x.kills, ctx.Clock.Now())
+}
+
+// matchWardKills pairs removed wards with combat log kills, removed wards without a kill have expired.
+// Wards still on the map at the end of the replay live until endTime.
+func matchWardKills(wards []*placedWard, kills []*wardKill, endTime float64) []models.Ward {
+	result := make([]models.Ward, 0, len(wards))
+	for _, ward := range wards {
+		if !ward.removed {
+			ward.removeAt = endTime
+		}
+		ward.ward.Lifetime = uint32(math.Max(math.Round(ward.removeAt-ward.placedAt), 0))
+
+		for _, kill := range kills {
+			if !ward.removed || kill.used || kill.wardType != ward.ward.Type || kill.team != ward.ward.Team ||
+				math.Abs(kill.at-ward.removeAt) > wardKillWindow {
+				continue
+			}
+			kill.used = true
+			ward.ward.KillerTeam = kill.killerTeam
+			ward.ward.KillerUsername = kill.killer.Username
+			ward.ward.KillerSteamID = kill.killer.UserSteamID
+			break
+		}
+		result = append(result, ward.ward)
+	}
+	return result
+}
@@ -0,0 +1,31 @@
+package extractors
+
+import (
+	"testing"
+
+	"go-glyph/internal/core/models"
+)
+
+func TestMatchWardKillsPairsKillsWithRemovedWards(t *testing.T) {
+	killer := models.MatchPlayer{Username: "dewarder", UserSteamID: "76561198000000001", Team: direTeam}
+	wards := []*placedWard{
+		{ward: models.Ward{Type: models.WardObserver, Team: radiantTeam}, placedAt: 100, removed: true, removeAt: 160},
+		{ward: models.Ward{Type: models.WardObserver, Team: radiantTeam}, placedAt: 100, removed: true, removeAt: 460},
+		{ward: models.Ward{Type: models.WardSentry, Team: direTeam}, placedAt: 900},
+	}
+	kills := []*wardKill{
+		{wardType: models.WardObserver, team: radiantTeam, at: 160.5, killer: killer, killerTeam: direTeam},
+	}
+
+	result := matchWardKills(wards, kills, 1000)
+
+	if result[0].KillerSteamID != killer.UserSteamID || result[0].KillerTeam != direTeam || result[0].Lifetime != 60 {
+		t.Errorf("expected first ward to be killed after 60 seconds, got %+v", result[0])
+	}
+	if result[1].KillerTeam != 0 || result[1].Lifetime != 360 {
+		t.Errorf("expected second ward to expire after 360 seconds, got %+v", result[1])
+	}
+	if result[2].KillerTeam != 0 || result[2].Lifetime != 100 {
+		t.Errorf("expected third ward to live until the end of the replay, got %+v", result[2])
+	}
+}
@@ -0,0 +1,115 @@
+package fakesteam
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	dotaproto "github.com/dotabuff/manta/dota"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Match details results of the GC
+const (
+	MatchDetailsFound    uint32 = 1
+	MatchDetailsNotFound uint32 = 2
+)
+
+// GC answers the Dota GC messages of a client, match details come from fixtures
+type GC struct {
+	mu       sync.Mutex
+	matches  map[uint64]*dotaproto.CMsgDOTAMatch
+	silent   bool
+	requests []uint64
+}
+
+func NewGC(matches ...*dotaproto.CMsgDOTAMatch) *GC {
+	gc := &GC{matches: make(map[uint64]*dotaproto.CMsgDOTAMatch)}
+	for _, match := range matches {
+		gc.AddMatch(match)
+	}
+	return gc
+}
+
+// LoadGC reads match fixtures from a JSON array of CMsgDOTAMatch in protobuf JSON
+func LoadGC(path string) (*GC, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []json.RawMessage
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("read GC fixtures %s: %w", path, err)
+	}
+
+	gc := NewGC()
+	for i, fixture := range fixtures {
+		match := new(dotaproto.CMsgDOTAMatch)
+		if err := protojson.Unmarshal(fixture, match); err != nil {
+			return nil, fmt.Errorf("read GC fixture %d of %s: %w", i, path, err)
+		}
+		gc.AddMatch(match)
+	}
+	return gc, nil
+}
+
+func (g *GC) AddMatch(match *dotaproto.CMsgDOTAMatch) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.matches[match.GetMatchId()] = match
+}
+
+// SetSilent stops answering, like a GC without a session for the client
+func (g *GC) SetSilent(silent bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.silent = silent
+}
+
+// MatchRequests lists the requested match IDs in order
+func (g *GC) MatchRequests() []uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]uint64(nil), g.requests...)
+}
+
+// Handle returns the answers to a message of the client
+func (g *GC) Handle(message GCMessage) []GCMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.silent {
+		return nil
+	}
+
+	switch message.MsgType {
+	case uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCClientHello):
+		return []GCMessage{gcMessage(uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCClientWelcome), &dotaproto.CMsgClientWelcome{
+			Version: proto.Uint32(1),
+		})}
+
+	case uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsRequest):
+		request := new(dotaproto.CMsgGCMatchDetailsRequest)
+		if err := proto.Unmarshal(message.Body, request); err != nil {
+			return nil
+		}
+		g.requests = append(g.requests, request.GetMatchId())
+
+		response := &dotaproto.CMsgGCMatchDetailsResponse{Result: proto.Uint32(MatchDetailsNotFound)}
+		if match, ok := g.matches[request.GetMatchId()]; ok {
+			response = &dotaproto.CMsgGCMatchDetailsResponse{Result: proto.Uint32(MatchDetailsFound), Match: match}
+		}
+		answer := gcMessage(uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsResponse), response)
+		answer.TargetJobID = message.SourceJobID
+		return []GCMessage{answer}
+	}
+	return nil
+}
+
+func gcMessage(msgType uint32, body proto.Message) GCMessage {
+	// Messages of the generated Dota types always marshal
+	b, _ := proto.Marshal(body)
+	return GCMessage{MsgType: msgType, Body: b}
+}
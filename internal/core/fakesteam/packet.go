@@ -0,0 +1,195 @@
+package fakesteam
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// EMsgs of the CM messages the fake handles
+const (
+	EMsgClientHeartBeat     uint32 = 703
+	EMsgClientLogOff        uint32 = 706
+	EMsgClientLogOnResponse uint32 = 751
+	EMsgClientToGC          uint32 = 5452
+	EMsgClientFromGC        uint32 = 5453
+	EMsgClientLogon         uint32 = 5514
+	EMsgClientHello         uint32 = 9805
+)
+
+// protoMask flags an EMsg or GC message type whose header and body are protobuf
+const protoMask uint32 = 0x80000000
+
+var errShortPacket = errors.New("packet is shorter than its header")
+
+// Packet is a protobuf CM message, or a protobuf GC message inside one
+type Packet struct {
+	EMsg   uint32
+	Header []byte // CMsgProtoBufHeader
+	Body   []byte
+}
+
+// ParsePacket reads a message of an EMsg, a header length, the header and the body
+func ParsePacket(b []byte) (Packet, error) {
+	if len(b) < 8 {
+		return Packet{}, errShortPacket
+	}
+	eMsg := binary.LittleEndian.Uint32(b)
+	if eMsg&protoMask == 0 {
+		return Packet{}, errors.New("only protobuf messages are supported")
+	}
+	headerLength := binary.LittleEndian.Uint32(b[4:])
+	if uint64(len(b)-8) < uint64(headerLength) {
+		return Packet{}, errShortPacket
+	}
+	return Packet{EMsg: eMsg &^ protoMask, Header: b[8 : 8+headerLength], Body: b[8+headerLength:]}, nil
+}
+
+// Bytes encodes the packet as a protobuf message
+func (p Packet) Bytes() []byte {
+	b := binary.LittleEndian.AppendUint32(nil, p.EMsg|protoMask)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(p.Header)))
+	b = append(b, p.Header...)
+	return append(b, p.Body...)
+}
+
+// cmHeader is a CMsgProtoBufHeader with the steam ID and session of the client
+func cmHeader(steamID uint64, sessionID int32) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, steamID)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(sessionID))
+}
+
+// Job fields of a CMsgProtoBufHeader
+const (
+	gcHeaderJobIDSource protowire.Number = 10
+	gcHeaderJobIDTarget protowire.Number = 11
+)
+
+// GCMessage is a message between the client and the Dota GC, without the CM envelope
+type GCMessage struct {
+	MsgType uint32
+	// Jobs of the message, an answer targets the source job of the request. 0 if there is none.
+	SourceJobID uint64
+	TargetJobID uint64
+	Body        []byte
+}
+
+// header is the CMsgProtoBufHeader with the jobs of the message
+func (m GCMessage) header() []byte {
+	var b []byte
+	if m.SourceJobID != 0 {
+		b = protowire.AppendTag(b, gcHeaderJobIDSource, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, m.SourceJobID)
+	}
+	if m.TargetJobID != 0 {
+		b = protowire.AppendTag(b, gcHeaderJobIDTarget, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, m.TargetJobID)
+	}
+	return b
+}
+
+// gcClientMessage is the CMsgGCClient envelope of ClientToGC and ClientFromGC
+type gcClientMessage struct {
+	AppID   uint32
+	Message GCMessage
+}
+
+func parseGCClientMessage(b []byte) (gcClientMessage, error) {
+	var message gcClientMessage
+	var payload []byte
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return message, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case number == 1 && wireType == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			message.AppID = uint32(v)
+		case number == 3 && wireType == protowire.BytesType:
+			payload, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(number, wireType, b)
+		}
+		if n < 0 {
+			return message, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+
+	packet, err := ParsePacket(payload)
+	if err != nil {
+		return message, err
+	}
+	message.Message = GCMessage{
+		MsgType:     packet.EMsg,
+		SourceJobID: fixed64Field(packet.Header, gcHeaderJobIDSource),
+		TargetJobID: fixed64Field(packet.Header, gcHeaderJobIDTarget),
+		Body:        packet.Body,
+	}
+	return message, nil
+}
+
+func (m gcClientMessage) Bytes() []byte {
+	payload := Packet{EMsg: m.Message.MsgType, Header: m.Message.header(), Body: m.Message.Body}.Bytes()
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.AppID))
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Message.MsgType|protoMask))
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	return protowire.AppendBytes(b, payload)
+}
+
+// stringField returns the last value of a string field of a protobuf message
+func stringField(b []byte, field protowire.Number) string {
+	var value string
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return value
+		}
+		b = b[n:]
+		if number == field && wireType == protowire.BytesType {
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			value = string(v)
+		} else {
+			n = protowire.ConsumeFieldValue(number, wireType, b)
+		}
+		if n < 0 {
+			return value
+		}
+		b = b[n:]
+	}
+	return value
+}
+
+// fixed64Field returns the last value of a fixed64 field of a protobuf message, 0 if it is not set
+func fixed64Field(b []byte, field protowire.Number) uint64 {
+	var value uint64
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return value
+		}
+		b = b[n:]
+		if number == field && wireType == protowire.Fixed64Type {
+			value, n = protowire.ConsumeFixed64(b)
+		} else {
+			n = protowire.ConsumeFieldValue(number, wireType, b)
+		}
+		if n < 0 {
+			return value
+		}
+		b = b[n:]
+	}
+	return value
+}
@@ -0,0 +1,243 @@
+// Package fakesteam is an in-process Steam CM websocket server with a Dota GC behind it, for tests
+// of the Steam client without network access.
+package fakesteam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// EResults of a logon
+const (
+	ResultOK              int32 = 1
+	ResultInvalidPassword int32 = 5
+)
+
+const (
+	dotaAppID      uint32 = 570
+	firstSteamID   uint64 = 76561197960265729
+	heartbeatDelay        = 9
+)
+
+// Logon is a ClientLogon received by the server
+type Logon struct {
+	Username    string
+	AccessToken string
+	Result      int32
+}
+
+// Server is a fake CM accepting websocket connections at /cmsocket/
+type Server struct {
+	GC *GC
+
+	httpServer *httptest.Server
+
+	mu sync.Mutex
+	// refreshTokens are the only tokens accepted for an account, any token is accepted for the others
+	refreshTokens map[string]string
+	conns         map[*wsConn]bool
+	logons        []Logon
+	logoffs       []string
+	received      []uint32
+	sessions      int32
+}
+
+// NewServer starts a plain websocket server
+func NewServer(gc *GC) *Server {
+	s := newServer(gc)
+	s.httpServer = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts a secure websocket server, Client trusts its certificate
+func NewTLSServer(gc *GC) *Server {
+	s := newServer(gc)
+	s.httpServer = httptest.NewTLSServer(s)
+	return s
+}
+
+func newServer(gc *GC) *Server {
+	return &Server{GC: gc, refreshTokens: make(map[string]string), conns: make(map[*wsConn]bool)}
+}
+
+// Endpoint is the host and port of the server, as in the CM list of the Steam directory
+func (s *Server) Endpoint() string {
+	return strings.TrimPrefix(strings.TrimPrefix(s.httpServer.URL, "https://"), "http://")
+}
+
+// URL is the websocket URL of the CM
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.httpServer.URL, "http") + "/cmsocket/"
+}
+
+// Client is an HTTP client trusting the certificate of a TLS server
+func (s *Server) Client() *http.Client {
+	return s.httpServer.Client()
+}
+
+// RequireRefreshToken makes logons of the account fail unless they carry the token
+func (s *Server) RequireRefreshToken(username, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshTokens[username] = token
+}
+
+// Logons lists the logons in order
+func (s *Server) Logons() []Logon {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Logon(nil), s.logons...)
+}
+
+// LogOffs lists the accounts that logged off in order
+func (s *Server) LogOffs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logoffs...)
+}
+
+// Received lists the EMsgs of every message received in order
+func (s *Server) Received() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint32(nil), s.received...)
+}
+
+// Connections counts the open connections
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Disconnect drops every connection without a closing handshake, like a CM going away
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) Close() {
+	s.Disconnect()
+	s.httpServer.Close()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/cmsocket/" {
+		http.NotFound(w, r)
+		return
+	}
+	conn, err := acceptWebSocket(w, r)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.conns[conn] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	session := &cmSession{server: s, conn: conn}
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		packet, err := ParsePacket(message)
+		if err != nil {
+			return
+		}
+		if !session.handle(packet) {
+			return
+		}
+	}
+}
+
+// cmSession is the state of one client connection
+type cmSession struct {
+	server    *Server
+	conn      *wsConn
+	username  string
+	steamID   uint64
+	sessionID int32
+}
+
+// handle answers a message of the client, returning false when the connection ends
+func (c *cmSession) handle(packet Packet) bool {
+	s := c.server
+	s.mu.Lock()
+	s.received = append(s.received, packet.EMsg)
+	s.mu.Unlock()
+
+	switch packet.EMsg {
+	case EMsgClientLogon:
+		return c.logOn(packet)
+
+	case EMsgClientLogOff:
+		s.mu.Lock()
+		s.logoffs = append(s.logoffs, c.username)
+		s.mu.Unlock()
+		return false
+
+	case EMsgClientToGC:
+		message, err := parseGCClientMessage(packet.Body)
+		if err != nil || message.AppID != dotaAppID || c.steamID == 0 {
+			return true
+		}
+		for _, answer := range s.GC.Handle(message.Message) {
+			response := Packet{
+				EMsg:   EMsgClientFromGC,
+				Header: cmHeader(c.steamID, c.sessionID),
+				Body:   gcClientMessage{AppID: dotaAppID, Message: answer}.Bytes(),
+			}
+			if err := c.conn.WriteMessage(response.Bytes()); err != nil {
+				return false
+			}
+		}
+	}
+	// Hellos, heartbeats, games played and persona states need no answer
+	return true
+}
+
+// logOn accepts the ClientLogon unless the account requires another refresh token
+func (c *cmSession) logOn(packet Packet) bool {
+	s := c.server
+	// CMsgClientLogon account_name and access_token
+	logon := Logon{Username: stringField(packet.Body, 50), AccessToken: stringField(packet.Body, 108), Result: ResultOK}
+
+	s.mu.Lock()
+	if token, ok := s.refreshTokens[logon.Username]; ok && token != logon.AccessToken {
+		logon.Result = ResultInvalidPassword
+	}
+	s.logons = append(s.logons, logon)
+	if logon.Result == ResultOK {
+		s.sessions++
+		c.username = logon.Username
+		c.steamID = firstSteamID + uint64(s.sessions)
+		c.sessionID = s.sessions
+	}
+	s.mu.Unlock()
+
+	// CMsgClientLogonResponse eresult and heartbeat seconds
+	var body []byte
+	body = protowire.AppendTag(body, 1, protowire.VarintType)
+	body = protowire.AppendVarint(body, uint64(logon.Result))
+	body = protowire.AppendTag(body, 2, protowire.VarintType)
+	body = protowire.AppendVarint(body, heartbeatDelay)
+	body = protowire.AppendTag(body, 3, protowire.VarintType)
+	body = protowire.AppendVarint(body, heartbeatDelay)
+
+	response := Packet{EMsg: EMsgClientLogOnResponse, Header: cmHeader(c.steamID, c.sessionID), Body: body}
+	return c.conn.WriteMessage(response.Bytes()) == nil && logon.Result == ResultOK
+}
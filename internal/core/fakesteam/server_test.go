@@ -0,0 +1,204 @@
+package fakesteam
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	dotaproto "github.com/dotabuff/manta/dota"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// dialWebSocket opens a client connection to a plain websocket URL
+func dialWebSocket(t *testing.T, rawURL string) *wsConn {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatalf("cannot connect to the fake CM: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	_, err = fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.Path, u.Host, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("cannot read the handshake response: %v", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		t.Fatalf("unexpected handshake response %s %v", response.Status, response.Header)
+	}
+	return &wsConn{conn: conn, reader: reader, client: true}
+}
+
+func writePacket(t *testing.T, conn *wsConn, packet Packet) {
+	t.Helper()
+	if err := conn.WriteMessage(packet.Bytes()); err != nil {
+		t.Fatalf("cannot write to the fake CM: %v", err)
+	}
+}
+
+func readPacket(t *testing.T, conn *wsConn) Packet {
+	t.Helper()
+	_ = conn.conn.SetReadDeadline(time.Now().Add(time.Second))
+	message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("cannot read from the fake CM: %v", err)
+	}
+	packet, err := ParsePacket(message)
+	if err != nil {
+		t.Fatalf("cannot parse the message of the fake CM: %v", err)
+	}
+	return packet
+}
+
+func logonPacket(username, token string) Packet {
+	var body []byte
+	body = protowire.AppendTag(body, 50, protowire.BytesType)
+	body = protowire.AppendString(body, username)
+	body = protowire.AppendTag(body, 108, protowire.BytesType)
+	body = protowire.AppendString(body, token)
+	return Packet{EMsg: EMsgClientLogon, Body: body}
+}
+
+func logOnResult(t *testing.T, packet Packet) int32 {
+	t.Helper()
+	if packet.EMsg != EMsgClientLogOnResponse {
+		t.Fatalf("expected a logon response, got EMsg %d", packet.EMsg)
+	}
+	_, _, n := protowire.ConsumeTag(packet.Body)
+	result, _ := protowire.ConsumeVarint(packet.Body[n:])
+	return int32(result)
+}
+
+func toGC(msgType uint32, body proto.Message, sourceJobID uint64) Packet {
+	message := gcMessage(msgType, body)
+	message.SourceJobID = sourceJobID
+	return Packet{EMsg: EMsgClientToGC, Body: gcClientMessage{AppID: dotaAppID, Message: message}.Bytes()}
+}
+
+func fromGC(t *testing.T, packet Packet) GCMessage {
+	t.Helper()
+	if packet.EMsg != EMsgClientFromGC {
+		t.Fatalf("expected a GC message, got EMsg %d", packet.EMsg)
+	}
+	message, err := parseGCClientMessage(packet.Body)
+	if err != nil {
+		t.Fatalf("cannot parse the GC message: %v", err)
+	}
+	return message.Message
+}
+
+func TestServerLogsOnAndAnswersFromFixtures(t *testing.T) {
+	gc, err := LoadGC("testdata/matches.json")
+	if err != nil {
+		t.Fatalf("LoadGC returned error: %v", err)
+	}
+	server := NewServer(gc)
+	t.Cleanup(server.Close)
+	conn := dialWebSocket(t, server.URL())
+
+	writePacket(t, conn, Packet{EMsg: EMsgClientHello})
+	writePacket(t, conn, logonPacket("glyph", "refresh-token"))
+	if result := logOnResult(t, readPacket(t, conn)); result != ResultOK {
+		t.Fatalf("expected the logon to succeed, got EResult %d", result)
+	}
+
+	writePacket(t, conn, toGC(uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCClientHello), &dotaproto.CMsgClientHello{}, 0))
+	if welcome := fromGC(t, readPacket(t, conn)); welcome.MsgType != uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCClientWelcome) {
+		t.Fatalf("expected the GC welcome, got message type %d", welcome.MsgType)
+	}
+
+	for matchID, want := range map[uint64]uint32{7500000001: MatchDetailsFound, 1: MatchDetailsNotFound} {
+		writePacket(t, conn, toGC(uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsRequest), &dotaproto.CMsgGCMatchDetailsRequest{
+			MatchId: proto.Uint64(matchID),
+		}, matchID))
+		message := fromGC(t, readPacket(t, conn))
+		if message.TargetJobID != matchID {
+			t.Fatalf("expected the answer to target job %d, got %d", matchID, message.TargetJobID)
+		}
+		response := new(dotaproto.CMsgGCMatchDetailsResponse)
+		if err := proto.Unmarshal(message.Body, response); err != nil {
+			t.Fatalf("cannot decode match details: %v", err)
+		}
+		if response.GetResult() != want {
+			t.Fatalf("expected result %d for match %d, got %d", want, matchID, response.GetResult())
+		}
+		if want == MatchDetailsFound && (response.GetMatch().GetCluster() != 236 || response.GetMatch().GetReplaySalt() != 1183751) {
+			t.Fatalf("expected the fixture of match %d, got %v", matchID, response.GetMatch())
+		}
+	}
+
+	if logons := server.Logons(); len(logons) != 1 || logons[0].Username != "glyph" || logons[0].AccessToken != "refresh-token" {
+		t.Fatalf("expected the logon of glyph, got %+v", logons)
+	}
+	if requests := gc.MatchRequests(); len(requests) != 2 {
+		t.Fatalf("expected 2 match requests, got %v", requests)
+	}
+}
+
+func TestServerRejectsOtherRefreshTokens(t *testing.T) {
+	server := NewServer(NewGC())
+	t.Cleanup(server.Close)
+	server.RequireRefreshToken("glyph", "valid")
+	conn := dialWebSocket(t, server.URL())
+
+	writePacket(t, conn, logonPacket("glyph", "expired"))
+	if result := logOnResult(t, readPacket(t, conn)); result != ResultInvalidPassword {
+		t.Fatalf("expected the logon to be rejected, got EResult %d", result)
+	}
+	// Steam closes the connection of a failed logon
+	if _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected the connection to be closed")
+	}
+}
+
+func TestServerDisconnectAndLogOff(t *testing.T) {
+	server := NewServer(NewGC())
+	t.Cleanup(server.Close)
+
+	dropped := dialWebSocket(t, server.URL())
+	writePacket(t, dropped, logonPacket("dropped", ""))
+	readPacket(t, dropped)
+	server.Disconnect()
+	if _, err := dropped.ReadMessage(); err == nil {
+		t.Fatal("expected the connection to be dropped")
+	}
+
+	conn := dialWebSocket(t, server.URL())
+	writePacket(t, conn, logonPacket("glyph", ""))
+	readPacket(t, conn)
+	writePacket(t, conn, Packet{EMsg: EMsgClientLogOff})
+	if _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected the connection to end after logging off")
+	}
+	if logoffs := server.LogOffs(); len(logoffs) != 1 || logoffs[0] != "glyph" {
+		t.Fatalf("expected glyph to log off, got %v", logoffs)
+	}
+}
+
+func TestGCSilent(t *testing.T) {
+	gc := NewGC()
+	gc.SetSilent(true)
+	if answers := gc.Handle(gcMessage(uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCClientHello), &dotaproto.CMsgClientHello{})); len(answers) != 0 {
+		t.Fatalf("expected a silent GC not to answer, got %v", answers)
+	}
+}
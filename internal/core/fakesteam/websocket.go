@@ -0,0 +1,196 @@
+package fakesteam
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// WebSocket opcodes of RFC 6455
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds a message read from a peer, CM messages are far smaller
+const maxMessageSize = 16 << 20
+
+// wsConn is the part of RFC 6455 the fake CM needs: binary messages, ping and close.
+// Clients mask the frames they write, servers do not.
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool
+
+	writeMu sync.Mutex
+}
+
+// acceptWebSocket completes the opening handshake of a request and takes over its connection
+func acceptWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("not a websocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be hijacked", http.StatusInternalServerError)
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", webSocketAccept(key))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ReadMessage returns the next data message, answering pings on the way. It returns io.EOF once the peer closes.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			_ = c.writeFrame(opClose, nil)
+			return nil, io.EOF
+		case opText, opBinary, opContinuation:
+			if len(message)+len(payload) > maxMessageSize {
+				return nil, errors.New("websocket message too large")
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown websocket opcode %d", opcode)
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxMessageSize {
+		err = errors.New("websocket frame too large")
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// WriteMessage writes a binary message in a single frame
+func (c *wsConn) WriteMessage(message []byte) error {
+	return c.writeFrame(opBinary, message)
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode, 0}
+	switch {
+	case len(payload) < 126:
+		frame[1] = byte(len(payload))
+	case len(payload) <= 0xffff:
+		frame[1] = 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame[1] = 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame[1] |= 0x80
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close drops the connection without a closing handshake, like a lost connection
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
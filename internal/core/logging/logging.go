@@ -0,0 +1,182 @@
+// Package logging builds the structured loggers of the server. Every subsystem logs at its own level, lines get the
+// request, match and trace IDs of their context, and attributes named like secrets are redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"go-glyph/internal/core/tracing"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Subsystems of the server, each logs at its own level
+const (
+	SubsystemApp      = "app"
+	SubsystemHTTP     = "http"
+	SubsystemSteam    = "steam"
+	SubsystemReplay   = "replay"
+	SubsystemDatabase = "database"
+)
+
+var subsystems = []string{SubsystemApp, SubsystemHTTP, SubsystemSteam, SubsystemReplay, SubsystemDatabase}
+
+// Keys of the attributes every line of a subsystem or a request gets
+const (
+	SubsystemKey = "subsystem"
+	RequestIDKey = "request_id"
+	MatchIDKey   = "match_id"
+	TraceIDKey   = "trace_id"
+)
+
+// Redacted replaces the values of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are parts of attribute keys whose values are never logged
+var sensitiveKeys = []string{"password", "token", "secret", "guard_code", "authorization", "cookie", "session_key"}
+
+// Config configures the loggers, zero values log text at info level
+type Config struct {
+	// Format is text or json
+	Format string
+	// Level of the subsystems without a level of their own
+	Level string
+	// Levels of single subsystems as comma separated subsystem=level pairs, e.g. steam=debug,http=warn
+	Levels string
+}
+
+// Loggers creates the logger of each subsystem, all writing to the same output
+type Loggers struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level
+}
+
+// New creates the loggers writing to w
+func New(w io.Writer, config Config) (*Loggers, error) {
+	loggers := &Loggers{levels: make(map[string]slog.Level)}
+	if config.Level != "" {
+		if err := loggers.level.UnmarshalText([]byte(config.Level)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pair := range strings.Split(config.Levels, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		subsystem, levelName, ok := strings.Cut(pair, "=")
+		subsystem = strings.TrimSpace(subsystem)
+		if !ok || !slices.Contains(subsystems, subsystem) {
+			return nil, fmt.Errorf("invalid subsystem level %q, expected <subsystem>=<level> with subsystem one of %s",
+				pair, strings.Join(subsystems, ", "))
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(levelName))); err != nil {
+			return nil, err
+		}
+		loggers.levels[subsystem] = level
+	}
+
+	// Levels are checked per subsystem, the shared handler lets everything through
+	options := &slog.HandlerOptions{Level: slog.Level(math.MinInt), ReplaceAttr: redact}
+	switch config.Format {
+	case "", FormatText:
+		loggers.handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		loggers.handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected %s or %s", config.Format, FormatText, FormatJSON)
+	}
+	return loggers, nil
+}
+
+// Logger returns the logger of the subsystem
+func (l *Loggers) Logger(subsystem string) *slog.Logger {
+	level, ok := l.levels[subsystem]
+	if !ok {
+		level = l.level
+	}
+	return slog.New(&contextHandler{handler: l.handler, level: level}).With(SubsystemKey, subsystem)
+}
+
+// redact replaces the values of attributes named like secrets, including those of groups and LogValuers
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
+
+type contextKey int
+
+const (
+	requestIDContextKey contextKey = iota
+	matchIDContextKey
+)
+
+// WithRequestID returns a context whose log lines carry the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestID returns the request ID of ctx, empty if it has none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// WithMatchID returns a context whose log lines carry the match ID
+func WithMatchID(ctx context.Context, matchID int) context.Context {
+	return context.WithValue(ctx, matchIDContextKey, matchID)
+}
+
+// contextHandler drops the records below the level of its subsystem and adds the IDs of the context to the others
+type contextHandler struct {
+	handler slog.Handler
+	level   slog.Level
+}
+
+func (h *contextHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		var attributes []slog.Attr
+		if requestID := RequestID(ctx); requestID != "" {
+			attributes = append(attributes, slog.String(RequestIDKey, requestID))
+		}
+		if matchID, ok := ctx.Value(matchIDContextKey).(int); ok {
+			attributes = append(attributes, slog.Int(MatchIDKey, matchID))
+		}
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			attributes = append(attributes, slog.String(TraceIDKey, traceID))
+		}
+		if len(attributes) > 0 {
+			record = record.Clone()
+			record.AddAttrs(attributes...)
+		}
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attributes []slog.Attr) slog.Handler {
+	return &contextHandler{handler: h.handler.WithAttrs(attributes), level: h.level}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// decodeLines returns the JSON log lines written to buf
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var decoded map[string]any
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, decoded)
+	}
+	return lines
+}
+
+func TestLevelsPerSubsystem(t *testing.T) {
+	var buf bytes.Buffer
+	loggers, err := New(&buf, Config{Format: FormatJSON, Level: "warn", Levels: "steam=debug, http=error"})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	loggers.Logger(SubsystemSteam).Debug("steam debug")
+	loggers.Logger(SubsystemHTTP).Warn("http warning")
+	loggers.Logger(SubsystemReplay).Info("replay info")
+	loggers.Logger(SubsystemReplay).Warn("replay warning")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 || lines[0]["msg"] != "steam debug" || lines[1]["msg"] != "replay warning" {
+		t.Fatalf("expected the steam debug line and the replay warning, got %v", lines)
+	}
+	if lines[0][SubsystemKey] != SubsystemSteam || lines[1][SubsystemKey] != SubsystemReplay {
+		t.Fatalf("expected the subsystem on every line, got %v", lines)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Format: "xml"},
+		{Level: "loud"},
+		{Levels: "parser=debug"},
+		{Levels: "steam"},
+		{Levels: "steam=loud"},
+	} {
+		if _, err := New(&bytes.Buffer{}, config); err == nil {
+			t.Errorf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	loggers, err := New(&buf, Config{Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	ctx := WithMatchID(WithRequestID(context.Background(), "request-1"), 1234)
+	logger := loggers.Logger(SubsystemReplay).With("source", "valve")
+	logger.InfoContext(ctx, "retrieved")
+	logger.Info("without context")
+
+	lines := decodeLines(t, &buf)
+	if lines[0][RequestIDKey] != "request-1" || lines[0][MatchIDKey] != float64(1234) || lines[0]["source"] != "valve" {
+		t.Fatalf("expected the request and match IDs of the context, got %v", lines[0])
+	}
+	if _, ok := lines[1][RequestIDKey]; ok {
+		t.Fatalf("expected no request ID without context, got %v", lines[1])
+	}
+}
+
+// account is logged as a whole by mistake
+type account struct {
+	Username string
+	Password string
+}
+
+func (a account) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", a.Username), slog.String("password", a.Password))
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON} {
+		var buf bytes.Buffer
+		loggers, err := New(&buf, Config{Format: format})
+		if err != nil {
+			t.Fatalf("New returned error: %v", err)
+		}
+
+		loggers.Logger(SubsystemSteam).Info("logging in",
+			"password", "hunter2",
+			"refresh_token", "eyJhbGciOi",
+			"Shared_Secret", "c2VjcmV0",
+			"guard_code", "F4K3C",
+			slog.Group("request", slog.String("Authorization", "Bearer admin-token")),
+			"account", account{Username: "glyph", Password: "hunter3"},
+		)
+
+		line := buf.String()
+		for _, secret := range []string{"hunter2", "eyJhbGciOi", "c2VjcmV0", "F4K3C", "admin-token", "hunter3"} {
+			if strings.Contains(line, secret) {
+				t.Errorf("%s line leaks %q: %s", format, secret, line)
+			}
+		}
+		if !strings.Contains(line, "glyph") || !strings.Contains(line, Redacted) {
+			t.Errorf("expected the username and redacted values in the %s line, got %s", format, line)
+		}
+	}
+}
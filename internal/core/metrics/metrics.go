@@ -0,0 +1,92 @@
+// Package metrics holds the Prometheus metrics of the parse pipeline, served on /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "glyph"
+
+// Outcomes of a match requested from the glyph API
+const (
+	OutcomeCached        = "cached"
+	OutcomeParsed        = "parsed"
+	OutcomeInProgress    = "in_progress"
+	OutcomeTooOld        = "too_old"
+	OutcomeGCUnavailable = "gc_unavailable"
+	OutcomeParseError    = "parse_error"
+	OutcomeCanceled      = "canceled"
+	OutcomeError         = "error"
+)
+
+// Registry holds every metric of the server, with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// stageBuckets span the stages of the pipeline, from a GC answer in milliseconds to a parse of several minutes
+var stageBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+var (
+	GCLookupDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gc_lookup_duration_seconds",
+		Help:      "Duration of match detail lookups let through by the circuit breaker, retries included.",
+		Buckets:   stageBuckets,
+	})
+	DownloadDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "replay_download_duration_seconds",
+		Help:      "Time spent reading replays from their source, by source.",
+		Buckets:   stageBuckets,
+	}, []string{"source"})
+	DecompressDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "replay_decompress_duration_seconds",
+		Help:      "Time spent decompressing and writing bzip2 replays, besides reading them.",
+		Buckets:   stageBuckets,
+	})
+	ParseDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "replay_parse_duration_seconds",
+		Help:      "Duration of replay parses.",
+		Buckets:   stageBuckets,
+	})
+	Matches = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "matches_total",
+		Help:      "Matches requested, by outcome.",
+	}, []string{"outcome"})
+	MatchesInProgress = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "matches_in_progress",
+		Help:      "Matches being looked up, downloaded or parsed.",
+	})
+	ReplayBytes = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "replay_downloaded_bytes_total",
+		Help:      "Bytes of replays downloaded, by Valve cluster or mirror.",
+	}, []string{"target"})
+	DownloadsWaiting = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "replay_downloads_waiting",
+		Help:      "Downloads queued for a free slot of their Valve cluster or mirror.",
+	})
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests answered, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests, by method and route.",
+		Buckets:   stageBuckets,
+	}, []string{"method", "route"})
+)
@@ -0,0 +1,35 @@
+package models
+
+type Match struct {
+	MatchID    int           `gorm:"primaryKey;autoIncrement:false"`
+	GameMode   int32         `gorm:"not null;default:0"` // Captains Mode is 2 (https://github.com/odota/dotaconstants/blob/master/json/game_mode.json)
+	Winner     uint64        `gorm:"not null;default:0"` // Radiant team is 2 and dire team is 3, 0 if unknown
+	Duration   uint32        `gorm:"not null;default:0"` // Game time in seconds from the horn to the end of the game
+	Players    []MatchPlayer `gorm:"foreignKey:MatchID;references:MatchID;constraint:OnDelete:CASCADE"`
+	Draft      []DraftPick   `gorm:"foreignKey:MatchID;references:MatchID;constraint:OnDelete:CASCADE"`
+	Objectives []Objective   `gorm:"foreignKey:MatchID;references:MatchID;constraint:OnDelete:CASCADE"`
+	Wards      []Ward        `gorm:"foreignKey:MatchID;references:MatchID;constraint:OnDelete:CASCADE"`
+}
+
+type MatchPlayer struct {
+	MatchID     int    `gorm:"not null;default:null"`
+	PlayerSlot  uint32 `gorm:"not null;default:0"` // Index of player in CDOTA_PlayerResource (0-9)
+	Username    string `gorm:"not null;default:''"`
+	UserSteamID string `gorm:"not null;default:null"`
+	Team        uint64 `gorm:"not null;default:2"` // Radiant team is 2 and dire team is 3
+	HeroID      uint32 `gorm:"not null;default:0"` // ID of hero (https://liquipedia.net/dota2/MediaWiki:Dota2webapi-heroes.json)
+	Kills       uint32 `gorm:"not null;default:0"`
+	Deaths      uint32 `gorm:"not null;default:0"`
+	Assists     uint32 `gorm:"not null;default:0"`
+	NetWorth    uint32 `gorm:"not null;default:0"`
+	LastHits    uint32 `gorm:"not null;default:0"`
+	Level       uint32 `gorm:"not null;default:0"`
+}
+
+type DraftPick struct {
+	MatchID int    `gorm:"not null;default:null"`
+	Order   uint32 `gorm:"not null;default:0"` // Order in which the hero was picked or banned, starting from 0
+	IsPick  bool   `gorm:"not null;default:false"`
+	Team    uint64 `gorm:"not null;default:0"` // Radiant team is 2 and dire team is 3, 0 if unknown
+	HeroID  uint32 `gorm:"not null;default:0"`
+}
@@ -0,0 +1,21 @@
+package models
+
+const (
+	ObjectiveRoshanKill    = "roshan_kill"
+	ObjectiveAegisPickup   = "aegis_pickup"
+	ObjectiveAegisStolen   = "aegis_stolen"
+	ObjectiveAegisDenied   = "aegis_denied"
+	ObjectiveAegisExpired  = "aegis_expired"
+	ObjectiveTormentorKill = "tormentor_kill"
+)
+
+type Objective struct {
+	MatchID     int    `gorm:"not null;default:null"`
+	Type        string `gorm:"not null;default:null"` // One of roshan_kill, aegis_pickup, aegis_stolen, aegis_denied, aegis_expired, tormentor_kill
+	Team        uint64 `gorm:"not null;default:0"`    // Radiant team is 2 and dire team is 3, 0 if unknown
+	Username    string `gorm:"not null;default:''"`   // Empty if no player is involved (e.g. Roshan killed by creeps)
+	UserSteamID string `gorm:"not null;default:''"`
+	HeroID      uint32 `gorm:"not null;default:0"`
+	Minute      uint32 `gorm:"not null;default:0"`
+	Second      uint32 `gorm:"not null;default:0"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SteamSession is the refresh token of a Steam account, so it is not logged in with its password on every start
+type SteamSession struct {
+	Username     string    `gorm:"primaryKey"`
+	RefreshToken []byte    `gorm:"not null"` // Encrypted with STEAM_SESSION_KEY, prefixed by the nonce
+	ExpiresAt    time.Time // Zero if the token does not tell
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+const (
+	WardObserver = "observer"
+	WardSentry   = "sentry"
+)
+
+type Ward struct {
+	MatchID        int     `gorm:"not null;default:null"`
+	Type           string  `gorm:"not null;default:null"` // observer or sentry
+	Team           uint64  `gorm:"not null;default:2"`    // Radiant team is 2 and dire team is 3
+	X              float32 `gorm:"not null;default:0"`    // World coordinates, map center is 0,0
+	Y              float32 `gorm:"not null;default:0"`
+	Username       string  `gorm:"not null;default:''"` // Player who placed the ward
+	UserSteamID    string  `gorm:"not null;default:''"`
+	HeroID         uint32  `gorm:"not null;default:0"`
+	KillerUsername string  `gorm:"not null;default:''"` // Empty if the ward expired or the killer is not a hero
+	KillerSteamID  string  `gorm:"not null;default:''"`
+	KillerTeam     uint64  `gorm:"not null;default:0"` // 0 if the ward was not killed
+	Minute         int32   `gorm:"not null;default:0"` // Game time when the ward was placed, negative before the horn (-0:45 is 0 and -45)
+	Second         int32   `gorm:"not null;default:0"`
+	Lifetime       uint32  `gorm:"not null;default:0"` // Seconds the ward stayed on the map
+}
@@ -0,0 +1,470 @@
+// Package pbzip2 decompresses bzip2 streams on several cores.
+//
+// Blocks of a bzip2 stream are independent, but they start at arbitrary bit offsets.
+// The stream is scanned for the block magic, each block is wrapped into a standalone
+// single block stream and decompressed by compress/bzip2 in parallel, and the output
+// is returned in the original order. The stream checksum is verified once all blocks are read.
+//
+// The 48 bit block magic could in theory also appear inside compressed data, the block
+// is then split in two and fails its checksum. A block that fails is merged with the blocks
+// following it and decompressed again, so only a stream that is corrupt returns an error.
+// An end of stream magic inside compressed data is recognized as it is not followed by the
+// end of the input or another stream.
+package pbzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+const (
+	blockMagic = 0x314159265359
+	eosMagic   = 0x177245385090
+	magicBits  = 48
+	magicMask  = 1<<magicBits - 1
+	crcBits    = 32
+
+	// readSize is the size of reads from the compressed stream
+	readSize = 64 << 10
+
+	// maxBlockBits bounds the merged blocks per compression level, a compressed block
+	// is at most slightly larger than its up to level * 100k bytes
+	maxBlockBits = 2 * 100000 * 8
+)
+
+// StructuralError is returned when the stream is not valid bzip2
+type StructuralError string
+
+func (e StructuralError) Error() string {
+	return "pbzip2 data invalid: " + string(e)
+}
+
+// block is the compressed bits of one block, data starts at the byte of the first bit
+type block struct {
+	data  []byte
+	shift uint
+	nbits int64
+	crc   uint32
+	level byte
+}
+
+// append returns the block followed by the bits of next, with the checksum of the block
+func (b block) append(next block) block {
+	w := bitWriter{buf: make([]byte, 0, len(b.data)+len(next.data))}
+	w.copyBits(b.data, b.shift, b.nbits)
+	w.copyBits(next.data, next.shift, next.nbits)
+	w.flush()
+	return block{data: w.buf, nbits: b.nbits + next.nbits, crc: b.crc, level: b.level}
+}
+
+// blockResult is a decompressed block, or the end of a stream with its combined checksum
+type blockResult struct {
+	data      []byte
+	err       error
+	block     *block
+	streamEnd bool
+	streamCRC uint32
+}
+
+// Reader decompresses a bzip2 stream, it implements io.ReadCloser
+type Reader struct {
+	results chan chan blockResult
+	done    chan struct{}
+	wg      sync.WaitGroup
+	current []byte
+	err     error
+
+	// combinedCRC is the checksum of the blocks read of the current stream
+	combinedCRC uint32
+}
+
+// NewReader decompresses r using the given number of workers, GOMAXPROCS if not positive.
+// Close stops decompression when the stream is not read to the end.
+func NewReader(r io.Reader, workers int) *Reader {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	reader := &Reader{
+		// Bounds the blocks decompressed ahead of the consumer
+		results: make(chan chan blockResult, 2*workers),
+		done:    make(chan struct{}),
+	}
+	s := &scanner{r: r, reader: reader, workers: make(chan struct{}, workers)}
+	reader.wg.Add(1)
+	go func() {
+		defer reader.wg.Done()
+		s.run()
+	}()
+	return reader
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		future, ok := <-r.results
+		if !ok {
+			r.err = io.EOF
+			continue
+		}
+		result := <-future
+		if result.err != nil && result.block != nil {
+			result = r.merge(result)
+		}
+
+		switch {
+		case result.err != nil:
+			r.err = result.err
+		case result.streamEnd:
+			if result.streamCRC != r.combinedCRC {
+				r.err = StructuralError("stream checksum mismatch")
+			}
+			r.combinedCRC = 0
+		default:
+			r.combinedCRC = (r.combinedCRC<<1 | r.combinedCRC>>31) ^ result.block.crc
+			r.current = result.data
+		}
+	}
+
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// merge decompresses a block that failed together with the blocks following it, until a block
+// split by a magic inside its compressed data is whole again. The first error is returned if
+// the merged blocks fail as well.
+func (r *Reader) merge(failed blockResult) blockResult {
+	merged := *failed.block
+	for merged.nbits <= int64(merged.level-'0')*maxBlockBits {
+		future, ok := <-r.results
+		if !ok {
+			return failed
+		}
+		next := <-future
+		if next.block == nil {
+			// The end of the stream or an error of the scanner
+			return failed
+		}
+
+		merged = merged.append(*next.block)
+		if data, err := decompressBlock(merged); err == nil {
+			return blockResult{data: data, block: &merged}
+		}
+	}
+	return failed
+}
+
+// Close stops the decompression and waits until the reads of the underlying reader and
+// the workers have returned, so it can be closed afterwards. A read blocked on the
+// underlying reader is waited for, it has to be ended by the caller, e.g. by canceling it.
+// Close does not close the underlying reader.
+func (r *Reader) Close() error {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	r.wg.Wait()
+	return nil
+}
+
+// scanner splits the compressed stream into blocks and hands them to workers
+type scanner struct {
+	r       io.Reader
+	reader  *Reader
+	workers chan struct{}
+
+	// data holds the compressed bytes from the absolute byte offset base
+	data []byte
+	base int64
+	eof  bool
+}
+
+func (s *scanner) run() {
+	defer close(s.reader.results)
+
+	if err := s.scanStreams(); err != nil && err != errStopped {
+		future := make(chan blockResult, 1)
+		future <- blockResult{err: err}
+		select {
+		case s.reader.results <- future:
+		case <-s.reader.done:
+		}
+	}
+}
+
+var errStopped = errors.New("pbzip2: reader closed")
+
+// scanStreams scans concatenated streams until the end of the input
+func (s *scanner) scanStreams() error {
+	start := int64(0)
+	for first := true; ; first = false {
+		if err := s.fill(start + 1); err != nil {
+			if !first && err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		if err := s.fill(start + 4); err != nil {
+			return err
+		}
+
+		header := s.data[start-s.base : start-s.base+4]
+		if header[0] != 'B' || header[1] != 'Z' || header[2] != 'h' {
+			return StructuralError("bad magic value")
+		}
+		if header[3] < '1' || header[3] > '9' {
+			return StructuralError("invalid compression level")
+		}
+
+		end, err := s.scanStream(start+4, header[3])
+		if err != nil {
+			return err
+		}
+		start = (end + 7) / 8
+	}
+}
+
+// scanStream dispatches the blocks of a stream whose first block starts at the given byte,
+// followed by its end with the combined checksum, returning the bit after the stream checksum
+func (s *scanner) scanStream(startByte int64, level byte) (int64, error) {
+	var window uint64
+	blockStart := int64(-1)
+
+	for i := startByte; ; i++ {
+		if err := s.fill(i + 1); err != nil {
+			return 0, err
+		}
+		window = window<<8 | uint64(s.data[i-s.base])
+		scannedBits := (i - startByte + 1) * 8
+
+		// Earlier starts first, a magic ends in this byte when shifted by 7 to 0 bits
+		for shift := 7; shift >= 0; shift-- {
+			if scannedBits-int64(shift) < magicBits {
+				continue
+			}
+			magic := (window >> shift) & magicMask
+			if magic != blockMagic && magic != eosMagic {
+				continue
+			}
+			start := (i+1)*8 - int64(shift) - magicBits
+
+			// End of stream, followed by the combined checksum of all blocks
+			end := start + magicBits + crcBits
+			if magic == eosMagic {
+				streamEnd, err := s.isStreamEnd(end)
+				if err != nil {
+					return 0, err
+				}
+				if !streamEnd {
+					continue
+				}
+			}
+
+			if blockStart >= 0 {
+				if err := s.dispatch(s.block(blockStart, start, level)); err != nil {
+					return 0, err
+				}
+				s.discard(start / 8)
+			}
+
+			if magic == blockMagic {
+				blockStart = start
+				continue
+			}
+
+			future := make(chan blockResult, 1)
+			future <- blockResult{streamEnd: true, streamCRC: uint32(s.bits(start+magicBits, crcBits))}
+			select {
+			case s.reader.results <- future:
+			case <-s.reader.done:
+				return 0, errStopped
+			}
+			return end, nil
+		}
+
+		// Before the first block only the bytes of a magic being matched are needed
+		if blockStart < 0 {
+			s.discard(i - 7)
+		}
+	}
+}
+
+// isStreamEnd reports whether the stream checksum ending at the bit end is followed by the end
+// of the input or another stream, an end of stream magic inside compressed data is not
+func (s *scanner) isStreamEnd(end int64) (bool, error) {
+	next := (end + 7) / 8
+	if err := s.fill(next); err != nil {
+		return false, err
+	}
+	if err := s.fill(next + 4); err == io.ErrUnexpectedEOF {
+		return s.base+int64(len(s.data)) == next, nil
+	} else if err != nil {
+		return false, err
+	}
+	header := s.data[next-s.base : next-s.base+4]
+	return header[0] == 'B' && header[1] == 'Z' && header[2] == 'h' && header[3] >= '1' && header[3] <= '9', nil
+}
+
+// block copies the bits of a block, its checksum follows the magic
+func (s *scanner) block(start, end int64, level byte) block {
+	data := make([]byte, (end+7)/8-start/8)
+	copy(data, s.data[start/8-s.base:])
+	return block{
+		data:  data,
+		shift: uint(start % 8),
+		nbits: end - start,
+		crc:   uint32(s.bits(start+magicBits, crcBits)),
+		level: level,
+	}
+}
+
+// dispatch decompresses the block on a free worker, results are queued in stream order
+func (s *scanner) dispatch(b block) error {
+	future := make(chan blockResult, 1)
+	select {
+	case s.reader.results <- future:
+	case <-s.reader.done:
+		return errStopped
+	}
+
+	select {
+	case s.workers <- struct{}{}:
+	case <-s.reader.done:
+		return errStopped
+	}
+	s.reader.wg.Add(1)
+	go func() {
+		defer s.reader.wg.Done()
+		defer func() { <-s.workers }()
+		data, err := decompressBlock(b)
+		future <- blockResult{data: data, err: err, block: &b}
+	}()
+	return nil
+}
+
+// fill reads until the byte before the absolute offset end is buffered
+func (s *scanner) fill(end int64) error {
+	for s.base+int64(len(s.data)) < end {
+		if s.eof {
+			return io.ErrUnexpectedEOF
+		}
+		select {
+		case <-s.reader.done:
+			return errStopped
+		default:
+		}
+
+		n := len(s.data)
+		if cap(s.data)-n < readSize {
+			grown := make([]byte, n, 2*cap(s.data)+readSize)
+			copy(grown, s.data)
+			s.data = grown
+		}
+		read, err := s.r.Read(s.data[n : n+readSize])
+		s.data = s.data[:n+read]
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discard drops the buffered bytes before the absolute offset
+func (s *scanner) discard(offset int64) {
+	if offset <= s.base {
+		return
+	}
+	n := copy(s.data, s.data[offset-s.base:])
+	s.data = s.data[:n]
+	s.base = offset
+}
+
+// bits reads n bits at the absolute bit offset
+func (s *scanner) bits(offset int64, n uint) uint64 {
+	return readBits(s.data, offset-s.base*8, n)
+}
+
+// readBits reads n (at most 57) bits at the bit offset of data, most significant bit first
+func readBits(data []byte, offset int64, n uint) uint64 {
+	var v uint64
+	first := offset / 8
+	last := (offset + int64(n) - 1) / 8
+	for i := first; i <= last; i++ {
+		v = v<<8 | uint64(data[i])
+	}
+	trailing := uint((last+1)*8 - offset - int64(n))
+	return (v >> trailing) & (1<<n - 1)
+}
+
+// decompressBlock wraps the block into a single block stream, whose checksum is the block checksum
+func decompressBlock(b block) ([]byte, error) {
+	w := bitWriter{buf: make([]byte, 0, len(b.data)+16)}
+	w.buf = append(w.buf, 'B', 'Z', 'h', b.level)
+	w.copyBits(b.data, b.shift, b.nbits)
+	w.writeBits(eosMagic, magicBits)
+	w.writeBits(uint64(b.crc), crcBits)
+	w.flush()
+
+	// A block holds up to level * 100k bytes before the initial run length encoding
+	var data bytes.Buffer
+	data.Grow(int(b.level-'0') * 100000)
+	if _, err := data.ReadFrom(bzip2.NewReader(bytes.NewReader(w.buf))); err != nil {
+		return nil, fmt.Errorf("pbzip2: block with checksum %08x: %w", b.crc, err)
+	}
+	return data.Bytes(), nil
+}
+
+// bitWriter appends bits to a byte slice, most significant bit first
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.nbits))
+	}
+}
+
+// copyBits appends nbits bits of data starting shift bits into its first byte
+func (w *bitWriter) copyBits(data []byte, shift uint, nbits int64) {
+	full := nbits / 8
+	switch {
+	case shift == 0 && w.nbits == 0:
+		w.buf = append(w.buf, data[:full]...)
+	case w.nbits == 0:
+		for i := int64(0); i < full; i++ {
+			w.buf = append(w.buf, data[i]<<shift|data[i+1]>>(8-shift))
+		}
+	default:
+		for i := int64(0); i < full; i++ {
+			w.writeBits(readBits(data, int64(shift)+i*8, 8), 8)
+		}
+	}
+	if rest := uint(nbits % 8); rest > 0 {
+		w.writeBits(readBits(data, int64(shift)+full*8, rest), rest)
+	}
+}
+
+func (w *bitWriter) flush() {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc<<(8-w.nbits)))
+		w.nbits = 0
+	}
+}
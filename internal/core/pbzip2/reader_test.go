@@ -0,0 +1,265 @@
+package pbzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testdata/lines.bz2 is testLines compressed with bzip2 -1, so it has several blocks
+const testFile = "testdata/lines.bz2"
+
+// emptyStream is an empty input compressed with bzip2
+var emptyStream = []byte{0x42, 0x5a, 0x68, 0x39, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x00, 0x00, 0x00, 0x00}
+
+func testLines() []byte {
+	var buf bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&buf, "tick %d glyph by player %d team %d\n", i*30, i%10, 2+i%2)
+	}
+	return buf.Bytes()
+}
+
+func readTestFile(t testing.TB) []byte {
+	t.Helper()
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("cannot read %s: %v", testFile, err)
+	}
+	return data
+}
+
+func decompress(data []byte, workers int) ([]byte, error) {
+	r := NewReader(bytes.NewReader(data), workers)
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestReaderMatchesInput(t *testing.T) {
+	compressed := readTestFile(t)
+	want := testLines()
+
+	for _, workers := range []int{1, 2, 8} {
+		got, err := decompress(compressed, workers)
+		if err != nil {
+			t.Fatalf("%d workers: unexpected error: %v", workers, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d workers: expected %d decompressed bytes to match the input, got %d", workers, len(want), len(got))
+		}
+	}
+}
+
+func TestReaderConcatenatedStreams(t *testing.T) {
+	compressed := readTestFile(t)
+	lines := testLines()
+
+	got, err := decompress(bytes.Join([][]byte{compressed, emptyStream, compressed}, nil), 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := append(append([]byte{}, lines...), lines...); !bytes.Equal(got, want) {
+		t.Fatalf("expected both streams to be decompressed, got %d of %d bytes", len(got), len(want))
+	}
+
+	if got, err := decompress(emptyStream, 4); err != nil || len(got) != 0 {
+		t.Fatalf("expected an empty stream to decompress to nothing, got %d bytes (%v)", len(got), err)
+	}
+}
+
+func TestReaderRejectsTruncatedStream(t *testing.T) {
+	compressed := readTestFile(t)
+
+	for _, size := range []int{2, len(compressed) / 2, len(compressed) - 4} {
+		_, err := decompress(compressed[:size], 4)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected io.ErrUnexpectedEOF for %d of %d bytes, got %v", size, len(compressed), err)
+		}
+	}
+}
+
+func TestReaderRejectsCorruptStream(t *testing.T) {
+	compressed := bytes.Clone(readTestFile(t))
+	compressed[len(compressed)/2] ^= 0xff
+
+	if _, err := decompress(compressed, 4); err == nil {
+		t.Fatal("expected an error for a corrupt block")
+	}
+	if _, err := decompress([]byte("PBDEMS2\x00"), 4); !errors.As(err, new(StructuralError)) {
+		t.Fatalf("expected StructuralError for data that is not bzip2, got %v", err)
+	}
+}
+
+func TestReaderClose(t *testing.T) {
+	r := NewReader(bytes.NewReader(readTestFile(t)), 1)
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The scanner stops instead of blocking on blocks that are never read
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	for range r.results {
+	}
+}
+
+// slowReader reads the stream in small pieces and records reads in progress
+type slowReader struct {
+	r      io.Reader
+	reads  atomic.Int32
+	active atomic.Bool
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	r.active.Store(true)
+	defer r.active.Store(false)
+	r.reads.Add(1)
+	time.Sleep(time.Millisecond)
+	return r.r.Read(p[:min(len(p), 512)])
+}
+
+func TestReaderCloseWaitsForReads(t *testing.T) {
+	source := &slowReader{r: bytes.NewReader(readTestFile(t))}
+	r := NewReader(source, 2)
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// The source can be closed once Close returns
+	reads := source.reads.Load()
+	if source.active.Load() {
+		t.Fatal("expected no read of the source in progress after Close")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := source.reads.Load(); got != reads {
+		t.Fatalf("expected no reads of the source after Close, got %d more", got-reads)
+	}
+}
+
+// blockStarts returns the bit offsets of the block magics of a stream
+func blockStarts(data []byte) []int64 {
+	var starts []int64
+	for offset := int64(32); offset+magicBits <= int64(len(data))*8; offset++ {
+		if readBits(data, offset, magicBits) == blockMagic {
+			starts = append(starts, offset)
+		}
+	}
+	return starts
+}
+
+func TestReaderMergesSplitBlock(t *testing.T) {
+	compressed := readTestFile(t)
+	starts := blockStarts(compressed)
+	if len(starts) < 2 {
+		t.Fatalf("expected several blocks in %s, got %d", testFile, len(starts))
+	}
+
+	// A block magic inside compressed data splits the first block at an arbitrary bit
+	bitBlock := func(start, end int64) block {
+		return block{
+			data:  compressed[start/8 : (end+7)/8],
+			shift: uint(start % 8),
+			nbits: end - start,
+			crc:   uint32(readBits(compressed, start+magicBits, crcBits)),
+			level: compressed[3],
+		}
+	}
+	split := starts[0] + 12345
+	first, second := bitBlock(starts[0], split), bitBlock(split, starts[1])
+	want, err := decompressBlock(bitBlock(starts[0], starts[1]))
+	if err != nil {
+		t.Fatalf("cannot decompress the whole block: %v", err)
+	}
+
+	r := &Reader{results: make(chan chan blockResult, 2), done: make(chan struct{})}
+	for _, b := range []block{first, second} {
+		data, err := decompressBlock(b)
+		if err == nil {
+			t.Fatal("expected the half of a block to fail its checksum")
+		}
+		future := make(chan blockResult, 1)
+		future <- blockResult{data: data, err: err, block: &b}
+		r.results <- future
+	}
+	close(r.results)
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("expected the split block to be merged, got %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected the %d bytes of the whole block, got %d", len(want), len(got))
+	}
+}
+
+func TestScannerIgnoresEndOfStreamMagicInsideBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		rest []byte
+		want bool
+	}{
+		{name: "end of input", want: true},
+		{name: "next stream", rest: []byte("BZh9"), want: true},
+		{name: "compressed data", rest: []byte{0x17, 0x72, 0x45, 0x38, 0x50}, want: false},
+		{name: "invalid level", rest: []byte("BZh0"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(bytes.Clone(emptyStream), tt.rest...)
+			s := &scanner{r: bytes.NewReader(data), reader: &Reader{done: make(chan struct{})}}
+			got, err := s.isStreamEnd(int64(len(emptyStream)) * 8)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// benchmarkFile is a real replay set by PBZIP2_BENCH_FILE, the test data otherwise
+func benchmarkFile(b *testing.B) []byte {
+	filename := os.Getenv("PBZIP2_BENCH_FILE")
+	if filename == "" {
+		return readTestFile(b)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		b.Fatalf("cannot read %s: %v", filename, err)
+	}
+	return data
+}
+
+func benchmarkDecompression(b *testing.B, newReader func(io.Reader) io.Reader) {
+	compressed := benchmarkFile(b)
+	size, err := io.Copy(io.Discard, bzip2.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		b.Fatalf("cannot decompress benchmark file: %v", err)
+	}
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := io.Copy(io.Discard, newReader(bytes.NewReader(compressed))); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkStdlib(b *testing.B) {
+	benchmarkDecompression(b, bzip2.NewReader)
+}
+
+func BenchmarkParallel(b *testing.B) {
+	benchmarkDecompression(b, func(r io.Reader) io.Reader { return NewReader(r, 0) })
+}
@@ -3,11 +3,12 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"sync"
 
 	dotaproto "github.com/dotabuff/manta/dota"
 	"github.com/sicdex/go-steam-ws"
+	"github.com/sicdex/go-steam-ws/protocol"
 	gcproto "github.com/sicdex/go-steam-ws/protocol/gamecoordinator"
 	"google.golang.org/protobuf/proto"
 )
@@ -20,18 +21,36 @@ var errDotaNotReady = errors.New("dota GC client is not ready")
 // Keeping it local lets the Steam transport use WebSockets without coupling it
 // to go-dota2's legacy TCP-only Steam client.
 type dotaGCClient struct {
-	steamClient    *steam.Client
-	ready          chan struct{}
-	readyOnce      sync.Once
-	matchResponses chan *dotaproto.CMsgGCMatchDetailsResponse
+	steamClient *steam.Client
+	ready       chan struct{}
+	readyOnce   sync.Once
+	// send writes a GC message as the job sourceJobID, 0 if no answer is expected. It is replaced in tests.
+	send   func(messageType uint32, body proto.Message, sourceJobID uint64)
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// matchRequests holds the match details requests sent to the GC and not answered yet, by match and by job
+	matchRequests map[uint64]*matchDetailsRequest
+	matchJobs     map[uint64]*matchDetailsRequest
+	lastJobID     uint64
 }
 
-func newDotaGCClient(client *steam.Client) *dotaGCClient {
+// matchDetailsRequest is a request sent to the GC, waited for by every lookup of the match
+type matchDetailsRequest struct {
+	matchID uint64
+	jobID   uint64
+	waiters []chan *dotaproto.CMsgGCMatchDetailsResponse
+}
+
+func newDotaGCClient(client *steam.Client, logger *slog.Logger) *dotaGCClient {
 	dc := &dotaGCClient{
-		steamClient:    client,
-		ready:          make(chan struct{}),
-		matchResponses: make(chan *dotaproto.CMsgGCMatchDetailsResponse, 1),
+		steamClient:   client,
+		logger:        logger,
+		ready:         make(chan struct{}),
+		matchRequests: make(map[uint64]*matchDetailsRequest),
+		matchJobs:     make(map[uint64]*matchDetailsRequest),
 	}
+	dc.send = dc.writeGC
 	client.GC.RegisterPacketHandler(dc)
 	return dc
 }
@@ -56,6 +75,9 @@ func (d *dotaGCClient) SayHello() {
 	})
 }
 
+// RequestMatchDetails requests the match from the GC, any number of requests may be in flight.
+// A lookup of a match that is already requested waits for the same response instead of sending another request.
+// Every request is a GC job, the response is routed by the job it targets.
 func (d *dotaGCClient) RequestMatchDetails(ctx context.Context, matchID uint64) (*dotaproto.CMsgGCMatchDetailsResponse, error) {
 	select {
 	case <-d.ready:
@@ -63,20 +85,74 @@ func (d *dotaGCClient) RequestMatchDetails(ctx context.Context, matchID uint64)
 		return nil, errDotaNotReady
 	}
 
-	d.write(uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsRequest), &dotaproto.CMsgGCMatchDetailsRequest{
-		MatchId: proto.Uint64(matchID),
-	})
+	waiter := make(chan *dotaproto.CMsgGCMatchDetailsResponse, 1)
+	d.mu.Lock()
+	request, pending := d.matchRequests[matchID]
+	if !pending {
+		d.lastJobID++
+		request = &matchDetailsRequest{matchID: matchID, jobID: d.lastJobID}
+		d.matchRequests[matchID] = request
+		d.matchJobs[request.jobID] = request
+	}
+	request.waiters = append(request.waiters, waiter)
+	d.mu.Unlock()
 
-	for {
-		select {
-		case response := <-d.matchResponses:
-			if response.GetMatch() == nil || response.GetMatch().GetMatchId() == matchID {
-				return response, nil
-			}
-		case <-ctx.Done():
-			return nil, ctx.Err()
+	if !pending {
+		d.send(uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsRequest), &dotaproto.CMsgGCMatchDetailsRequest{
+			MatchId: proto.Uint64(matchID),
+		}, request.jobID)
+	}
+
+	select {
+	case response := <-waiter:
+		return response, nil
+	case <-ctx.Done():
+		d.removeWaiter(request, waiter)
+		return nil, ctx.Err()
+	}
+}
+
+// removeWaiter stops waiting for the request, the response of a request nobody waits for is dropped
+func (d *dotaGCClient) removeWaiter(request *matchDetailsRequest, waiter chan *dotaproto.CMsgGCMatchDetailsResponse) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.matchJobs[request.jobID] != request {
+		return
+	}
+	for i, w := range request.waiters {
+		if w == waiter {
+			request.waiters = append(request.waiters[:i], request.waiters[i+1:]...)
+			break
 		}
 	}
+	if len(request.waiters) == 0 {
+		d.forgetRequest(request)
+	}
+}
+
+// resolveMatchDetails hands the response to everyone waiting for the request of the job.
+// A response of a job that is not pending (answered, canceled or never sent) is dropped.
+func (d *dotaGCClient) resolveMatchDetails(jobID uint64, response *dotaproto.CMsgGCMatchDetailsResponse) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	request, ok := d.matchJobs[jobID]
+	if !ok {
+		d.logger.Debug("Dropping Dota match details response nobody is waiting for",
+			"job_id", jobID, "match_id", response.GetMatch().GetMatchId())
+		return
+	}
+	d.forgetRequest(request)
+	for _, waiter := range request.waiters {
+		waiter <- response
+	}
+}
+
+// forgetRequest removes the request from the pending ones, d.mu must be held
+func (d *dotaGCClient) forgetRequest(request *matchDetailsRequest) {
+	delete(d.matchRequests, request.matchID)
+	delete(d.matchJobs, request.jobID)
 }
 
 func (d *dotaGCClient) HandleGCPacket(packet *gcproto.GCPacket) {
@@ -91,10 +167,10 @@ func (d *dotaGCClient) HandleGCPacket(packet *gcproto.GCPacket) {
 	case uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCClientConnectionStatus):
 		status := new(dotaproto.CMsgConnectionStatus)
 		if err := proto.Unmarshal(packet.Body, status); err != nil {
-			log.Printf("Could not decode Dota GC connection status: %v", err)
+			d.logger.Error("Could not decode Dota GC connection status", "error", err)
 			return
 		}
-		log.Println("New GC connection status:", status.GetStatus())
+		d.logger.Info("New GC connection status", "status", status.GetStatus())
 		if status.GetStatus() == dotaproto.GCConnectionStatus_GCConnectionStatus_HAVE_SESSION {
 			d.markReady()
 		} else {
@@ -104,14 +180,10 @@ func (d *dotaGCClient) HandleGCPacket(packet *gcproto.GCPacket) {
 	case uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsResponse):
 		response := new(dotaproto.CMsgGCMatchDetailsResponse)
 		if err := proto.Unmarshal(packet.Body, response); err != nil {
-			log.Printf("Could not decode Dota match details: %v", err)
+			d.logger.Error("Could not decode Dota match details", "error", err)
 			return
 		}
-		select {
-		case d.matchResponses <- response:
-		default:
-			log.Println("Dropping unexpected Dota match details response")
-		}
+		d.resolveMatchDetails(uint64(packet.TargetJobId), response)
 
 	case uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCPingRequest):
 		d.write(uint32(dotaproto.EGCBaseClientMsg_k_EMsgGCPingResponse), new(dotaproto.CMsgGCClientPing))
@@ -125,5 +197,13 @@ func (d *dotaGCClient) markReady() {
 }
 
 func (d *dotaGCClient) write(messageType uint32, body proto.Message) {
-	d.steamClient.GC.Write(gcproto.NewGCMsgProtobuf(dotaAppID, messageType, body))
+	d.send(messageType, body, 0)
+}
+
+func (d *dotaGCClient) writeGC(messageType uint32, body proto.Message, sourceJobID uint64) {
+	msg := gcproto.NewGCMsgProtobuf(dotaAppID, messageType, body)
+	if sourceJobID != 0 {
+		msg.SetSourceJobId(protocol.JobId(sourceJobID))
+	}
+	d.steamClient.GC.Write(msg)
 }
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"go-glyph/internal/core/fakesteam"
+	"log/slog"
+	"testing"
+	"time"
+
+	dotaproto "github.com/dotabuff/manta/dota"
+	"github.com/sicdex/go-steam-ws/protocol"
+	gcproto "github.com/sicdex/go-steam-ws/protocol/gamecoordinator"
+	"google.golang.org/protobuf/proto"
+)
+
+// sentMatchRequest is a match details request written to the GC
+type sentMatchRequest struct {
+	matchID uint64
+	jobID   uint64
+}
+
+// newTestDotaGCClient returns a ready client reporting the match details requests it sends
+func newTestDotaGCClient() (*dotaGCClient, chan sentMatchRequest) {
+	requested := make(chan sentMatchRequest, 10)
+	dc := &dotaGCClient{
+		ready:         make(chan struct{}),
+		matchRequests: make(map[uint64]*matchDetailsRequest),
+		matchJobs:     make(map[uint64]*matchDetailsRequest),
+		logger:        slog.Default(),
+		send: func(messageType uint32, body proto.Message, sourceJobID uint64) {
+			if request, ok := body.(*dotaproto.CMsgGCMatchDetailsRequest); ok {
+				requested <- sentMatchRequest{matchID: request.GetMatchId(), jobID: sourceJobID}
+			}
+		},
+	}
+	dc.markReady()
+	return dc, requested
+}
+
+func respondMatchDetails(t *testing.T, dc *dotaGCClient, jobID uint64, response *dotaproto.CMsgGCMatchDetailsResponse) {
+	t.Helper()
+	body, err := proto.Marshal(response)
+	if err != nil {
+		t.Fatalf("cannot encode response: %v", err)
+	}
+	dc.HandleGCPacket(&gcproto.GCPacket{
+		AppId:       dotaAppID,
+		MsgType:     uint32(dotaproto.EDOTAGCMsg_k_EMsgGCMatchDetailsResponse),
+		Body:        body,
+		TargetJobId: protocol.JobId(jobID),
+	})
+}
+
+func matchDetailsResponse(matchID uint64) *dotaproto.CMsgGCMatchDetailsResponse {
+	return &dotaproto.CMsgGCMatchDetailsResponse{Match: &dotaproto.CMsgDOTAMatch{MatchId: proto.Uint64(matchID)}}
+}
+
+type matchDetailsResult struct {
+	response *dotaproto.CMsgGCMatchDetailsResponse
+	err      error
+}
+
+func requestMatchDetailsAsync(dc *dotaGCClient, ctx context.Context, matchID uint64) chan matchDetailsResult {
+	result := make(chan matchDetailsResult, 1)
+	go func() {
+		response, err := dc.RequestMatchDetails(ctx, matchID)
+		result <- matchDetailsResult{response: response, err: err}
+	}()
+	return result
+}
+
+func awaitMatchDetails(t *testing.T, result chan matchDetailsResult) *dotaproto.CMsgGCMatchDetailsResponse {
+	t.Helper()
+	select {
+	case r := <-result:
+		if r.err != nil {
+			t.Fatalf("RequestMatchDetails returned error: %v", r.err)
+		}
+		return r.response
+	case <-time.After(time.Second):
+		t.Fatal("RequestMatchDetails did not return")
+		return nil
+	}
+}
+
+func TestRequestMatchDetailsRoutesResponsesToRequests(t *testing.T) {
+	dc, requested := newTestDotaGCClient()
+
+	first := requestMatchDetailsAsync(dc, context.Background(), 1)
+	firstSent := <-requested
+	second := requestMatchDetailsAsync(dc, context.Background(), 2)
+	secondSent := <-requested
+	third := requestMatchDetailsAsync(dc, context.Background(), 3)
+	thirdSent := <-requested
+	if firstSent.jobID == secondSent.jobID || secondSent.jobID == thirdSent.jobID || firstSent.jobID == thirdSent.jobID {
+		t.Fatalf("expected every request to be its own job, got %v, %v and %v", firstSent, secondSent, thirdSent)
+	}
+
+	// Responses of jobs nobody waits for are dropped, with or without a match
+	respondMatchDetails(t, dc, 0, &dotaproto.CMsgGCMatchDetailsResponse{Result: proto.Uint32(2)})
+	respondMatchDetails(t, dc, thirdSent.jobID+100, matchDetailsResponse(1))
+	select {
+	case r := <-first:
+		t.Fatalf("expected the first lookup to wait for its own job, got %v", r.response)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// Answered out of order, the response without a match is for the job it targets
+	respondMatchDetails(t, dc, secondSent.jobID, matchDetailsResponse(2))
+	respondMatchDetails(t, dc, firstSent.jobID, &dotaproto.CMsgGCMatchDetailsResponse{Result: proto.Uint32(2)})
+	respondMatchDetails(t, dc, thirdSent.jobID, matchDetailsResponse(3))
+
+	if got := awaitMatchDetails(t, second).GetMatch().GetMatchId(); got != 2 {
+		t.Fatalf("expected match 2, got %d", got)
+	}
+	if got := awaitMatchDetails(t, first); got.GetMatch() != nil || got.GetResult() != 2 {
+		t.Fatalf("expected the response without a match, got %v", got)
+	}
+	if got := awaitMatchDetails(t, third).GetMatch().GetMatchId(); got != 3 {
+		t.Fatalf("expected match 3, got %d", got)
+	}
+}
+
+func TestRequestMatchDetailsCoalescesLookups(t *testing.T) {
+	dc, requested := newTestDotaGCClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := requestMatchDetailsAsync(dc, ctx, 1)
+	sent := <-requested
+
+	var results []chan matchDetailsResult
+	for i := 0; i < 3; i++ {
+		results = append(results, requestMatchDetailsAsync(dc, context.Background(), 1))
+	}
+	waitForMatchWaiters(t, dc, 1, 4)
+
+	// A canceled lookup leaves the request to the others
+	cancel()
+	if r := <-canceled; r.err != context.Canceled {
+		t.Fatalf("expected the canceled lookup to return context.Canceled, got %v", r.err)
+	}
+	waitForMatchWaiters(t, dc, 1, 3)
+
+	respondMatchDetails(t, dc, sent.jobID, matchDetailsResponse(1))
+	for _, result := range results {
+		if got := awaitMatchDetails(t, result).GetMatch().GetMatchId(); got != 1 {
+			t.Fatalf("expected match 1, got %d", got)
+		}
+	}
+
+	select {
+	case again := <-requested:
+		t.Fatalf("expected a single request to the GC, match %d was requested again", again.matchID)
+	default:
+	}
+	if len(dc.matchRequests) != 0 || len(dc.matchJobs) != 0 {
+		t.Fatalf("expected no pending requests, got %d matches and %d jobs", len(dc.matchRequests), len(dc.matchJobs))
+	}
+}
+
+func waitForMatchWaiters(t *testing.T, dc *dotaGCClient, matchID uint64, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		waiters := 0
+		dc.mu.Lock()
+		if request, ok := dc.matchRequests[matchID]; ok {
+			waiters = len(request.waiters)
+		}
+		dc.mu.Unlock()
+		if waiters == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d lookups waiting for match %d, got %d", want, matchID, waiters)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newFakeGCDotaClient returns a client whose messages are answered by the fake GC
+func newFakeGCDotaClient(t *testing.T, gc *fakesteam.GC) *dotaGCClient {
+	t.Helper()
+	dc := &dotaGCClient{
+		ready:         make(chan struct{}),
+		matchRequests: make(map[uint64]*matchDetailsRequest),
+		matchJobs:     make(map[uint64]*matchDetailsRequest),
+		logger:        slog.Default(),
+	}
+	dc.send = func(messageType uint32, body proto.Message, sourceJobID uint64) {
+		b, err := proto.Marshal(body)
+		if err != nil {
+			t.Errorf("cannot encode GC message: %v", err)
+			return
+		}
+		for _, answer := range gc.Handle(fakesteam.GCMessage{MsgType: messageType, SourceJobID: sourceJobID, Body: b}) {
+			// The GC answers asynchronously, like the Steam client delivering packets
+			go dc.HandleGCPacket(&gcproto.GCPacket{
+				AppId:       dotaAppID,
+				MsgType:     answer.MsgType,
+				IsProto:     true,
+				Body:        answer.Body,
+				TargetJobId: protocol.JobId(answer.TargetJobID),
+			})
+		}
+	}
+	return dc
+}
+
+func TestDotaGCClientAgainstFakeGC(t *testing.T) {
+	gc, err := fakesteam.LoadGC("../fakesteam/testdata/matches.json")
+	if err != nil {
+		t.Fatalf("LoadGC returned error: %v", err)
+	}
+	dc := newFakeGCDotaClient(t, gc)
+
+	if _, err := dc.RequestMatchDetails(context.Background(), 7500000001); err != errDotaNotReady {
+		t.Fatalf("expected requests before the welcome to fail, got %v", err)
+	}
+	dc.SayHello()
+	select {
+	case <-dc.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("client was not welcomed by the GC")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err := dc.RequestMatchDetails(ctx, 7500000002)
+	if err != nil {
+		t.Fatalf("RequestMatchDetails returned error: %v", err)
+	}
+	if response.GetMatch().GetCluster() != 183 || response.GetMatch().GetReplaySalt() != 942016 {
+		t.Fatalf("expected the fixture of the match, got %v", response.GetMatch())
+	}
+
+	response, err = dc.RequestMatchDetails(ctx, 1)
+	if err != nil || response.GetMatch() != nil || response.GetResult() != fakesteam.MatchDetailsNotFound {
+		t.Fatalf("expected an unknown match not to be found, got %v (%v)", response, err)
+	}
+
+	// A GC that stops answering leaves the request to time out
+	gc.SetSilent(true)
+	silentCtx, cancelSilent := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelSilent()
+	if _, err := dc.RequestMatchDetails(silentCtx, 7500000001); err != context.DeadlineExceeded {
+		t.Fatalf("expected the request to time out, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"time"
 )
 
 type UserFacingError struct {
@@ -13,6 +17,15 @@ func (e UserFacingError) Error() string {
 	return e.Message
 }
 
+// SteamUnavailableError is returned while the Dota GC cannot be reached, RetryAfter is zero if unknown
+type SteamUnavailableError struct {
+	RetryAfter time.Duration
+}
+
+func (e SteamUnavailableError) Error() string {
+	return "Error connecting to dota servers :( Please try again later"
+}
+
 type ValidateError struct {
 	error
 }
@@ -62,6 +75,14 @@ func (e CopyError) Error() string {
 	return fmt.Sprintf("Cannot copy decompressed content into file: %s", e.error)
 }
 
+type IncompleteReplayError struct {
+	error
+}
+
+func (e IncompleteReplayError) Error() string {
+	return fmt.Sprintf("Replay is incomplete: %s", e.error)
+}
+
 type GETError struct {
 	url string
 	error
@@ -132,6 +153,89 @@ func (e CloseFileError) Error() string {
 	return fmt.Sprintf("Cannot close file %s: %s", e.filename, e.error)
 }
 
+type DemoMatchIDError struct {
+	filename string
+	error
+}
+
+func (e DemoMatchIDError) Error() string {
+	return fmt.Sprintf("Cannot read match ID of demo %s: %s", e.filename, e.error)
+}
+
+// ReplayMatchMismatchError is returned for a retrieved replay recording another match than the requested one
+type ReplayMatchMismatchError struct {
+	matchID     int
+	demoMatchID int
+}
+
+func (e ReplayMatchMismatchError) Error() string {
+	return fmt.Sprintf("Replay is of match %d, not %d", e.demoMatchID, e.matchID)
+}
+
+type ReplayExpiredError struct{}
+
+func (e ReplayExpiredError) Error() string {
+	return "Match is too new or too old :("
+}
+
+type ReplayNotFoundError struct {
+	location string
+}
+
+func (e ReplayNotFoundError) Error() string {
+	return fmt.Sprintf("No replay at %s", e.location)
+}
+
+type ReplaySourceError struct {
+	source string
+	error
+}
+
+func (e ReplaySourceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.source, e.error)
+}
+
+func (e ReplaySourceError) Unwrap() error {
+	return e.error
+}
+
+// ReplayUnavailableError tells why each replay source failed
+type ReplayUnavailableError struct {
+	errors []ReplaySourceError
+}
+
+func (e ReplayUnavailableError) Error() string {
+	reasons := make([]string, len(e.errors))
+	for i, err := range e.errors {
+		reasons[i] = err.Error()
+	}
+	return fmt.Sprintf("Replay is unavailable (%s)", strings.Join(reasons, "; "))
+}
+
+// Expired tells whether Valve reported the replay as too old or too new to be served
+func (e ReplayUnavailableError) Expired() bool {
+	for _, err := range e.errors {
+		if errors.As(err, &ReplayExpiredError{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusCode is 404 if no source has the replay and 502 if any source failed otherwise
+func (e ReplayUnavailableError) StatusCode() int {
+	for _, err := range e.errors {
+		var userFacing UserFacingError
+		switch {
+		case errors.As(err, &ReplayExpiredError{}), errors.As(err, &ReplayNotFoundError{}):
+		case errors.As(err, &userFacing) && userFacing.Code == fiber.StatusNotFound:
+		default:
+			return fiber.StatusBadGateway
+		}
+	}
+	return fiber.StatusNotFound
+}
+
 type APIError struct{}
 
 func (e APIError) Error() string {
@@ -9,16 +9,22 @@ import (
 type GlyphServiceGlyphRepository interface {
 	GetGlyphs(matchID int) ([]models.Glyph, error)
 	GlyphsExist(matchID int) (bool, error)
-	CreateGlyphs(newGlyphs []models.Glyph) error
+}
+
+type GlyphServiceMatchRepository interface {
+	MatchExists(matchID int) (bool, error)
 }
 
 type GlyphService struct {
 	GlyphServiceGlyphRepository GlyphServiceGlyphRepository
+	GlyphServiceMatchRepository GlyphServiceMatchRepository
 }
 
-func NewGlyphService(glyphServiceGlyphRepository GlyphServiceGlyphRepository) *GlyphService {
+func NewGlyphService(glyphServiceGlyphRepository GlyphServiceGlyphRepository,
+	glyphServiceMatchRepository GlyphServiceMatchRepository) *GlyphService {
 	return &GlyphService{
 		GlyphServiceGlyphRepository: glyphServiceGlyphRepository,
+		GlyphServiceMatchRepository: glyphServiceMatchRepository,
 	}
 }
 
@@ -28,10 +34,17 @@ func (s *GlyphService) GetGlyphs(getGlyphs *dtos.GetGlyphs) (dtos.GlyphParse, er
 		return dtos.GlyphParse{}, ValidateError{err}
 	}
 
-	matchParsed, err := s.GlyphServiceGlyphRepository.GlyphsExist(getGlyphs.MatchID)
+	// A match without glyphs is stored as a match only, glyphs of older parses may have no match
+	matchParsed, err := s.GlyphServiceMatchRepository.MatchExists(getGlyphs.MatchID)
 	if err != nil {
 		return dtos.GlyphParse{}, RepositoryError{err}
 	}
+	if !matchParsed {
+		matchParsed, err = s.GlyphServiceGlyphRepository.GlyphsExist(getGlyphs.MatchID)
+		if err != nil {
+			return dtos.GlyphParse{}, RepositoryError{err}
+		}
+	}
 	if !matchParsed {
 		return dtos.GlyphParse{GlyphParsed: false}, nil
 	}
@@ -43,24 +56,3 @@ func (s *GlyphService) GetGlyphs(getGlyphs *dtos.GetGlyphs) (dtos.GlyphParse, er
 
 	return dtos.GlyphParse{GlyphParsed: true, Glyphs: glyphs}, nil
 }
-
-func (s *GlyphService) CreateGlyphs(createGlyphs *dtos.CreateGlyphs) error {
-	err := validator.ValidateStruct(createGlyphs)
-	if err != nil {
-		return ValidateError{err}
-	}
-
-	matchParsed, err := s.GlyphServiceGlyphRepository.GlyphsExist(createGlyphs.Glyphs[0].MatchID)
-	if err != nil {
-		return RepositoryError{err}
-	}
-	if matchParsed {
-		return MatchAlreadyParsedError{}
-	}
-
-	err = s.GlyphServiceGlyphRepository.CreateGlyphs(createGlyphs.Glyphs)
-	if err != nil {
-		return RepositoryError{err}
-	}
-	return nil
-}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+)
+
+type fakeGlyphRepository struct {
+	glyphs []models.Glyph
+}
+
+func (r *fakeGlyphRepository) GetGlyphs(int) ([]models.Glyph, error) {
+	return r.glyphs, nil
+}
+
+func (r *fakeGlyphRepository) GlyphsExist(int) (bool, error) {
+	return len(r.glyphs) > 0, nil
+}
+
+type fakeMatchExists bool
+
+func (e fakeMatchExists) MatchExists(int) (bool, error) {
+	return bool(e), nil
+}
+
+func TestGetGlyphsTreatsMatchWithoutGlyphsAsParsed(t *testing.T) {
+	service := NewGlyphService(&fakeGlyphRepository{glyphs: []models.Glyph{}}, fakeMatchExists(true))
+
+	glyphParse, err := service.GetGlyphs(&dtos.GetGlyphs{MatchID: 7000000001})
+	if err != nil {
+		t.Fatalf("GetGlyphs returned error: %v", err)
+	}
+	if !glyphParse.GlyphParsed || len(glyphParse.Glyphs) != 0 {
+		t.Fatalf("expected a parsed match without glyphs, got %+v", glyphParse)
+	}
+}
+
+func TestGetGlyphsFallsBackToGlyphsWithoutMatch(t *testing.T) {
+	glyphs := []models.Glyph{{MatchID: 7000000001, Minute: 12}}
+
+	glyphParse, err := NewGlyphService(&fakeGlyphRepository{glyphs: glyphs}, fakeMatchExists(false)).
+		GetGlyphs(&dtos.GetGlyphs{MatchID: 7000000001})
+	if err != nil {
+		t.Fatalf("GetGlyphs returned error: %v", err)
+	}
+	if !glyphParse.GlyphParsed || len(glyphParse.Glyphs) != 1 {
+		t.Fatalf("expected the glyphs of an older parse, got %+v", glyphParse)
+	}
+
+	glyphParse, err = NewGlyphService(&fakeGlyphRepository{}, fakeMatchExists(false)).
+		GetGlyphs(&dtos.GetGlyphs{MatchID: 7000000001})
+	if err != nil || glyphParse.GlyphParsed {
+		t.Fatalf("expected an unparsed match, got %+v (%v)", glyphParse, err)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"go-glyph/internal/core/dtos"
-	"log"
+	"go-glyph/internal/core/logging"
+	"go-glyph/internal/core/metrics"
+	"go-glyph/internal/core/tracing"
+	"log/slog"
 	"net"
 	"sort"
 	"strings"
@@ -14,87 +17,191 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/sicdex/go-steam-ws"
-	steamproto "github.com/sicdex/go-steam-ws/protocol"
-	steampb "github.com/sicdex/go-steam-ws/protocol/protobuf"
 	"github.com/sicdex/go-steam-ws/protocol/steamlang"
 )
 
+const (
+	// steamRequestTimeout is how long the GC is given to answer a request
+	steamRequestTimeout = 5 * time.Second
+	// steamAcquireTimeout is how long a request waits for an account to become ready
+	steamAcquireTimeout = 10 * time.Second
+	// A lookup is tried on this many accounts, backing off from steamRetryBaseDelay between attempts
+	steamLookupAttempts = 3
+	steamRetryBaseDelay = 250 * time.Millisecond
+	// steamAccountLogKey is the attribute naming the account on the log lines of its logins and requests
+	steamAccountLogKey = "account"
+)
+
+// GoSteamService keeps every configured account logged in and dispatches requests to the least busy one.
+// Accounts that fail are quarantined and reconnected in the background.
 type GoSteamService struct {
-	steamClient       *steam.Client
-	dotaClient        *dotaGCClient
-	steamLoginInfos   []*steam.LogOnDetails
-	counter           uint
-	lock              sync.Mutex
-	keepAliveTicker   *time.Ticker
-	keepAliveTickerMu sync.Mutex
-	keepAliveRequests chan struct{}
+	accounts        []*steamAccount
+	connect         steamConnector
+	quarantineDelay time.Duration
+	retryDelay      time.Duration
+	breaker         *circuitBreaker
+	logger          *slog.Logger
+	// ctx is canceled on close, stopping logins waiting for Steam Guard
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// changed is closed and replaced whenever an account changes state
+	changed chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
 }
 
-func NewGoSteamService(usernames, passwords string) *GoSteamService {
-	var steamLoginInfos []*steam.LogOnDetails
-	u := strings.Split(usernames, " ")
-	p := strings.Split(passwords, " ")
-	for i := 0; i < len(u); i++ {
-		steamLoginInfos = append(steamLoginInfos, &steam.LogOnDetails{
-			Username: u[i],
-			Password: p[i],
-		})
-	}
-	steamLoginInfo := steamLoginInfos[0]
+// steamLease is an account session acquired for one request
+type steamLease struct {
+	account    *steamAccount
+	session    steamSession
+	generation int
+}
 
+// SteamAccountConfig is the login of one account of the pool
+type SteamAccountConfig struct {
+	Username string
+	Password string
+	// SharedSecret of the mobile authenticator, base64 encoded. Email Steam Guard codes are
+	// submitted through the admin API if the account has none.
+	SharedSecret string
+	// Priority orders the accounts, requests go to the ready accounts of the highest priority
+	Priority int
+}
+
+// LogValue keeps the password and shared secret out of the logs
+func (c SteamAccountConfig) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", c.Username), slog.Int("priority", c.Priority))
+}
+
+// NewGoSteamService logs in to the accounts in the background, reusing the refresh tokens stored by sessions.
+// Requests wait for an account to log in and readiness reports the pool until one did.
+func NewGoSteamService(accounts []SteamAccountConfig, sessions *SteamSessionService, circuit CircuitBreakerConfig, logger *slog.Logger) *GoSteamService {
+	connect := connectDotaSession(sessions, newSteamAuthenticator(steamAuthURL), logger)
+	service := newGoSteamService(accounts, connect, steamQuarantineBaseDelay, logger)
+	service.breaker = newCircuitBreaker(circuit, logger)
+	return service
+}
+
+// newGoSteamService logs in to all accounts concurrently
+func newGoSteamService(accounts []SteamAccountConfig, connect steamConnector, quarantineDelay time.Duration, logger *slog.Logger) *GoSteamService {
 	service := &GoSteamService{
-		steamLoginInfos: steamLoginInfos,
-		counter:         0,
-		lock:            sync.Mutex{},
+		connect:         connect,
+		quarantineDelay: quarantineDelay,
+		retryDelay:      steamRetryBaseDelay,
+		breaker:         newCircuitBreaker(CircuitBreakerConfig{}, logger),
+		logger:          logger,
+		changed:         make(chan struct{}),
+		done:            make(chan struct{}),
 	}
-
-	sc, dc, err := initDotaClient(steamLoginInfo, service.onDisconnected)
-	if err != nil {
-		log.Fatal(err)
+	service.ctx, service.cancel = context.WithCancel(context.Background())
+	service.mu.Lock()
+	for _, account := range accounts {
+		service.startAccount(account)
 	}
+	service.mu.Unlock()
+	return service
+}
+
+// GetMatchDetails looks the match up with the GC, failing fast while the circuit breaker is open.
+// The lookup gives up once ctx is done, without counting against the GC.
+func (s *GoSteamService) GetMatchDetails(ctx context.Context, matchID int) (match dtos.Match, err error) {
+	ctx = logging.WithMatchID(ctx, matchID)
+	ctx, span := tracing.Start(ctx, "steam.GetMatchDetails", tracing.MatchIDKey.Int(matchID))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
-	service.steamClient = sc
-	service.dotaClient = dc
-	service.startKeepAlive()
+	done, retryAfter, ok := s.breaker.Allow()
+	if !ok {
+		span.AddEvent("circuit breaker open")
+		return dtos.Match{}, SteamUnavailableError{RetryAfter: retryAfter}
+	}
 
-	return service
+	start := time.Now()
+	match, err = s.lookUpMatchDetails(ctx, matchID)
+	metrics.GCLookupDuration.Observe(time.Since(start).Seconds())
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		done(circuitAbandoned)
+		return dtos.Match{}, ctxErr
+	}
+	var unavailable SteamUnavailableError
+	if !errors.As(err, &unavailable) {
+		done(circuitSucceeded)
+		return match, err
+	}
+	done(circuitFailed)
+	return dtos.Match{}, SteamUnavailableError{RetryAfter: s.breaker.RetryAfter()}
 }
 
-func (s *GoSteamService) GetMatchDetails(matchID int) (dtos.Match, error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// lookUpMatchDetails tries up to steamLookupAttempts accounts, backing off from retryDelay between attempts
+func (s *GoSteamService) lookUpMatchDetails(ctx context.Context, matchID int) (dtos.Match, error) {
+	for i := 0; i < steamLookupAttempts; i++ {
+		if i > 0 {
+			if err := sleepContext(ctx, backoffDelay(s.retryDelay, steamRequestTimeout, i)); err != nil {
+				return dtos.Match{}, err
+			}
+		}
 
-	maxRetries := 3
-	for i := 0; i < maxRetries; i++ {
-		match, err := s.getMatchFromSteam(matchID)
+		acquireCtx, cancel := context.WithTimeout(ctx, steamAcquireTimeout)
+		lease, err := s.acquire(acquireCtx)
+		cancel()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err == nil {
+				s.release(lease)
+			}
+			return dtos.Match{}, ctxErr
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "No Steam account is ready", "error", err)
+			break
+		}
+
+		match, err := s.getMatchFromSteam(ctx, lease, matchID, i+1)
+		s.release(lease)
 		if err == nil {
+			lease.account.requestAnswered(lease.generation)
 			return match, nil
 		}
-
-		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, errDotaNotReady) {
-			return dtos.Match{}, err
+		// The account is not to blame for a request given up by its caller
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return dtos.Match{}, ctxErr
 		}
 
-		log.Printf("Error connecting to dota: %v, changing client...", err)
-		err = s.changeClient()
-		time.Sleep(1 * time.Second)
-		if err != nil {
-			log.Println("Error changing client:", err)
+		switch {
+		case errors.Is(err, errDotaNotReady):
+			lease.account.logger.WarnContext(ctx, "Steam account has no GC session, quarantining it", "error", err)
+			lease.account.quarantine(lease.generation, err)
+		case errors.Is(err, context.DeadlineExceeded):
+			if lease.account.requestTimedOut(lease.generation, err) {
+				lease.account.logger.WarnContext(ctx, "Steam account timed out repeatedly, quarantining it", "error", err)
+			} else {
+				lease.account.logger.WarnContext(ctx, "Steam account timed out getting match details", "error", err)
+			}
+		default:
+			return dtos.Match{}, err
 		}
 	}
 
-	log.Printf("Could not get match details after %d attempts", maxRetries)
-	return dtos.Match{}, UserFacingError{Code: fiber.StatusServiceUnavailable, Message: "Error connecting to dota servers :( Please try again later"}
+	s.logger.WarnContext(ctx, "Could not get match details")
+	return dtos.Match{}, SteamUnavailableError{}
 }
 
-func (s *GoSteamService) getMatchFromSteam(matchID int) (dtos.Match, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// getMatchFromSteam requests the match from the GC with the leased account, attempt is counted from 1 for the trace
+func (s *GoSteamService) getMatchFromSteam(ctx context.Context, lease steamLease, matchID, attempt int) (dtos.Match, error) {
+	ctx, span := tracing.Start(ctx, "steam.RequestMatchDetails",
+		tracing.SteamAccountKey.String(lease.account.loginInfo.Username), tracing.SteamAttemptKey.Int(attempt))
+	ctx, cancel := context.WithTimeout(ctx, steamRequestTimeout)
 	defer cancel()
 
-	matchDetails, err := s.dotaClient.RequestMatchDetails(ctx, uint64(matchID))
+	matchDetails, err := lease.session.RequestMatchDetails(ctx, uint64(matchID))
 	if err != nil {
+		tracing.End(span, err)
 		return dtos.Match{}, err
 	}
+	span.SetAttributes(tracing.ReplayClusterKey.Int(int(matchDetails.Match.GetCluster())))
+	span.End()
 
 	return dtos.Match{
 		ID:         matchID,
@@ -103,97 +210,188 @@ func (s *GoSteamService) getMatchFromSteam(matchID int) (dtos.Match, error) {
 	}, nil
 }
 
-func (s *GoSteamService) changeClient() error {
-	s.gracefulDisconnect()
-
-	s.counter++
-	if s.counter >= uint(len(s.steamLoginInfos)) {
-		s.counter = 0
-	}
-	loginInfo := s.steamLoginInfos[s.counter]
+// acquire waits for a ready account of the highest priority, picking the one with the fewest requests
+// in flight and the least recently used among those
+func (s *GoSteamService) acquire(ctx context.Context) (steamLease, error) {
+	for {
+		s.mu.Lock()
+		var best *steamAccount
+		for _, account := range s.accounts {
+			if account.state != SteamAccountReady {
+				continue
+			}
+			if best == nil || account.priority > best.priority ||
+				(account.priority == best.priority && (account.inFlight < best.inFlight ||
+					(account.inFlight == best.inFlight && account.lastUsed.Before(best.lastUsed)))) {
+				best = account
+			}
+		}
+		if best != nil {
+			best.inFlight++
+			best.requests++
+			best.lastUsed = time.Now()
+			lease := steamLease{account: best, session: best.session, generation: best.generation}
+			s.mu.Unlock()
+			return lease, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
 
-	log.Printf("Switching to client `%s`", loginInfo.Username)
-	sc, dc, err := initDotaClient(loginInfo, s.onDisconnected)
-	if err != nil {
-		return err
+		select {
+		case <-changed:
+		case <-s.done:
+			return steamLease{}, errSteamPoolClosed
+		case <-ctx.Done():
+			return steamLease{}, ctx.Err()
+		}
 	}
-
-	s.steamClient = sc
-	s.dotaClient = dc
-
-	return nil
 }
 
-func (s *GoSteamService) startKeepAlive() {
-	// Keep-alive every 1 hour to reinitialize the client if it's not ready
-	ticker := time.NewTicker(1 * time.Hour)
-	s.keepAliveTickerMu.Lock()
-	s.keepAliveTicker = ticker
-	s.keepAliveTickerMu.Unlock()
-
-	s.keepAliveRequests = make(chan struct{}, 1)
+func (s *GoSteamService) release(lease steamLease) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lease.account.inFlight--
+}
 
-	go func() {
-		for range s.keepAliveRequests {
-			s.runKeepAlive()
+// AccountHealth reports the state of every account, without credentials
+func (s *GoSteamService) AccountHealth() []dtos.SteamAccountHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	health := make([]dtos.SteamAccountHealth, len(s.accounts))
+	for i, account := range s.accounts {
+		health[i] = dtos.SteamAccountHealth{
+			Username:  account.loginInfo.Username,
+			Priority:  account.priority,
+			State:     account.state,
+			Since:     account.since,
+			InFlight:  account.inFlight,
+			Requests:  account.requests,
+			Failures:  account.failures,
+			LastError: account.lastError,
 		}
-	}()
+		if !account.retryAt.IsZero() {
+			retryAt := account.retryAt
+			health[i].RetryAt = &retryAt
+		}
+	}
+	return health
+}
 
+// startAccount adds an account to the pool and logs it in, it must be called with the pool lock held
+func (s *GoSteamService) startAccount(config SteamAccountConfig) {
+	account := &steamAccount{
+		pool:         s,
+		loginInfo:    &steam.LogOnDetails{Username: config.Username, Password: config.Password},
+		sharedSecret: config.SharedSecret,
+		logger:       s.logger.With(steamAccountLogKey, config.Username),
+		priority:     config.Priority,
+		unhealthy:    make(chan struct{}, 1),
+		guardCodes:   make(chan string, 1),
+		state:        SteamAccountConnecting,
+		since:        time.Now(),
+	}
+	account.ctx, account.stop = context.WithCancel(s.ctx)
+	s.accounts = append(s.accounts, account)
+
+	s.wg.Add(1)
 	go func() {
-		defer ticker.Stop()
-		for range ticker.C {
-			s.requestKeepAlive()
-		}
+		defer s.wg.Done()
+		account.run()
 	}()
 }
 
-func (s *GoSteamService) requestKeepAlive() {
-	if s.keepAliveRequests == nil {
-		return
-	}
+// SetAccounts replaces the accounts of the pool. Removed accounts and accounts whose login changed
+// are logged off, the others keep their session and take the new priority.
+func (s *GoSteamService) SetAccounts(accounts []SteamAccountConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Non-blocking enqueue
 	select {
-	case s.keepAliveRequests <- struct{}{}:
+	case <-s.done:
+		return
 	default:
 	}
-}
 
-func (s *GoSteamService) runKeepAlive() {
-	if !s.steamClient.Connected() {
-		log.Println("Steam client not connected, running keep-alive...")
+	configs := make(map[string]SteamAccountConfig, len(accounts))
+	for _, config := range accounts {
+		configs[config.Username] = config
 	}
 
-	if _, err := s.GetMatchDetails(239); err != nil {
-		log.Printf("Keep-alive error: %v", err)
-	} else {
-		log.Println("Keep-alive success")
+	kept := make(map[string]bool)
+	var running []*steamAccount
+	for _, account := range s.accounts {
+		config, ok := configs[account.loginInfo.Username]
+		if ok && config.Password == account.loginInfo.Password && config.SharedSecret == account.sharedSecret {
+			account.priority = config.Priority
+			kept[config.Username] = true
+			running = append(running, account)
+			continue
+		}
+		account.logger.Info("Steam account was removed or its login changed, logging it off")
+		account.stop()
 	}
-}
+	s.accounts = running
 
-func (s *GoSteamService) onDisconnected() {
-	go func() {
-		log.Println("Requesting keep-alive after disconnect in 5s")
-		time.Sleep(5 * time.Second)
-		if s.steamClient == nil {
-			return
+	for _, config := range accounts {
+		if !kept[config.Username] {
+			s.logger.Info("Steam account was added, logging it in", steamAccountLogKey, config.Username)
+			s.startAccount(config)
 		}
+	}
 
-		s.keepAliveTickerMu.Lock()
-		if s.keepAliveTicker != nil {
-			s.keepAliveTicker.Reset(1 * time.Hour)
+	// Requests waiting for an account pick again with the new priorities
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// CircuitStats reports the state of the circuit breaker of the GC path
+func (s *GoSteamService) CircuitStats() dtos.SteamCircuit {
+	return s.breaker.Stats()
+}
+
+// SubmitGuardCode passes a Steam Guard code sent to the email of an account to its login waiting for it
+func (s *GoSteamService) SubmitGuardCode(username, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, account := range s.accounts {
+		if account.loginInfo.Username != username {
+			continue
+		}
+		if account.state != SteamAccountAwaitingGuardCode {
+			return UserFacingError{Code: fiber.StatusConflict, Message: "Steam account is not waiting for a Steam Guard code"}
 		}
-		s.keepAliveTickerMu.Unlock()
+		select {
+		case account.guardCodes <- code:
+			return nil
+		default:
+			return UserFacingError{Code: fiber.StatusConflict, Message: "A Steam Guard code was already submitted"}
+		}
+	}
+	return UserFacingError{Code: fiber.StatusNotFound, Message: "Steam account not found"}
+}
 
-		s.requestKeepAlive()
-	}()
+// Close logs off every account and stops reconnecting them
+func (s *GoSteamService) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
 }
 
-func initDotaClient(steamLoginInfo *steam.LogOnDetails, onDisconnected func()) (*steam.Client, *dotaGCClient, error) {
+// initDotaClient logs on with a refresh token of the account and waits for a GC session, logging to the logger
+// of the account
+func initDotaClient(steamLoginInfo *steam.LogOnDetails, refreshToken string, onDisconnected func(), logger *slog.Logger) (*steam.Client, *dotaGCClient, error) {
 	sc := steam.NewClient()
 	dialer := &net.Dialer{Timeout: 8 * time.Second, KeepAlive: 30 * time.Second}
 	sc.Dialer = dialer.Dial
-	dc := newDotaGCClient(sc)
+	dc := newDotaGCClient(sc, logger)
 	connectionErrors := make(chan error, 1)
 	helloRetryCtx, stopHelloRetry := context.WithCancel(context.Background())
 	defer stopHelloRetry()
@@ -204,50 +402,43 @@ func initDotaClient(steamLoginInfo *steam.LogOnDetails, onDisconnected func()) (
 		}
 	}
 
-	server, err := connectSteamWebSocket(sc)
+	server, err := connectSteamWebSocket(sc, logger)
 	if err != nil {
 		return nil, nil, err
 	}
-	log.Printf("Steam client connected to websocket server %s\n", server)
+	logger.Debug("Steam client connected to websocket server", "server", server)
 
 	go func() {
 		for event := range sc.Events() {
 			switch e := event.(type) {
 
 			case *steam.ConnectedEvent:
-				log.Println("Connected, attempting to log in...")
-				go func() {
-					authResult, err := sc.Authentication.LogOnWithCredentials(steamLoginInfo.Username, steamLoginInfo.Password)
-					if err != nil {
-						reportConnectionError(fmt.Errorf("steam credential authentication failed: %w", err))
-						return
-					}
-					sc.Auth.LogOn(&steam.LogOnDetails{
-						Username:    authResult.AccountName,
-						AccessToken: authResult.RefreshToken,
-					})
-				}()
+				logger.Debug("Connected to Steam, logging in")
+				sc.Auth.LogOn(&steam.LogOnDetails{
+					Username:    steamLoginInfo.Username,
+					AccessToken: refreshToken,
+				})
 
 			case *steam.LoggedOnEvent:
 				if e.Result != steamlang.EResult_OK {
-					reportConnectionError(fmt.Errorf("steam logon failed: %v", e.Result))
+					reportConnectionError(logOnError(e.Result))
 					continue
 				}
-				log.Println("Logged in to Steam")
+				logger.Info("Logged in to Steam")
 				sc.Social.SetPersonaState(steamlang.EPersonaState_Online)
 				dc.SetPlaying(true)
 				dc.SayHello()
 				go retryDotaHelloUntilReady(helloRetryCtx, dc)
 
 			case *steam.LogOnFailedEvent:
-				reportConnectionError(fmt.Errorf("steam logon failed: %v", e.Result))
+				reportConnectionError(logOnError(e.Result))
 
 			case *steam.AccountInfoEvent:
-				log.Println(e.AccountFlags)
+				logger.Debug("Received Steam account info", "flags", e.AccountFlags)
 
 			case *steam.DisconnectedEvent:
 				stopHelloRetry()
-				log.Printf("Disconnected from Steam :(")
+				logger.Warn("Disconnected from Steam")
 				if onDisconnected != nil {
 					onDisconnected()
 				}
@@ -261,11 +452,9 @@ func initDotaClient(steamLoginInfo *steam.LogOnDetails, onDisconnected func()) (
 		}
 	}()
 
-	// Credential auth is an HTTP polling flow, so allow it more time than the
-	// old password-in-CM handshake needed.
 	select {
 	case <-dc.Ready():
-		log.Println("Dota client is ready with a GC session.")
+		logger.Info("Dota client is ready with a GC session")
 		return sc, dc, nil
 	case err := <-connectionErrors:
 		stopHelloRetry()
@@ -278,6 +467,11 @@ func initDotaClient(steamLoginInfo *steam.LogOnDetails, onDisconnected func()) (
 	}
 }
 
+// logOnError reports a failed logon, which means the refresh token is no longer valid
+func logOnError(result steamlang.EResult) error {
+	return fmt.Errorf("%w: %v", errSteamTokenRejected, result)
+}
+
 func retryDotaHelloUntilReady(ctx context.Context, dc *dotaGCClient) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
@@ -294,10 +488,14 @@ func retryDotaHelloUntilReady(ctx context.Context, dc *dotaGCClient) {
 	}
 }
 
-func connectSteamWebSocket(sc *steam.Client) (string, error) {
+// steamCMServers lists the websocket CM servers to connect to, least loaded first.
+// It is replaced in tests by a fake CM.
+var steamCMServers = fetchSteamCMServers
+
+func fetchSteamCMServers() ([]steam.CMServer, error) {
 	servers, err := steam.FetchCMListForConnect(0)
 	if err != nil {
-		return "", fmt.Errorf("fetch Steam CM list: %w", err)
+		return nil, fmt.Errorf("fetch Steam CM list: %w", err)
 	}
 
 	websocketServers := steam.FilterByType(servers, "websockets")
@@ -308,15 +506,23 @@ func connectSteamWebSocket(sc *steam.Client) (string, error) {
 		}
 	}
 	if len(secureServers) == 0 {
-		return "", errors.New("steam directory returned no secure websocket servers")
+		return nil, errors.New("steam directory returned no secure websocket servers")
 	}
 	sort.Slice(secureServers, func(i, j int) bool {
 		return secureServers[i].WeightedLoad < secureServers[j].WeightedLoad
 	})
+	return secureServers, nil
+}
+
+func connectSteamWebSocket(sc *steam.Client, logger *slog.Logger) (string, error) {
+	servers, err := steamCMServers()
+	if err != nil {
+		return "", err
+	}
 
 	const maxAttempts = 5
 	var lastErr error
-	for i, server := range secureServers {
+	for i, server := range servers {
 		if i >= maxAttempts {
 			break
 		}
@@ -324,7 +530,7 @@ func connectSteamWebSocket(sc *steam.Client) (string, error) {
 			return server.Endpoint, nil
 		} else {
 			lastErr = err
-			log.Printf("Steam websocket %s is unavailable: %v", server.Endpoint, err)
+			logger.Warn("Steam websocket server is unavailable", "server", server.Endpoint, "error", err)
 		}
 
 		// ConnectToWebSocket reports the same dial failure as a fatal event.
@@ -338,20 +544,3 @@ func connectSteamWebSocket(sc *steam.Client) (string, error) {
 
 	return "", fmt.Errorf("connect to Steam websocket after %d attempts: %w", maxAttempts, lastErr)
 }
-
-func (s *GoSteamService) gracefulDisconnect() {
-	if s.steamClient == nil {
-		return
-	}
-
-	if s.dotaClient != nil {
-		s.dotaClient.SetPlaying(false)
-	}
-
-	if s.steamClient.Connected() {
-		s.steamClient.Social.SetPersonaState(steamlang.EPersonaState_Offline)
-		s.steamClient.Write(steamproto.NewClientMsgProtobuf(steamlang.EMsg_ClientLogOff, new(steampb.CMsgClientLogOff)))
-		time.Sleep(250 * time.Millisecond)
-	}
-	s.steamClient.Disconnect()
-}
@@ -2,8 +2,21 @@ package services
 
 import (
 	"context"
+	"errors"
+	"go-glyph/internal/core/tracing"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	dotaproto "github.com/dotabuff/manta/dota"
+	"github.com/sicdex/go-steam-ws"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestRetryDotaHelloUntilReadyStopsWhenCanceled(t *testing.T) {
@@ -24,3 +37,291 @@ func TestRetryDotaHelloUntilReadyStopsWhenCanceled(t *testing.T) {
 		t.Fatal("Dota hello retry did not stop after cancellation")
 	}
 }
+
+// fakeSteamSession answers match details requests with respond
+type fakeSteamSession struct {
+	respond      func(ctx context.Context, matchID uint64) error
+	disconnected atomic.Bool
+}
+
+func (s *fakeSteamSession) RequestMatchDetails(ctx context.Context, matchID uint64) (*dotaproto.CMsgGCMatchDetailsResponse, error) {
+	if err := s.respond(ctx, matchID); err != nil {
+		return nil, err
+	}
+	return &dotaproto.CMsgGCMatchDetailsResponse{Match: &dotaproto.CMsgDOTAMatch{
+		MatchId:    proto.Uint64(matchID),
+		Cluster:    proto.Uint32(111),
+		ReplaySalt: proto.Uint32(42),
+	}}, nil
+}
+
+func (s *fakeSteamSession) Disconnect() {
+	s.disconnected.Store(true)
+}
+
+// fakeSteamPool creates a pool whose sessions are created by respond per username, counting logins
+func fakeSteamPool(t *testing.T, usernames []string, respond func(username string, ctx context.Context) error) (*GoSteamService, *sync.Map) {
+	var logins sync.Map
+	var accounts []SteamAccountConfig
+	for _, username := range usernames {
+		accounts = append(accounts, SteamAccountConfig{Username: username})
+		logins.Store(username, new(atomic.Int32))
+	}
+
+	connect := func(ctx context.Context, loginInfo *steam.LogOnDetails, guard steamGuard, onDisconnected func()) (steamSession, error) {
+		count, _ := logins.LoadOrStore(loginInfo.Username, new(atomic.Int32))
+		count.(*atomic.Int32).Add(1)
+		return &fakeSteamSession{respond: func(ctx context.Context, matchID uint64) error {
+			return respond(loginInfo.Username, ctx)
+		}}, nil
+	}
+
+	s := newGoSteamService(accounts, connect, 100*time.Millisecond, slog.Default())
+	s.retryDelay = time.Millisecond
+	t.Cleanup(s.Close)
+	return s, &logins
+}
+
+func waitForReadyAccounts(t *testing.T, s *GoSteamService, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		ready := 0
+		for _, account := range s.AccountHealth() {
+			if account.State == SteamAccountReady {
+				ready++
+			}
+		}
+		if ready == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d ready accounts, got %+v", want, s.AccountHealth())
+}
+
+func TestGoSteamServiceDispatchesToLeastBusyAccount(t *testing.T) {
+	release := make(chan struct{})
+	var served sync.Map
+	s, _ := fakeSteamPool(t, []string{"first", "second"}, func(username string, ctx context.Context) error {
+		served.Store(username, true)
+		<-release
+		return nil
+	})
+	waitForReadyAccounts(t, s, 2)
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := s.GetMatchDetails(context.Background(), 1234)
+			results <- err
+		}()
+	}
+
+	// Both requests are in flight at once, on different accounts
+	deadline := time.Now().Add(time.Second)
+	for {
+		health := s.AccountHealth()
+		if health[0].InFlight == 1 && health[1].InFlight == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a request in flight on each account, got %+v", health)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("GetMatchDetails returned error: %v", err)
+		}
+	}
+}
+
+func TestGoSteamServiceQuarantinesAndReconnects(t *testing.T) {
+	var failing atomic.Bool
+	var timeouts atomic.Int32
+	failing.Store(true)
+	s, logins := fakeSteamPool(t, []string{"broken", "healthy"}, func(username string, ctx context.Context) error {
+		if username == "broken" && failing.Load() {
+			timeouts.Add(1)
+			return context.DeadlineExceeded
+		}
+		return nil
+	})
+	waitForReadyAccounts(t, s, 2)
+
+	// Whether the broken account is tried first or second, every request succeeds on the healthy one
+	for i := 0; i < 4*steamQuarantineTimeouts && s.AccountHealth()[0].State == SteamAccountReady; i++ {
+		match, err := s.GetMatchDetails(context.Background(), 1234)
+		if err != nil || match.Cluster != 111 || match.ReplaySalt != 42 {
+			t.Fatalf("expected match details from the healthy account, got %+v (%v)", match, err)
+		}
+	}
+
+	health := s.AccountHealth()
+	if health[0].Failures != 1 || health[0].LastError == "" {
+		t.Fatalf("expected the broken account to be quarantined once, got %+v", health[0])
+	}
+	if got := timeouts.Load(); got != steamQuarantineTimeouts {
+		t.Fatalf("expected the broken account to be quarantined after %d timeouts, got %d", steamQuarantineTimeouts, got)
+	}
+
+	// The quarantined account logs in again in the background
+	failing.Store(false)
+	waitForReadyAccounts(t, s, 2)
+	count, _ := logins.Load("broken")
+	if count.(*atomic.Int32).Load() != 2 {
+		t.Fatalf("expected the broken account to log in twice, got %d", count.(*atomic.Int32).Load())
+	}
+}
+
+func TestGoSteamServiceToleratesOccasionalTimeouts(t *testing.T) {
+	var requests atomic.Int32
+	s, _ := fakeSteamPool(t, []string{"slow"}, func(username string, ctx context.Context) error {
+		// Every third request is answered, so fewer than steamQuarantineTimeouts time out in a row
+		if requests.Add(1)%steamQuarantineTimeouts != 0 {
+			return context.DeadlineExceeded
+		}
+		return nil
+	})
+	waitForReadyAccounts(t, s, 1)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetMatchDetails(context.Background(), 1234); err != nil {
+			t.Fatalf("expected the retry to be answered, got %v", err)
+		}
+	}
+	if health := s.AccountHealth(); health[0].State != SteamAccountReady || health[0].Failures != 0 {
+		t.Fatalf("expected the account to stay ready, got %+v", health[0])
+	}
+}
+
+func TestGoSteamServiceWaitsForSubmittedGuardCode(t *testing.T) {
+	codes := make(chan string, 1)
+	connect := func(ctx context.Context, loginInfo *steam.LogOnDetails, guard steamGuard, onDisconnected func()) (steamSession, error) {
+		code, err := guard.EmailCode(ctx)
+		if err != nil {
+			return nil, err
+		}
+		codes <- code
+		return &fakeSteamSession{respond: func(ctx context.Context, matchID uint64) error { return nil }}, nil
+	}
+	s := newGoSteamService([]SteamAccountConfig{{Username: "guarded"}}, connect, 100*time.Millisecond, slog.Default())
+	t.Cleanup(s.Close)
+
+	var err error
+	if err = s.SubmitGuardCode("unknown", "ABCDE"); err.(UserFacingError).Code != 404 {
+		t.Fatalf("expected an unknown account to be rejected, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for s.AccountHealth()[0].State != SteamAccountAwaitingGuardCode {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the account to await a Steam Guard code, got %+v", s.AccountHealth()[0])
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.SubmitGuardCode("guarded", "ABCDE"); err != nil {
+		t.Fatalf("SubmitGuardCode returned error: %v", err)
+	}
+	if code := <-codes; code != "ABCDE" {
+		t.Fatalf("expected the submitted code, got %s", code)
+	}
+	waitForReadyAccounts(t, s, 1)
+
+	if err = s.SubmitGuardCode("guarded", "ABCDE"); err.(UserFacingError).Code != 409 {
+		t.Fatalf("expected a ready account to reject codes, got %v", err)
+	}
+}
+
+func TestGoSteamServiceSetAccounts(t *testing.T) {
+	var served sync.Map
+	s, logins := fakeSteamPool(t, []string{"kept", "removed"}, func(username string, ctx context.Context) error {
+		served.Store(username, true)
+		return nil
+	})
+	waitForReadyAccounts(t, s, 2)
+
+	s.SetAccounts([]SteamAccountConfig{{Username: "kept", Priority: 1}, {Username: "added", Priority: 2}})
+	waitForReadyAccounts(t, s, 2)
+
+	health := s.AccountHealth()
+	if len(health) != 2 || health[0].Username != "kept" || health[0].Priority != 1 || health[1].Username != "added" {
+		t.Fatalf("expected the kept and added accounts, got %+v", health)
+	}
+	if count, _ := logins.Load("kept"); count.(*atomic.Int32).Load() != 1 {
+		t.Fatalf("expected the kept account to keep its session, it logged in %d times", count.(*atomic.Int32).Load())
+	}
+
+	// Requests go to the account of the highest priority
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetMatchDetails(context.Background(), 1234); err != nil {
+			t.Fatalf("GetMatchDetails returned error: %v", err)
+		}
+	}
+	if _, ok := served.Load("kept"); ok {
+		t.Fatal("expected no request on the account of lower priority")
+	}
+	if _, ok := served.Load("added"); !ok {
+		t.Fatal("expected requests on the account of the highest priority")
+	}
+}
+
+func TestGoSteamServiceGivesUpWithItsCaller(t *testing.T) {
+	s, _ := fakeSteamPool(t, []string{"slow"}, func(username string, ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	waitForReadyAccounts(t, s, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := s.GetMatchDetails(ctx, 1234); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the lookup to be canceled, got %v", err)
+	}
+
+	// Neither the account nor the GC is to blame for a canceled request
+	if health := s.AccountHealth(); health[0].State != SteamAccountReady || health[0].Failures != 0 {
+		t.Fatalf("expected the account to stay ready, got %+v", health[0])
+	}
+	if stats := s.CircuitStats(); stats.Failures != 0 {
+		t.Fatalf("expected no failure counted by the circuit breaker, got %+v", stats)
+	}
+}
+
+func TestGoSteamServiceTracesTheAccountUsed(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	s, _ := fakeSteamPool(t, []string{"traced"}, func(username string, ctx context.Context) error {
+		return nil
+	})
+	waitForReadyAccounts(t, s, 1)
+
+	if _, err := s.GetMatchDetails(context.Background(), 1234); err != nil {
+		t.Fatalf("GetMatchDetails returned error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "steam.RequestMatchDetails" || spans[1].Name() != "steam.GetMatchDetails" {
+		t.Fatalf("expected a GC request span inside the lookup span, got %v", spans)
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Fatal("expected the GC request span to be a child of the lookup span")
+	}
+	attributes := attribute.NewSet(spans[0].Attributes()...)
+	if account, _ := attributes.Value(tracing.SteamAccountKey); account.AsString() != "traced" {
+		t.Fatalf("expected the account used on the GC request span, got %v", spans[0].Attributes())
+	}
+	if cluster, _ := attributes.Value(tracing.ReplayClusterKey); cluster.AsInt64() != 111 {
+		t.Fatalf("expected the replay cluster on the GC request span, got %v", spans[0].Attributes())
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package services
+
+import "errors"
+
+// diskFree is only implemented on Linux and macOS, where syscall.Statfs_t is known, the disk check is skipped elsewhere
+func diskFree(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
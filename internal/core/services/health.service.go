@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-glyph/internal/core/dtos"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMinFreeDisk = 1 << 30
+	// healthCheckTimeout bounds the database ping of a health check
+	healthCheckTimeout = 2 * time.Second
+	// demosCheckInterval is how long the writability of the demo directory is not checked again,
+	// so probes every few seconds do not create a file each
+	demosCheckInterval = 30 * time.Second
+)
+
+// Dependencies reported by the health checks
+const (
+	DatabaseDependency = "database"
+	SteamDependency    = "steam"
+	DemosDependency    = "demos"
+	DiskDependency     = "disk"
+)
+
+type HealthServiceDatabase interface {
+	PingContext(ctx context.Context) error
+}
+
+type HealthServiceSteam interface {
+	AccountHealth() []dtos.SteamAccountHealth
+	CircuitStats() dtos.SteamCircuit
+}
+
+// HealthService checks the dependencies a match is processed with, remembering the last error of each
+type HealthService struct {
+	database    HealthServiceDatabase
+	steam       HealthServiceSteam
+	demosDir    string
+	minFreeDisk uint64
+
+	mu         sync.Mutex
+	lastErrors map[string]healthError
+	// demosErr is the result of the last writability check of the demo directory, done at demosCheckedAt
+	demosErr       error
+	demosCheckedAt time.Time
+}
+
+type healthError struct {
+	message string
+	at      time.Time
+}
+
+// NewHealthService creates a service checking the database, the Steam accounts, that demos can be written
+// and that at least minFreeDisk bytes are free for them (1 GB if 0)
+func NewHealthService(database HealthServiceDatabase, steam HealthServiceSteam, minFreeDisk uint64) *HealthService {
+	if minFreeDisk == 0 {
+		minFreeDisk = defaultMinFreeDisk
+	}
+	return &HealthService{
+		database:    database,
+		steam:       steam,
+		demosDir:    demosPath,
+		minFreeDisk: minFreeDisk,
+		lastErrors:  make(map[string]healthError),
+	}
+}
+
+// Status checks every dependency, the server is ready if all of them are
+func (s *HealthService) Status(ctx context.Context) dtos.HealthStatus {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) (string, error)
+	}{
+		{DatabaseDependency, s.checkDatabase},
+		{SteamDependency, s.checkSteam},
+		{DemosDependency, s.checkDemos},
+		{DiskDependency, s.checkDisk},
+	}
+
+	status := dtos.HealthStatus{Ready: true}
+	for _, c := range checks {
+		detail, err := c.check(ctx)
+		dependency := s.record(c.name, detail, err, time.Now())
+		status.Ready = status.Ready && dependency.Ready
+		status.Dependencies = append(status.Dependencies, dependency)
+	}
+	return status
+}
+
+// record builds the status of a dependency from its check, keeping the last error
+func (s *HealthService) record(name, detail string, err error, at time.Time) dtos.DependencyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.lastErrors[name] = healthError{message: err.Error(), at: at}
+	}
+	dependency := dtos.DependencyStatus{Name: name, Ready: err == nil, Detail: detail, CheckedAt: at}
+	if lastError, ok := s.lastErrors[name]; ok {
+		dependency.LastError = lastError.message
+		dependency.LastErrorAt = &lastError.at
+	}
+	return dependency
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if err := s.database.PingContext(ctx); err != nil {
+		return "", fmt.Errorf("database is unreachable: %w", err)
+	}
+	return "", nil
+}
+
+// checkSteam needs an account whose GC session is ready, accounts are only ready once the GC welcomed them
+func (s *HealthService) checkSteam(context.Context) (string, error) {
+	accounts := s.steam.AccountHealth()
+	ready := 0
+	for _, account := range accounts {
+		if account.State == SteamAccountReady {
+			ready++
+		}
+	}
+
+	detail := fmt.Sprintf("%d of %d accounts ready, circuit breaker %s", ready, len(accounts), s.steam.CircuitStats().State)
+	if ready == 0 {
+		return detail, errors.New("no Steam account has a Dota GC session")
+	}
+	return detail, nil
+}
+
+// checkDemos reuses the last writability check of the demo directory for demosCheckInterval
+func (s *HealthService) checkDemos(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.Sub(s.demosCheckedAt) >= demosCheckInterval {
+		s.demosErr = s.writeDemoProbe()
+		s.demosCheckedAt = now
+	}
+	return "", s.demosErr
+}
+
+// writeDemoProbe creates and removes a file in the demo directory
+func (s *HealthService) writeDemoProbe() error {
+	if err := os.MkdirAll(s.demosDir, os.ModePerm); err != nil {
+		return FolderCreationError{foldername: s.demosDir, error: err}
+	}
+	file, err := os.CreateTemp(s.demosDir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("demo directory is not writable: %w", err)
+	}
+	_ = file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return RemoveFileError{filename: file.Name(), error: err}
+	}
+	return nil
+}
+
+func (s *HealthService) checkDisk(context.Context) (string, error) {
+	free, err := diskFree(s.demosDir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return "free disk space is not checked on this platform", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read free disk space: %w", err)
+	}
+
+	detail := fmt.Sprintf("%d MB free, %d MB required", free>>20, s.minFreeDisk>>20)
+	if free < s.minFreeDisk {
+		return detail, errors.New("not enough free disk space for demos")
+	}
+	return detail, nil
+}
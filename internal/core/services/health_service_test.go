@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-glyph/internal/core/dtos"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeHealthDatabase struct {
+	err error
+}
+
+func (d *fakeHealthDatabase) PingContext(ctx context.Context) error {
+	return d.err
+}
+
+type fakeHealthSteam struct {
+	states []string
+}
+
+func (s *fakeHealthSteam) AccountHealth() []dtos.SteamAccountHealth {
+	accounts := make([]dtos.SteamAccountHealth, len(s.states))
+	for i, state := range s.states {
+		accounts[i] = dtos.SteamAccountHealth{Username: "account", State: state}
+	}
+	return accounts
+}
+
+func (s *fakeHealthSteam) CircuitStats() dtos.SteamCircuit {
+	return dtos.SteamCircuit{State: SteamCircuitClosed}
+}
+
+func dependencyStatus(t *testing.T, status dtos.HealthStatus, name string) dtos.DependencyStatus {
+	t.Helper()
+	for _, dependency := range status.Dependencies {
+		if dependency.Name == name {
+			return dependency
+		}
+	}
+	t.Fatalf("expected dependency %s in %+v", name, status)
+	return dtos.DependencyStatus{}
+}
+
+func TestHealthServiceReportsDependencies(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	database := &fakeHealthDatabase{}
+	steam := &fakeHealthSteam{states: []string{SteamAccountQuarantined, SteamAccountReady}}
+	s := NewHealthService(database, steam, 1)
+
+	status := s.Status(context.Background())
+	if !status.Ready || len(status.Dependencies) != 4 {
+		t.Fatalf("expected every dependency to be ready, got %+v", status)
+	}
+	if detail := dependencyStatus(t, status, SteamDependency).Detail; detail != "1 of 2 accounts ready, circuit breaker closed" {
+		t.Fatalf("unexpected Steam detail %q", detail)
+	}
+	if entries, _ := os.ReadDir(demosPath); len(entries) != 0 {
+		t.Fatalf("expected the writability check to clean up, got %v", entries)
+	}
+
+	// A failed dependency makes the server not ready, its error is kept once it recovered
+	database.err = errors.New("connection refused")
+	steam.states = []string{SteamAccountConnecting}
+	status = s.Status(context.Background())
+	if status.Ready || dependencyStatus(t, status, DatabaseDependency).Ready || dependencyStatus(t, status, SteamDependency).Ready {
+		t.Fatalf("expected the database and Steam not to be ready, got %+v", status)
+	}
+
+	database.err = nil
+	steam.states = []string{SteamAccountReady}
+	db := dependencyStatus(t, s.Status(context.Background()), DatabaseDependency)
+	if !db.Ready || db.LastError != "database is unreachable: connection refused" || db.LastErrorAt == nil {
+		t.Fatalf("expected the recovered database to keep its last error, got %+v", db)
+	}
+}
+
+func TestHealthServiceChecksDemoDirectory(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	s := NewHealthService(&fakeHealthDatabase{}, &fakeHealthSteam{states: []string{SteamAccountReady}}, 1<<62)
+	s.demosDir = filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(s.demosDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	status := s.Status(context.Background())
+	if demos := dependencyStatus(t, status, DemosDependency); demos.Ready || demos.LastError == "" {
+		t.Fatalf("expected a demo directory that is a file not to be ready, got %+v", demos)
+	}
+
+	s.demosDir = t.TempDir()
+	disk := dependencyStatus(t, s.Status(context.Background()), DiskDependency)
+	if disk.Ready {
+		t.Fatalf("expected the disk not to have 4 EB free, got %+v", disk)
+	}
+}
+
+func TestHealthServiceReusesDemoDirectoryCheck(t *testing.T) {
+	s := NewHealthService(&fakeHealthDatabase{}, &fakeHealthSteam{states: []string{SteamAccountReady}}, 1)
+	s.demosDir = t.TempDir()
+	if demos := dependencyStatus(t, s.Status(context.Background()), DemosDependency); !demos.Ready {
+		t.Fatalf("expected the demo directory to be ready, got %+v", demos)
+	}
+
+	// The directory is only checked again once demosCheckInterval passed
+	s.demosDir = filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(s.demosDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if demos := dependencyStatus(t, s.Status(context.Background()), DemosDependency); !demos.Ready {
+		t.Fatalf("expected the last check to be reused, got %+v", demos)
+	}
+	s.demosCheckedAt = s.demosCheckedAt.Add(-demosCheckInterval)
+	if demos := dependencyStatus(t, s.Status(context.Background()), DemosDependency); demos.Ready {
+		t.Fatalf("expected the demo directory to be checked again, got %+v", demos)
+	}
+}
@@ -1,139 +1,168 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"context"
 	"fmt"
-	"math"
+	"io"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/dotabuff/manta"
-	"github.com/dotabuff/manta/dota"
-	"golang.org/x/exp/slices"
+	dotaproto "github.com/dotabuff/manta/dota"
 
-	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/extractors"
+	"go-glyph/internal/core/metrics"
 	"go-glyph/internal/core/models"
+	"go-glyph/internal/core/tracing"
 )
 
+const glyphExtractor = "glyphs"
+
+// bzip2Magic starts every bzip2 stream, replays are served by Valve in this format
+var bzip2Magic = []byte("BZh")
+
 type MantaService struct {
+	extractorNames []string
+}
+
+// NewMantaService creates a service that runs the given extractors on every demo, all registered extractors if empty
+func NewMantaService(extractorNames []string) *MantaService {
+	return &MantaService{extractorNames: extractorNames}
+}
+
+// GetGlyphsFromDem parses the demo file once with the given extractors (service defaults if empty).
+// Glyphs are always extracted. The parse is stopped once ctx is done.
+func (s MantaService) GetGlyphsFromDem(ctx context.Context, filename string, extractorNames []string) ([]models.Glyph, models.Match, error) {
+	result, err := s.ParseFile(ctx, filename, s.withGlyphs(extractorNames))
+	if err != nil {
+		return nil, models.Match{}, err
+	}
+
+	return glyphsFromResult(result)
+}
+
+// GetGlyphsFromReplay is GetGlyphsFromDem for a replay streamed from r, plain or bzip2 compressed.
+// The match ID written in the demo must be the claimed one.
+func (s MantaService) GetGlyphsFromReplay(ctx context.Context, r io.Reader, matchID int, extractorNames []string) ([]models.Glyph, models.Match, error) {
+	selected, err := extractors.New(s.withGlyphs(extractorNames))
+	if err != nil {
+		return nil, models.Match{}, UserFacingError{Code: fiber.StatusBadRequest, Message: err.Error()}
+	}
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(bzip2Magic)); bytes.Equal(magic, bzip2Magic) {
+		r = bzip2.NewReader(br)
+	} else {
+		r = br
+	}
+
+	result, err := parseStream(ctx, r, matchID, selected)
+	if err != nil {
+		return nil, models.Match{}, err
+	}
+
+	// CDemoFileInfo comes last, so the check can only be done once the whole demo is read
+	if result.DemoMatchID != matchID {
+		return nil, models.Match{}, UserFacingError{
+			Code:    fiber.StatusBadRequest,
+			Message: fmt.Sprintf("Replay is of match %d, not %d", result.DemoMatchID, matchID),
+		}
+	}
+
+	return glyphsFromResult(result)
+}
+
+// withGlyphs falls back to the service defaults and makes sure glyphs are extracted
+func (s MantaService) withGlyphs(extractorNames []string) []string {
+	if len(extractorNames) == 0 {
+		extractorNames = s.extractorNames
+	}
+	if len(extractorNames) > 0 {
+		extractorNames = append([]string{glyphExtractor}, extractorNames...)
+	}
+	return extractorNames
 }
 
-func NewMantaService() *MantaService {
-	return &MantaService{}
+// glyphsFromResult returns the glyphs and match of a parse, a match without glyphs is parsed all the same
+func glyphsFromResult(result extractors.Result) ([]models.Glyph, models.Match, error) {
+	if result.Glyphs == nil {
+		result.Glyphs = []models.Glyph{}
+	}
+	return result.Glyphs, result.Match, nil
 }
 
-func (s MantaService) GetGlyphsFromDem(match dtos.Match) ([]models.Glyph, error) {
-	filename := fmt.Sprintf("demos/%d.dem", match.ID)
+// ParseFile runs the given extractors over the demo file in a single pass.
+// The match ID is taken from the CDemoFileInfo of the demo.
+func (s MantaService) ParseFile(ctx context.Context, filename string, extractorNames []string) (result extractors.Result, err error) {
+	selected, err := extractors.New(extractorNames)
+	if err != nil {
+		return extractors.Result{}, UserFacingError{Code: fiber.StatusBadRequest, Message: err.Error()}
+	}
 
 	// Open file to parse
 	f, err := os.Open(filename)
 	if err != nil {
-		return nil, OpenFileError{filename: filename, error: err}
+		return extractors.Result{}, OpenFileError{filename: filename, error: err}
 	}
 
 	// Handle defer errors
-	defer func(name string) {
-		if tempErr := os.Remove(name); tempErr != nil {
-			err = RemoveFileError{filename: filename, error: tempErr}
-		}
-	}(filename)
-
 	defer func(f *os.File) {
 		if tempErr := f.Close(); tempErr != nil {
 			err = CloseFileError{filename: filename, error: tempErr}
 		}
 	}(f)
 
-	// Create stream parser
-	p, err := manta.NewStreamParser(f)
+	matchID, err := extractors.ReadDemoMatchID(f)
 	if err != nil {
-		return nil, ParserCreationError{err}
+		return extractors.Result{}, DemoMatchIDError{filename: filename, error: err}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return extractors.Result{}, OpenFileError{filename: filename, error: err}
 	}
-	defer p.Stop()
-
-	// Declare some variables for parsing
-	var (
-		gameCurrentTime, gameStartTime float64
-		gamePaused                     bool
-		pauseStartTick                 int32
-		totalPausedTicks               int32
-
-		heroPlayers = make([]dtos.HeroPlayer, 10)
-		glyphs      []models.Glyph
-		glyph       models.Glyph
 
-		pendingHeroes = make(map[int]bool)
-	)
+	return parseStream(ctx, f, matchID, selected)
+}
 
-	for i := 0; i < 10; i++ {
-		pendingHeroes[i] = true
-	}
+// parseStream runs the extractors over a demo read from r in a single pass, stopping once ctx is done
+func parseStream(ctx context.Context, r io.Reader, matchID int, selected []extractors.Extractor) (result extractors.Result, err error) {
+	ctx, span := tracing.Start(ctx, "replay.Parse", tracing.MatchIDKey.Int(matchID))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
-	p.Callbacks.OnCDOTAUserMsg_SpectatorPlayerUnitOrders(func(m *dota.CDOTAUserMsg_SpectatorPlayerUnitOrders) error {
-		if m.GetOrderType() == int32(dota.DotaunitorderT_DOTA_UNIT_ORDER_GLYPH) {
-			entity := p.FindEntity(m.GetEntindex())
-			glyph = models.Glyph{
-				MatchID:     match.ID,
-				Username:    entity.Get("m_iszPlayerName").(string),
-				UserSteamID: strconv.FormatInt(int64(entity.Get("m_steamID").(uint64)), 10),
-				Minute:      uint32(gameCurrentTime-gameStartTime) / 60,
-				Second:      uint32(math.Round(gameCurrentTime-gameStartTime)) % 60,
-				Team:        entity.Get("m_iTeamNum").(uint64),
-			}
-			if !slices.Contains(glyphs, glyph) {
-				glyphs = append(glyphs, glyph)
-			}
+	// Create stream parser, reads fail once ctx is done so a parse waiting on a slow stream stops too
+	p, err := manta.NewStreamParser(newContextReader(ctx, r))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return extractors.Result{}, ctxErr
 		}
-		return nil
-	})
+		return extractors.Result{}, ParserCreationError{err}
+	}
+	defer p.Stop()
 
-	p.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
-		switch e.GetClassName() {
-		case "CDOTAGamerulesProxy":
-			gameStartTime = float64(e.Get("m_pGameRules.m_flGameStartTime").(float32))
-			gamePaused = e.Get("m_pGameRules.m_bGamePaused").(bool)
-			pauseStartTick = e.Get("m_pGameRules.m_nPauseStartTick").(int32)
-			totalPausedTicks = e.Get("m_pGameRules.m_nTotalPausedTicks").(int32)
-			if gamePaused {
-				gameCurrentTime = float64((pauseStartTick - totalPausedTicks) / 30)
-			} else {
-				gameCurrentTime = float64((int32(p.NetTick) - totalPausedTicks) / 30)
-			}
-		case "CDOTA_PlayerResource":
-			if len(pendingHeroes) == 0 {
-				return nil
-			}
-
-			for i := range pendingHeroes {
-				heroPlayers[i].PlayerID, _ = e.GetUint64("m_vecPlayerData.000" + strconv.Itoa(i) + ".m_iPlayerSteamID")
-				newHeroID, ok := e.GetInt32("m_vecPlayerTeamData.000" + strconv.Itoa(i) + ".m_nSelectedHeroID")
-				if ok && newHeroID > 0 && newHeroID < 1000000 {
-					heroPlayers[i].HeroID = uint32(newHeroID)
-					delete(pendingHeroes, i)
-				}
-			}
+	// The parser is not safe for concurrent use, so it is stopped from its own goroutine on the next tick
+	p.Callbacks.OnCNETMsg_Tick(func(*dotaproto.CNETMsg_Tick) error {
+		if ctx.Err() != nil {
+			p.Stop()
 		}
-
 		return nil
 	})
 
-	if err = p.Start(); err != nil {
-		return nil, ParserError{err}
-	}
-
-	for k := range glyphs {
-		for l := range heroPlayers {
-			if glyphs[k].UserSteamID == strconv.FormatInt(int64(heroPlayers[l].PlayerID), 10) {
-				glyphs[k].HeroID = heroPlayers[l].HeroID
-				break
-			}
-		}
+	start := time.Now()
+	result, err = extractors.Run(p, matchID, selected)
+	metrics.ParseDuration.Observe(time.Since(start).Seconds())
+	// A stopped parse ends without error, its result is incomplete
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return extractors.Result{}, ctxErr
 	}
-
-	if len(glyphs) == 0 {
-		return glyphs, UserFacingError{Code: fiber.StatusNotFound, Message: "No glyphs found lol"}
+	if err != nil {
+		return extractors.Result{}, ParserError{err}
 	}
 
-	return glyphs, err
+	return result, nil
 }
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetGlyphsFromReplayStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := NewMantaService(nil).GetGlyphsFromReplay(ctx, bytes.NewReader(testDemoBz2), testMatch.ID, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the parse to be canceled, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+	"go-glyph/internal/core/validator"
+)
+
+type MatchServiceMatchRepository interface {
+	GetMatch(matchID int) (models.Match, error)
+	MatchExists(matchID int) (bool, error)
+	CreateMatch(newMatch *models.Match, glyphs []models.Glyph) error
+	GetPlayerWards(userSteamID string, wardType string) ([]models.Ward, error)
+}
+
+type MatchServiceGlyphRepository interface {
+	GetGlyphs(matchID int) ([]models.Glyph, error)
+}
+
+type MatchService struct {
+	MatchServiceMatchRepository MatchServiceMatchRepository
+	MatchServiceGlyphRepository MatchServiceGlyphRepository
+}
+
+func NewMatchService(matchServiceMatchRepository MatchServiceMatchRepository,
+	matchServiceGlyphRepository MatchServiceGlyphRepository) *MatchService {
+	return &MatchService{
+		MatchServiceMatchRepository: matchServiceMatchRepository,
+		MatchServiceGlyphRepository: matchServiceGlyphRepository,
+	}
+}
+
+func (s *MatchService) GetMatch(getMatch *dtos.GetMatch) (dtos.MatchParse, error) {
+	err := validator.ValidateStruct(getMatch)
+	if err != nil {
+		return dtos.MatchParse{}, ValidateError{err}
+	}
+
+	matchParsed, err := s.MatchServiceMatchRepository.MatchExists(getMatch.MatchID)
+	if err != nil {
+		return dtos.MatchParse{}, RepositoryError{err}
+	}
+	if !matchParsed {
+		return dtos.MatchParse{MatchParsed: false}, nil
+	}
+
+	match, err := s.MatchServiceMatchRepository.GetMatch(getMatch.MatchID)
+	if err != nil {
+		return dtos.MatchParse{}, RepositoryError{err}
+	}
+
+	return dtos.MatchParse{MatchParsed: true, Match: match}, nil
+}
+
+// CreateMatch saves a parsed match with its glyphs, which may be none
+func (s *MatchService) CreateMatch(createMatch *dtos.CreateMatch) error {
+	err := validator.ValidateStruct(createMatch)
+	if err != nil {
+		return ValidateError{err}
+	}
+
+	matchParsed, err := s.MatchServiceMatchRepository.MatchExists(createMatch.Match.MatchID)
+	if err != nil {
+		return RepositoryError{err}
+	}
+	if matchParsed {
+		return MatchAlreadyParsedError{}
+	}
+
+	err = s.MatchServiceMatchRepository.CreateMatch(&createMatch.Match, createMatch.Glyphs)
+	if err != nil {
+		return RepositoryError{err}
+	}
+	return nil
+}
+
+// GetTimeline merges glyphs and objective events of a parsed match ordered by game time
+func (s *MatchService) GetTimeline(getMatch *dtos.GetMatch) ([]dtos.TimelineEvent, error) {
+	matchParse, err := s.GetMatch(getMatch)
+	if err != nil {
+		return nil, err
+	}
+	if !matchParse.MatchParsed {
+		return nil, UserFacingError{Code: fiber.StatusNotFound, Message: "Match is not parsed yet"}
+	}
+
+	glyphs, err := s.MatchServiceGlyphRepository.GetGlyphs(getMatch.MatchID)
+	if err != nil {
+		return nil, RepositoryError{err}
+	}
+
+	timeline := make([]dtos.TimelineEvent, 0, len(glyphs)+len(matchParse.Match.Objectives))
+	for _, glyph := range glyphs {
+		timeline = append(timeline, dtos.TimelineEvent{
+			Type:        "glyph",
+			Team:        glyph.Team,
+			Username:    glyph.Username,
+			UserSteamID: glyph.UserSteamID,
+			HeroID:      glyph.HeroID,
+			Minute:      glyph.Minute,
+			Second:      glyph.Second,
+		})
+	}
+	for _, objective := range matchParse.Match.Objectives {
+		timeline = append(timeline, dtos.TimelineEvent{
+			Type:        objective.Type,
+			Team:        objective.Team,
+			Username:    objective.Username,
+			UserSteamID: objective.UserSteamID,
+			HeroID:      objective.HeroID,
+			Minute:      objective.Minute,
+			Second:      objective.Second,
+		})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Minute*60+timeline[i].Second < timeline[j].Minute*60+timeline[j].Second
+	})
+
+	return timeline, nil
+}
+
+func (s *MatchService) GetWards(getMatch *dtos.GetMatch) ([]models.Ward, error) {
+	matchParse, err := s.GetMatch(getMatch)
+	if err != nil {
+		return nil, err
+	}
+	if !matchParse.MatchParsed {
+		return nil, UserFacingError{Code: fiber.StatusNotFound, Message: "Match is not parsed yet"}
+	}
+
+	return matchParse.Match.Wards, nil
+}
+
+// GetWardHeatmap counts wards placed by a player across all parsed matches in square cells of the map
+func (s *MatchService) GetWardHeatmap(getWardHeatmap *dtos.GetWardHeatmap) (dtos.WardHeatmap, error) {
+	err := validator.ValidateStruct(getWardHeatmap)
+	if err != nil {
+		return dtos.WardHeatmap{}, ValidateError{err}
+	}
+
+	wards, err := s.MatchServiceMatchRepository.GetPlayerWards(getWardHeatmap.UserSteamID, getWardHeatmap.Type)
+	if err != nil {
+		return dtos.WardHeatmap{}, RepositoryError{err}
+	}
+
+	type cellKey struct{ x, y int }
+	cellSize := float64(getWardHeatmap.CellSize)
+	counts := make(map[cellKey]int)
+	matches := make(map[int]bool)
+	for _, ward := range wards {
+		key := cellKey{x: int(math.Floor(float64(ward.X) / cellSize)), y: int(math.Floor(float64(ward.Y) / cellSize))}
+		counts[key]++
+		matches[ward.MatchID] = true
+	}
+
+	heatmap := dtos.WardHeatmap{
+		UserSteamID: getWardHeatmap.UserSteamID,
+		Type:        getWardHeatmap.Type,
+		CellSize:    getWardHeatmap.CellSize,
+		Matches:     len(matches),
+		Wards:       len(wards),
+		Cells:       make([]dtos.HeatmapCell, 0, len(counts)),
+	}
+	for key, count := range counts {
+		heatmap.Cells = append(heatmap.Cells, dtos.HeatmapCell{
+			X:     float32(float64(key.x) * cellSize),
+			Y:     float32(float64(key.y) * cellSize),
+			Count: count,
+		})
+	}
+	sort.Slice(heatmap.Cells, func(i, j int) bool {
+		if heatmap.Cells[i].Count != heatmap.Cells[j].Count {
+			return heatmap.Cells[i].Count > heatmap.Cells[j].Count
+		}
+		if heatmap.Cells[i].X != heatmap.Cells[j].X {
+			return heatmap.Cells[i].X < heatmap.Cells[j].X
+		}
+		return heatmap.Cells[i].Y < heatmap.Cells[j].Y
+	})
+
+	return heatmap, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+)
+
+type fakeMatchRepository struct {
+	wards []models.Ward
+}
+
+func (r *fakeMatchRepository) GetMatch(matchID int) (models.Match, error) {
+	return models.Match{MatchID: matchID}, nil
+}
+
+func (r *fakeMatchRepository) MatchExists(int) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeMatchRepository) CreateMatch(*models.Match, []models.Glyph) error {
+	return nil
+}
+
+func (r *fakeMatchRepository) GetPlayerWards(string, string) ([]models.Ward, error) {
+	return r.wards, nil
+}
+
+func TestGetWardHeatmapGroupsWardsIntoCells(t *testing.T) {
+	repository := &fakeMatchRepository{wards: []models.Ward{
+		{MatchID: 1, X: 10, Y: 10},
+		{MatchID: 1, X: 500, Y: 20},
+		{MatchID: 2, X: -10, Y: 10},
+	}}
+	service := NewMatchService(repository, nil)
+
+	heatmap, err := service.GetWardHeatmap(&dtos.GetWardHeatmap{UserSteamID: "76561198000000000", CellSize: 512})
+	if err != nil {
+		t.Fatalf("GetWardHeatmap returned error: %v", err)
+	}
+
+	if heatmap.Matches != 2 || heatmap.Wards != 3 {
+		t.Fatalf("expected 2 matches and 3 wards, got %d and %d", heatmap.Matches, heatmap.Wards)
+	}
+	expected := []dtos.HeatmapCell{{X: 0, Y: 0, Count: 2}, {X: -512, Y: 0, Count: 1}}
+	if len(heatmap.Cells) != len(expected) {
+		t.Fatalf("expected %d cells, got %+v", len(expected), heatmap.Cells)
+	}
+	for i := range expected {
+		if heatmap.Cells[i] != expected[i] {
+			t.Errorf("cell %d: expected %+v, got %+v", i, expected[i], heatmap.Cells[i])
+		}
+	}
+}
+
+func TestGetWardHeatmapRejectsUnknownWardType(t *testing.T) {
+	service := NewMatchService(&fakeMatchRepository{}, nil)
+
+	_, err := service.GetWardHeatmap(&dtos.GetWardHeatmap{UserSteamID: "76561198000000000", Type: "dispenser", CellSize: 512})
+	if _, ok := err.(ValidateError); !ok {
+		t.Fatalf("expected ValidateError, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/metrics"
+	"go-glyph/internal/core/models"
+	"go-glyph/internal/core/tracing"
+)
+
+type ParseServiceMatchService interface {
+	CreateMatch(createMatch *dtos.CreateMatch) error
+}
+
+// ParseService tracks the matches being parsed and saves them once parsed. It is shared by the
+// glyph lookup and the replay upload, so a match is only parsed once at a time whichever way it comes in.
+type ParseService struct {
+	ParseServiceMatchService ParseServiceMatchService
+
+	activeMatches sync.Map
+}
+
+func NewParseService(parseServiceMatchService ParseServiceMatchService) *ParseService {
+	return &ParseService{
+		ParseServiceMatchService: parseServiceMatchService,
+	}
+}
+
+// Start marks the match as being parsed, false if it already is. Finish must follow a successful Start.
+func (s *ParseService) Start(matchID int) bool {
+	if _, loaded := s.activeMatches.LoadOrStore(matchID, true); loaded {
+		return false
+	}
+	metrics.MatchesInProgress.Inc()
+	return true
+}
+
+// Finish marks the match as no longer being parsed
+func (s *ParseService) Finish(matchID int) {
+	metrics.MatchesInProgress.Dec()
+	s.activeMatches.Delete(matchID)
+}
+
+// Save saves the parsed glyphs together with the lineup and draft of the match, a match without glyphs is saved too
+func (s *ParseService) Save(ctx context.Context, glyphs []models.Glyph, match models.Match) (err error) {
+	_, span := tracing.Start(ctx, "glyphs.Save")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	createMatch := dtos.CreateMatch{Match: match, Glyphs: glyphs}
+	return s.ParseServiceMatchService.CreateMatch(&createMatch)
+}
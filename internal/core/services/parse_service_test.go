@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+)
+
+type recordingMatchService struct {
+	created []dtos.CreateMatch
+}
+
+func (s *recordingMatchService) CreateMatch(createMatch *dtos.CreateMatch) error {
+	s.created = append(s.created, *createMatch)
+	return nil
+}
+
+func TestParseServiceParsesMatchOnce(t *testing.T) {
+	service := NewParseService(&recordingMatchService{})
+
+	if !service.Start(7000000001) {
+		t.Fatal("expected the first parse to start")
+	}
+	if service.Start(7000000001) {
+		t.Fatal("expected a second parse of the same match to be refused")
+	}
+	if !service.Start(7000000002) {
+		t.Fatal("expected the parse of another match to start")
+	}
+
+	service.Finish(7000000001)
+	if !service.Start(7000000001) {
+		t.Fatal("expected the match to be parsed again once finished")
+	}
+}
+
+func TestParseServiceSavesGlyphsWithMatch(t *testing.T) {
+	matchService := &recordingMatchService{}
+	service := NewParseService(matchService)
+
+	glyphs := []models.Glyph{{MatchID: 7000000001, Minute: 12}}
+	if err := service.Save(context.Background(), glyphs, models.Match{MatchID: 7000000001}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if err := service.Save(context.Background(), []models.Glyph{}, models.Match{MatchID: 7000000002}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	if len(matchService.created) != 2 {
+		t.Fatalf("expected 2 saved matches, got %d", len(matchService.created))
+	}
+	if saved := matchService.created[0]; saved.Match.MatchID != 7000000001 || len(saved.Glyphs) != 1 {
+		t.Fatalf("expected the match to be saved with its glyph, got %+v", saved)
+	}
+	if saved := matchService.created[1]; saved.Match.MatchID != 7000000002 || len(saved.Glyphs) != 0 {
+		t.Fatalf("expected the match without glyphs to be saved, got %+v", saved)
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// contextReader fails reads once ctx is done, so copies and parses of a canceled request stop at the next read
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func newContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// timedReader adds the time spent in reads of r to elapsed and the bytes read to n
+type timedReader struct {
+	r       io.Reader
+	elapsed time.Duration
+	n       int64
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.r.Read(p)
+	r.elapsed += time.Since(start)
+	r.n += int64(n)
+	return n, err
+}
+
+// sleepContext waits for d, returning early with the error of ctx once it is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-glyph/internal/core/dtos"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDownloadRetries      = 5
+	defaultDownloadBaseDelay    = time.Second
+	defaultDownloadMaxDelay     = 30 * time.Second
+	defaultDownloadStallTimeout = 30 * time.Second
+	defaultTargetConcurrency    = 4
+	downloadConnectTimeout      = 10 * time.Second
+)
+
+// DownloadConfig configures how replays are downloaded over HTTP, zero values use the defaults
+type DownloadConfig struct {
+	// Retries is the number of consecutive failed attempts allowed after the first one
+	Retries int
+	// BaseDelay is the delay before the first retry, it doubles on every consecutive failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// StallTimeout aborts an attempt when no byte is received for this long
+	StallTimeout time.Duration
+	// BandwidthLimit caps the bytes per second of all downloads together, unlimited if 0
+	BandwidthLimit int64
+	// TargetConcurrency caps concurrent downloads from one Valve cluster or the mirror, unlimited if negative
+	TargetConcurrency int
+	// Logger of interrupted and failed downloads, slog.Default() if nil
+	Logger *slog.Logger
+}
+
+// Downloader downloads replays, resuming interrupted transfers with HTTP Range requests.
+// Downloads share a bandwidth limit and are limited per target, so no replay cluster is flooded.
+type Downloader struct {
+	client       *http.Client
+	retries      int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	stallTimeout time.Duration
+	limiter      *bandwidthLimiter
+	targets      *downloadTargets
+	logger       *slog.Logger
+}
+
+func NewDownloader(config DownloadConfig) *Downloader {
+	d := &Downloader{
+		retries:      config.Retries,
+		baseDelay:    config.BaseDelay,
+		maxDelay:     config.MaxDelay,
+		stallTimeout: config.StallTimeout,
+		limiter:      newBandwidthLimiter(config.BandwidthLimit),
+		logger:       config.Logger,
+	}
+	switch {
+	case config.TargetConcurrency == 0:
+		d.targets = newDownloadTargets(defaultTargetConcurrency)
+	case config.TargetConcurrency < 0:
+		d.targets = newDownloadTargets(0)
+	default:
+		d.targets = newDownloadTargets(config.TargetConcurrency)
+	}
+	if d.retries <= 0 {
+		d.retries = defaultDownloadRetries
+	}
+	if d.baseDelay <= 0 {
+		d.baseDelay = defaultDownloadBaseDelay
+	}
+	if d.maxDelay <= 0 {
+		d.maxDelay = defaultDownloadMaxDelay
+	}
+	if d.stallTimeout <= 0 {
+		d.stallTimeout = defaultDownloadStallTimeout
+	}
+	if d.logger == nil {
+		d.logger = slog.Default()
+	}
+
+	// No overall client timeout, replays are big and a slow but steady download is fine.
+	// Stalled transfers are aborted by the body instead.
+	d.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: downloadConnectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   downloadConnectTimeout,
+			ResponseHeaderTimeout: d.stallTimeout,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+	return d
+}
+
+// Open starts the download of url from target, waiting for a free slot of the target.
+// Reading the body resumes the transfer when it is interrupted, closing it frees the slot.
+// The download is aborted once ctx is done.
+func (d *Downloader) Open(ctx context.Context, target string, url string) (io.ReadCloser, error) {
+	t := d.targets.get(target)
+	if err := t.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	body := &resumableBody{ctx: ctx, downloader: d, target: t, url: url, total: -1, endAt: -1}
+	if err := body.connect(); err != nil {
+		t.release()
+		return nil, err
+	}
+	return body, nil
+}
+
+// Stats returns the downloads of every target used so far
+func (d *Downloader) Stats() []dtos.DownloadStats {
+	return d.targets.stats()
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (d *Downloader) backoff(retry int) time.Duration {
+	delay := d.baseDelay
+	for i := 1; i < retry && delay < d.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxDelay)
+}
+
+// resumableBody reads a download, reconnecting from the last received byte on failure
+type resumableBody struct {
+	ctx        context.Context
+	downloader *Downloader
+	target     *downloadTarget
+	url        string
+	closed     bool
+
+	body   io.ReadCloser
+	cancel context.CancelFunc
+	stall  *time.Timer
+
+	// offset is the number of bytes received, total the size of the file (-1 if unknown)
+	offset int64
+	total  int64
+	// endAt is the offset a response of unknown size ended at (-1 if none), the end is only trusted once another
+	// request ends there as well
+	endAt int64
+	// failures counts consecutive attempts without progress, every failed attempt is counted once by retry
+	failures int
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	for {
+		if b.body == nil {
+			if err := b.connect(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := b.body.Read(p[:b.downloader.limiter.maxRead(len(p))])
+		b.offset += int64(n)
+		if n > 0 {
+			b.failures = 0
+			b.target.add(n, time.Now())
+			// Waiting for the bandwidth limit is not a stall
+			b.stall.Stop()
+			if waitErr := b.downloader.limiter.wait(b.ctx, n); waitErr != nil {
+				b.disconnect()
+				return n, waitErr
+			}
+			b.stall.Reset(b.downloader.stallTimeout)
+		}
+
+		// A canceled download is not resumed
+		if ctxErr := b.ctx.Err(); ctxErr != nil && err != nil {
+			b.disconnect()
+			return n, ctxErr
+		}
+
+		switch {
+		case err == nil:
+			return n, nil
+		case err == io.EOF && b.total >= 0 && b.offset >= b.total:
+			return n, io.EOF
+		case err == io.EOF && b.total < 0 && b.endAt == b.offset:
+			return n, io.EOF
+		case err == io.EOF && b.total < 0:
+			// The connection may have been closed early, the end is confirmed by asking for the bytes after it.
+			// This is not a failure, a server that keeps ending at the same offset confirms it.
+			b.endAt = b.offset
+			b.disconnect()
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		case err == io.EOF:
+			err = io.ErrUnexpectedEOF
+		}
+
+		// The transfer was interrupted, the next read resumes it
+		b.downloader.logger.WarnContext(b.ctx, "Download interrupted", "url", b.url, "offset", b.offset, "error", err)
+		b.disconnect()
+		if n > 0 {
+			return n, nil
+		}
+		if err := b.retry(err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (b *resumableBody) Close() error {
+	b.disconnect()
+	if !b.closed {
+		b.closed = true
+		b.target.release()
+	}
+	return nil
+}
+
+// connect requests the rest of the file, retrying with exponential backoff on transient failures
+func (b *resumableBody) connect() error {
+	for {
+		if b.failures > 0 {
+			if err := sleepContext(b.ctx, b.downloader.backoff(b.failures)); err != nil {
+				return err
+			}
+		}
+
+		err := b.request()
+		if err == nil {
+			return nil
+		}
+		if ctxErr := b.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if !isTransientDownloadError(err) {
+			return err
+		}
+		if err := b.retry(err); err != nil {
+			return err
+		}
+		b.downloader.logger.WarnContext(b.ctx, "Download failed", "url", b.url,
+			"attempt", b.failures, "attempts", b.downloader.retries+1, "error", err)
+	}
+}
+
+// retry counts a failed attempt, err is returned once no retries are left
+func (b *resumableBody) retry(err error) error {
+	b.failures++
+	if b.failures > b.downloader.retries {
+		return err
+	}
+	return nil
+}
+
+// request sends a single request for the bytes after offset
+func (b *resumableBody) request() error {
+	ctx, cancel := context.WithCancel(b.ctx)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		cancel()
+		return GETError{url: b.url, error: err}
+	}
+	if b.offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	}
+
+	response, err := b.downloader.client.Do(request)
+	if err != nil {
+		cancel()
+		return GETError{url: b.url, error: err}
+	}
+
+	switch {
+	case response.StatusCode == http.StatusPartialContent && b.offset > 0:
+		start, total, ok := parseContentRange(response.Header.Get("Content-Range"))
+		if !ok || start != b.offset {
+			_ = response.Body.Close()
+			cancel()
+			return HTTPError{url: b.url, statusCode: response.StatusCode,
+				response: "unexpected Content-Range " + response.Header.Get("Content-Range")}
+		}
+		b.total = total
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && b.offset > 0:
+		// Nothing is left after offset, the previous response of unknown size was complete
+		_ = response.Body.Close()
+		total, ok := parseUnsatisfiedRange(response.Header.Get("Content-Range"))
+		if !ok || total != b.offset {
+			cancel()
+			return HTTPError{url: b.url, statusCode: response.StatusCode,
+				response: "unexpected Content-Range " + response.Header.Get("Content-Range")}
+		}
+		b.total = total
+		response.Body = http.NoBody
+	case response.StatusCode == http.StatusOK:
+		b.total = response.ContentLength
+		// The server does not support ranges, the received part is skipped
+		if b.offset > 0 {
+			if _, err := io.CopyN(io.Discard, response.Body, b.offset); err != nil {
+				_ = response.Body.Close()
+				cancel()
+				return GETError{url: b.url, error: err}
+			}
+		}
+	default:
+		defer cancel()
+		return b.downloader.responseError(b.ctx, b.url, response)
+	}
+
+	b.body = response.Body
+	b.cancel = cancel
+	b.stall = time.AfterFunc(b.downloader.stallTimeout, cancel)
+	return nil
+}
+
+func (b *resumableBody) disconnect() {
+	if b.body == nil {
+		return
+	}
+	b.stall.Stop()
+	b.cancel()
+	_ = b.body.Close()
+	b.body = nil
+}
+
+// responseError reads the failed response and tells whether the replay is missing or the server failed
+func (d *Downloader) responseError(ctx context.Context, url string, response *http.Response) error {
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if err != nil {
+		d.logger.WarnContext(ctx, "Cannot read HTTP error response", "url", url, "status", response.StatusCode, "error", err)
+		return ReadResponseBodyError{err}
+	}
+
+	bodyStr := string(body)
+	if strings.Contains(bodyStr, "Error: 2010") {
+		d.logger.InfoContext(ctx, "Replay expired", "url", url, "status", response.StatusCode, "body", bodyStr)
+		return ReplayExpiredError{}
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return ReplayNotFoundError{location: url}
+	}
+
+	return HTTPError{url: url, statusCode: response.StatusCode, response: bodyStr}
+}
+
+// isTransientDownloadError tells whether a failed attempt is worth retrying
+func isTransientDownloadError(err error) bool {
+	switch e := err.(type) {
+	case GETError, ReadResponseBodyError:
+		return true
+	case HTTPError:
+		return e.statusCode >= 500 || e.statusCode == http.StatusTooManyRequests || e.statusCode == http.StatusRequestTimeout
+	default:
+		return false
+	}
+}
+
+// parseContentRange parses "bytes <start>-<end>/<total>", total is -1 if unknown
+func parseContentRange(value string) (start int64, total int64, ok bool) {
+	value, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	byteRange, size, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if size == "*" {
+		return start, -1, true
+	}
+	total, err = strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// parseUnsatisfiedRange parses "bytes */<total>" of a 416 response
+func parseUnsatisfiedRange(value string) (total int64, ok bool) {
+	size, found := strings.CutPrefix(value, "bytes */")
+	if !found {
+		return 0, false
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return total, true
+}
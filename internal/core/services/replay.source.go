@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go-glyph/internal/core/dtos"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ValveReplaySourceName  = "valve"
+	MirrorReplaySourceName = "mirror"
+	LocalReplaySourceName  = "local"
+	CacheReplaySourceName  = "cache"
+)
+
+// defaultReplaySourceOrder is used when no order is configured, sources that are not configured are skipped
+var defaultReplaySourceOrder = []string{CacheReplaySourceName, LocalReplaySourceName, ValveReplaySourceName, MirrorReplaySourceName}
+
+// ReplaySource is a place replays can be retrieved from
+type ReplaySource interface {
+	Name() string
+	// Open returns the replay of the match, either a plain demo or bzip2 compressed.
+	// Downloads are aborted once ctx is done.
+	Open(ctx context.Context, match dtos.Match) (io.ReadCloser, error)
+}
+
+// ReplaySourcesConfig configures the replay sources, empty values disable a source
+type ReplaySourcesConfig struct {
+	// Order of the sources to try, defaultReplaySourceOrder if empty
+	Order []string
+	// MirrorURL is a template with {match_id}, {cluster} and {salt} placeholders
+	MirrorURL string
+	LocalDir  string
+	CacheDir  string
+	// CacheMaxBytes caps the size of the cache, the least recently used replays are evicted beyond it (no limit if 0)
+	CacheMaxBytes int64
+	// Downloader is used by the valve and mirror sources
+	Downloader *Downloader
+}
+
+// NewReplaySources creates the configured sources in order and the cache downloaded replays are stored in (nil if disabled)
+func NewReplaySources(config ReplaySourcesConfig) ([]ReplaySource, *CacheReplaySource, error) {
+	downloader := config.Downloader
+	if downloader == nil {
+		downloader = NewDownloader(DownloadConfig{})
+	}
+
+	var cache *CacheReplaySource
+	if config.CacheDir != "" {
+		cache = NewCacheReplaySource(config.CacheDir, config.CacheMaxBytes)
+	}
+
+	available := map[string]ReplaySource{ValveReplaySourceName: NewValveReplaySource(downloader)}
+	if config.MirrorURL != "" {
+		available[MirrorReplaySourceName] = NewMirrorReplaySource(downloader, config.MirrorURL)
+	}
+	if config.LocalDir != "" {
+		available[LocalReplaySourceName] = NewLocalReplaySource(config.LocalDir)
+	}
+	if cache != nil {
+		available[CacheReplaySourceName] = cache
+	}
+
+	order := config.Order
+	explicit := len(order) > 0
+	if !explicit {
+		order = defaultReplaySourceOrder
+	}
+
+	var sources []ReplaySource
+	seen := make(map[string]bool)
+	for _, name := range order {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		source, ok := available[name]
+		if ok {
+			sources = append(sources, source)
+			continue
+		}
+		if explicit {
+			switch name {
+			case MirrorReplaySourceName, LocalReplaySourceName, CacheReplaySourceName:
+				return nil, nil, fmt.Errorf("replay source %q is not configured", name)
+			default:
+				return nil, nil, fmt.Errorf("unknown replay source %q", name)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("no replay sources configured")
+	}
+
+	return sources, cache, nil
+}
+
+// ValveReplaySource downloads replays from the replay clusters of Valve
+type ValveReplaySource struct {
+	downloader *Downloader
+}
+
+func NewValveReplaySource(downloader *Downloader) *ValveReplaySource {
+	return &ValveReplaySource{downloader: downloader}
+}
+
+func (s *ValveReplaySource) Name() string {
+	return ValveReplaySourceName
+}
+
+func (s *ValveReplaySource) Open(ctx context.Context, match dtos.Match) (io.ReadCloser, error) {
+	if match.Cluster == 0 {
+		return nil, UserFacingError{Code: fiber.StatusNotFound, Message: "Match id is invalid"}
+	}
+
+	url := fmt.Sprintf(baseReplayURL, match.Cluster, match.ID, match.ReplaySalt)
+	return s.downloader.Open(ctx, fmt.Sprintf("replay%d", match.Cluster), url)
+}
+
+// MirrorReplaySource downloads replays from an archive served over HTTP
+type MirrorReplaySource struct {
+	downloader  *Downloader
+	urlTemplate string
+}
+
+func NewMirrorReplaySource(downloader *Downloader, urlTemplate string) *MirrorReplaySource {
+	return &MirrorReplaySource{downloader: downloader, urlTemplate: urlTemplate}
+}
+
+func (s *MirrorReplaySource) Name() string {
+	return MirrorReplaySourceName
+}
+
+func (s *MirrorReplaySource) Open(ctx context.Context, match dtos.Match) (io.ReadCloser, error) {
+	url := strings.NewReplacer(
+		"{match_id}", strconv.Itoa(match.ID),
+		"{cluster}", strconv.Itoa(match.Cluster),
+		"{salt}", strconv.Itoa(match.ReplaySalt),
+	).Replace(s.urlTemplate)
+	return s.downloader.Open(ctx, MirrorReplaySourceName, url)
+}
+
+// LocalReplaySource reads replays from a directory, named <match id>.dem, <match id>.dem.bz2 or <match id>_<salt>.dem.bz2
+type LocalReplaySource struct {
+	dir string
+}
+
+func NewLocalReplaySource(dir string) *LocalReplaySource {
+	return &LocalReplaySource{dir: dir}
+}
+
+func (s *LocalReplaySource) Name() string {
+	return LocalReplaySourceName
+}
+
+func (s *LocalReplaySource) Open(ctx context.Context, match dtos.Match) (io.ReadCloser, error) {
+	return openReplayFile(s.dir,
+		fmt.Sprintf("%d.dem", match.ID),
+		fmt.Sprintf("%d.dem.bz2", match.ID),
+		fmt.Sprintf("%d_%d.dem.bz2", match.ID, match.ReplaySalt),
+	)
+}
+
+// CacheReplaySource keeps the replays downloaded from other sources, as they were downloaded.
+// The modification time of a cached replay is the time it was last used.
+type CacheReplaySource struct {
+	dir      string
+	maxBytes int64
+	// evictMu keeps concurrent evictions from removing more replays than needed
+	evictMu sync.Mutex
+}
+
+// NewCacheReplaySource creates a cache evicting the least recently used replays once it holds more than maxBytes,
+// it grows without limit if maxBytes is 0
+func NewCacheReplaySource(dir string, maxBytes int64) *CacheReplaySource {
+	return &CacheReplaySource{dir: dir, maxBytes: maxBytes}
+}
+
+func (s *CacheReplaySource) Name() string {
+	return CacheReplaySourceName
+}
+
+func (s *CacheReplaySource) Open(ctx context.Context, match dtos.Match) (io.ReadCloser, error) {
+	replay, err := openReplayFile(s.dir, fmt.Sprintf("%d.dem.bz2", match.ID), fmt.Sprintf("%d.dem", match.ID))
+	if err != nil {
+		return nil, err
+	}
+	// Marks the replay as used, a replay that cannot be marked is only evicted sooner
+	if file, ok := replay.(*os.File); ok {
+		now := time.Now()
+		_ = os.Chtimes(file.Name(), now, now)
+	}
+	return replay, nil
+}
+
+// Create returns a file the replay of the match is written to, it is added to the cache by commit
+func (s *CacheReplaySource) Create(match dtos.Match, compressed bool) (file *os.File, commit func() error, err error) {
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return nil, nil, FolderCreationError{foldername: s.dir, error: err}
+	}
+
+	filename := filepath.Join(s.dir, fmt.Sprintf("%d.dem", match.ID))
+	if compressed {
+		filename += ".bz2"
+	}
+
+	// Written to a temporary file first, so a failed download never ends up in the cache
+	file, err = os.CreateTemp(s.dir, fmt.Sprintf("%d-*.part", match.ID))
+	if err != nil {
+		return nil, nil, FileCreationError{filename: filename, error: err}
+	}
+
+	commit = func() error {
+		if err := os.Rename(file.Name(), filename); err != nil {
+			return FileCreationError{filename: filename, error: err}
+		}
+		return nil
+	}
+	return file, commit, nil
+}
+
+// Evict removes the least recently used replays until the cache holds at most maxBytes.
+// The most recently used replay is kept even if it is larger on its own.
+func (s *CacheReplaySource) Evict() error {
+	if s.maxBytes <= 0 {
+		return nil
+	}
+	s.evictMu.Lock()
+	defer s.evictMu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return OpenFileError{filename: s.dir, error: err}
+	}
+
+	var replays []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		// Replays still being written end with .part
+		name := entry.Name()
+		if !entry.Type().IsRegular() || (!strings.HasSuffix(name, ".dem") && !strings.HasSuffix(name, ".dem.bz2")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+		replays = append(replays, info)
+		total += info.Size()
+	}
+	sort.Slice(replays, func(i, j int) bool {
+		return replays[i].ModTime().Before(replays[j].ModTime())
+	})
+
+	var errs []error
+	for i := 0; total > s.maxBytes && i < len(replays)-1; i++ {
+		filename := filepath.Join(s.dir, replays[i].Name())
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, RemoveFileError{filename: filename, error: err})
+			continue
+		}
+		total -= replays[i].Size()
+	}
+	return errors.Join(errs...)
+}
+
+// openReplayFile opens the first existing file of the given names in dir
+func openReplayFile(dir string, names ...string) (io.ReadCloser, error) {
+	for _, name := range names {
+		filename := filepath.Join(dir, name)
+		file, err := os.Open(filename)
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, OpenFileError{filename: filename, error: err}
+		}
+	}
+	return nil, ReplayNotFoundError{location: dir}
+}
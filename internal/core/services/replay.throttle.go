@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/metrics"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// throughputWindow is the period current throughput is averaged over
+const throughputWindow = 10
+
+// bandwidthLimiter is a token bucket shared by all downloads, nil means unlimited
+type bandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	// A tenth of a second worth of data can be read at once, so readers are not paused for long
+	burst := max(float64(bytesPerSecond)/10, 1)
+	return &bandwidthLimiter{rate: float64(bytesPerSecond), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// maxRead is the size reads are split into so a single read does not exceed the burst
+func (l *bandwidthLimiter) maxRead(n int) int {
+	if l == nil {
+		return n
+	}
+	return min(n, int(l.burst))
+}
+
+// wait blocks until n bytes may be read or ctx is done. Tokens go negative while readers wait,
+// so waiting readers are served in order.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit > 0 {
+		return sleepContext(ctx, time.Duration(deficit/l.rate*float64(time.Second)))
+	}
+	return nil
+}
+
+// downloadTarget limits concurrent downloads from one target and measures their throughput
+type downloadTarget struct {
+	slots chan struct{}
+	bytes prometheus.Counter
+
+	mu      sync.Mutex
+	active  int
+	total   int64
+	buckets [throughputWindow]int64
+	seconds [throughputWindow]int64
+}
+
+// acquire waits for a free slot of the target, failing once ctx is done
+func (t *downloadTarget) acquire(ctx context.Context) error {
+	if t.slots != nil {
+		metrics.DownloadsWaiting.Inc()
+		select {
+		case t.slots <- struct{}{}:
+			metrics.DownloadsWaiting.Dec()
+		case <-ctx.Done():
+			metrics.DownloadsWaiting.Dec()
+			return ctx.Err()
+		}
+	}
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *downloadTarget) release() {
+	t.mu.Lock()
+	t.active--
+	t.mu.Unlock()
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+// add counts n bytes received in the current second
+func (t *downloadTarget) add(n int, now time.Time) {
+	second := now.Unix()
+	i := second % throughputWindow
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seconds[i] != second {
+		t.seconds[i] = second
+		t.buckets[i] = 0
+	}
+	t.buckets[i] += int64(n)
+	t.total += int64(n)
+	t.bytes.Add(float64(n))
+}
+
+func (t *downloadTarget) stats(name string, now time.Time) dtos.DownloadStats {
+	second := now.Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var recent int64
+	for i, bucketSecond := range t.seconds {
+		if second-bucketSecond < throughputWindow {
+			recent += t.buckets[i]
+		}
+	}
+	return dtos.DownloadStats{
+		Target:         name,
+		Active:         t.active,
+		Bytes:          t.total,
+		BytesPerSecond: float64(recent) / throughputWindow,
+	}
+}
+
+// downloadTargets holds a downloadTarget per target name, created on first use
+type downloadTargets struct {
+	limit int
+
+	mu      sync.Mutex
+	targets map[string]*downloadTarget
+}
+
+func newDownloadTargets(limit int) *downloadTargets {
+	return &downloadTargets{limit: limit, targets: make(map[string]*downloadTarget)}
+}
+
+func (d *downloadTargets) get(name string) *downloadTarget {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	target, ok := d.targets[name]
+	if !ok {
+		target = &downloadTarget{bytes: metrics.ReplayBytes.WithLabelValues(name)}
+		if d.limit > 0 {
+			target.slots = make(chan struct{}, d.limit)
+		}
+		d.targets[name] = target
+	}
+	return target
+}
+
+func (d *downloadTargets) stats() []dtos.DownloadStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]dtos.DownloadStats, 0, len(d.targets))
+	for name, target := range d.targets {
+		stats = append(stats, target.stats(name, now))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
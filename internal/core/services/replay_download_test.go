@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testDownloadConfig = DownloadConfig{Retries: 3, BaseDelay: time.Millisecond, StallTimeout: 200 * time.Millisecond}
+
+func testPayload() []byte {
+	payload := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(payload)
+	return payload
+}
+
+// interruptedServer serves payload with Range support, the first response is cut after half of the payload by fail
+func interruptedServer(t *testing.T, payload []byte, fail func(w http.ResponseWriter)) (*httptest.Server, *[]string) {
+	var requests atomic.Int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			_, _ = w.Write(payload[:len(payload)/2])
+			w.(http.Flusher).Flush()
+			fail(w)
+			return
+		}
+		http.ServeContent(w, r, "replay.dem.bz2", time.Time{}, bytes.NewReader(payload))
+	}))
+	t.Cleanup(server.Close)
+	return server, &ranges
+}
+
+func TestDownloaderResumesDroppedConnection(t *testing.T) {
+	payload := testPayload()
+	server, ranges := interruptedServer(t, payload, func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	})
+
+	assertDownload(t, NewDownloader(testDownloadConfig), server.URL, payload)
+	if len(*ranges) != 2 || (*ranges)[1] != "bytes="+strconv.Itoa(len(payload)/2)+"-" {
+		t.Fatalf("expected the second request to resume from the middle, got ranges %q", *ranges)
+	}
+}
+
+func TestDownloaderResumesStalledTransfer(t *testing.T) {
+	payload := testPayload()
+	server, ranges := interruptedServer(t, payload, func(w http.ResponseWriter) {
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	assertDownload(t, NewDownloader(testDownloadConfig), server.URL, payload)
+	if len(*ranges) != 2 {
+		t.Fatalf("expected the stalled transfer to be resumed, got ranges %q", *ranges)
+	}
+}
+
+func TestDownloaderRetriesServerErrors(t *testing.T) {
+	payload := testPayload()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing.dem.bz2":
+			requests.Add(1)
+			http.NotFound(w, r)
+		case requests.Add(1) <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			_, _ = w.Write(payload)
+		}
+	}))
+	defer server.Close()
+
+	d := NewDownloader(testDownloadConfig)
+	assertDownload(t, d, server.URL+"/replay.dem.bz2", payload)
+	if requests.Load() != 3 {
+		t.Fatalf("expected 2 retries, got %d requests", requests.Load())
+	}
+
+	requests.Store(0)
+	if _, err := d.Open(context.Background(), "test", server.URL+"/missing.dem.bz2"); !errors.As(err, &ReplayNotFoundError{}) {
+		t.Fatalf("expected ReplayNotFoundError, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("expected a missing replay not to be retried, got %d requests", requests.Load())
+	}
+}
+
+func TestDownloaderCountsEveryFailedAttemptOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Length", "1024")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	body, err := NewDownloader(testDownloadConfig).Open(context.Background(), "test", server.URL)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer body.Close()
+
+	if _, err := io.ReadAll(body); err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	if got, want := requests.Load(), int32(testDownloadConfig.Retries+1); got != want {
+		t.Fatalf("expected %d attempts, got %d", want, got)
+	}
+}
+
+func TestDownloaderConfirmsTheEndOfUnknownSize(t *testing.T) {
+	payload := testPayload()
+
+	for _, test := range []struct {
+		name string
+		// sent is the part of the payload the first response sends without a Content-Length
+		sent   []byte
+		ranges []string
+	}{
+		{name: "ended early", sent: payload[:len(payload)/2], ranges: []string{"", "bytes=" + strconv.Itoa(len(payload)/2) + "-"}},
+		// The end of a complete response is confirmed by a range that cannot be satisfied
+		{name: "complete", sent: payload, ranges: []string{"", "bytes=" + strconv.Itoa(len(payload)) + "-"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if r.Header.Get("Range") == "" {
+					_, _ = w.Write(test.sent)
+					w.(http.Flusher).Flush()
+					return
+				}
+				http.ServeContent(w, r, "replay.dem.bz2", time.Time{}, bytes.NewReader(payload))
+			}))
+			defer server.Close()
+
+			assertDownload(t, NewDownloader(testDownloadConfig), server.URL, payload)
+			if len(ranges) != len(test.ranges) || ranges[1] != test.ranges[1] {
+				t.Fatalf("expected ranges %q, got %q", test.ranges, ranges)
+			}
+		})
+	}
+}
+
+func TestRetrieveFileRejectsIncompleteBzip2(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	localDir := t.TempDir()
+	truncated := testDemoBz2[:len(testDemoBz2)-8]
+	if err := os.WriteFile(filepath.Join(localDir, "1234.dem.bz2"), truncated, 0o644); err != nil {
+		t.Fatalf("cannot write replay: %v", err)
+	}
+
+	// The standard library and the parallel decompressor
+	for _, workers := range []int{0, 2} {
+		s := NewValveService([]ReplaySource{NewLocalReplaySource(localDir)}, nil, nil, workers, slog.Default())
+		_, err := s.RetrieveFile(context.Background(), testMatch)
+
+		var unavailable ReplayUnavailableError
+		if !errors.As(err, &unavailable) || !errors.As(unavailable.errors[0], &IncompleteReplayError{}) {
+			t.Fatalf("%d workers: expected IncompleteReplayError, got %v", workers, err)
+		}
+	}
+}
+
+func assertDownload(t *testing.T, d *Downloader, url string, payload []byte) {
+	t.Helper()
+
+	body, err := d.Open(context.Background(), "test", url)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer body.Close()
+
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("download returned error: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("expected %d downloaded bytes to match the payload, got %d", len(payload), len(got))
+	}
+}
+
+func TestDownloaderStopsWhenCanceled(t *testing.T) {
+	payload := testPayload()
+	server, ranges := interruptedServer(t, payload, func(w http.ResponseWriter) {
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body, err := NewDownloader(DownloadConfig{Retries: 3, BaseDelay: time.Millisecond, StallTimeout: 5 * time.Second}).
+		Open(ctx, "test", server.URL)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer body.Close()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the download to be canceled, got %v", err)
+	}
+	if len(*ranges) != 1 {
+		t.Fatalf("expected a canceled download not to be resumed, got ranges %q", *ranges)
+	}
+}
+
+func TestRetrieveFileRemovesCanceledDemo(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	localDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(localDir, "1234.dem.bz2"), testDemoBz2, 0o644); err != nil {
+		t.Fatalf("cannot write replay: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewValveService([]ReplaySource{NewLocalReplaySource(localDir)}, nil, nil, 0, slog.Default())
+	if _, err := s.RetrieveFile(ctx, testMatch); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the retrieval to be canceled, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(demosPath, "1234.dem")); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial demo to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-glyph/internal/core/dtos"
+
+	dotaproto "github.com/dotabuff/manta/dota"
+	"google.golang.org/protobuf/proto"
+)
+
+// testDemo is the smallest demo of testMatch, its CDemoFileInfo only holds the match ID
+var testDemo = demoOfMatch(uint64(testMatch.ID))
+
+// testDemoBz2 is testDemo compressed with bzip2
+var testDemoBz2 = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x2e, 0xab, 0x12, 0x84, 0x00, 0x00,
+	0x0a, 0xfe, 0x02, 0x5a, 0xe0, 0x40, 0x00, 0x10, 0x00, 0x10, 0x00, 0x16, 0x02, 0x48, 0x00, 0x10,
+	0x00, 0x20, 0x00, 0x22, 0x9b, 0x50, 0x69, 0x84, 0x6d, 0x42, 0x86, 0x9a, 0x60, 0x01, 0x52, 0xe1,
+	0xb3, 0x86, 0xb2, 0x02, 0x17, 0x84, 0xf7, 0xd5, 0x44, 0xfc, 0x5d, 0xc9, 0x14, 0xe1, 0x42, 0x40,
+	0xba, 0xac, 0x4a, 0x10,
+}
+
+var testMatch = dtos.Match{ID: 1234, Cluster: 0, ReplaySalt: 42}
+
+func TestNewReplaySourcesOrder(t *testing.T) {
+	sources, cache, err := NewReplaySources(ReplaySourcesConfig{CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewReplaySources returned error: %v", err)
+	}
+	if cache == nil || len(sources) != 2 || sources[0].Name() != CacheReplaySourceName || sources[1].Name() != ValveReplaySourceName {
+		t.Fatalf("expected cache then valve by default, got %v", sourceNames(sources))
+	}
+
+	sources, _, err = NewReplaySources(ReplaySourcesConfig{Order: []string{"mirror", "valve"}, MirrorURL: "http://mirror/{match_id}"})
+	if err != nil || len(sources) != 2 || sources[0].Name() != MirrorReplaySourceName {
+		t.Fatalf("expected mirror then valve, got %v (%v)", sourceNames(sources), err)
+	}
+
+	if _, _, err := NewReplaySources(ReplaySourcesConfig{Order: []string{"local"}}); err == nil {
+		t.Fatal("expected error for a source that is not configured")
+	}
+	if _, _, err := NewReplaySources(ReplaySourcesConfig{Order: []string{"ftp"}}); err == nil {
+		t.Fatal("expected error for an unknown source")
+	}
+}
+
+func TestRetrieveFileFallsBackAndCaches(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1234_42.dem.bz2" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(testDemoBz2)
+	}))
+	defer server.Close()
+
+	sources, cache, err := NewReplaySources(ReplaySourcesConfig{
+		Order:     []string{"cache", "local", "mirror"},
+		MirrorURL: server.URL + "/{match_id}_{salt}.dem.bz2",
+		LocalDir:  t.TempDir(),
+		CacheDir:  "cache",
+	})
+	if err != nil {
+		t.Fatalf("NewReplaySources returned error: %v", err)
+	}
+	s := NewValveService(sources, cache, nil, 0, slog.Default())
+
+	filename, err := s.RetrieveFile(context.Background(), testMatch)
+	if err != nil {
+		t.Fatalf("RetrieveFile returned error: %v", err)
+	}
+	assertFileContent(t, filename, testDemo)
+	assertFileContent(t, filepath.Join("cache", "1234.dem.bz2"), string(testDemoBz2))
+
+	// The cached replay is used once the mirror is down
+	server.Close()
+	filename, err = s.RetrieveFile(context.Background(), testMatch)
+	if err != nil {
+		t.Fatalf("RetrieveFile from cache returned error: %v", err)
+	}
+	assertFileContent(t, filename, testDemo)
+}
+
+func TestRetrieveFileReportsEverySource(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sources, cache, err := NewReplaySources(ReplaySourcesConfig{
+		Order:      []string{"local", "valve", "mirror"},
+		MirrorURL:  server.URL + "/{match_id}.dem.bz2",
+		LocalDir:   t.TempDir(),
+		Downloader: NewDownloader(testDownloadConfig),
+	})
+	if err != nil {
+		t.Fatalf("NewReplaySources returned error: %v", err)
+	}
+	s := NewValveService(sources, cache, nil, 0, slog.Default())
+
+	_, err = s.RetrieveFile(context.Background(), testMatch)
+	var unavailable ReplayUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected ReplayUnavailableError, got %v", err)
+	}
+	if len(unavailable.errors) != 3 || unavailable.StatusCode() != http.StatusNotFound {
+		t.Fatalf("expected 3 not found reasons, got %d with status %d: %v", len(unavailable.errors), unavailable.StatusCode(), err)
+	}
+
+	// A mirror that is down is not reported as a missing replay
+	status = http.StatusServiceUnavailable
+	_, err = s.RetrieveFile(context.Background(), testMatch)
+	if !errors.As(err, &unavailable) || unavailable.StatusCode() != http.StatusBadGateway {
+		t.Fatalf("expected ReplayUnavailableError with status 502, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(demosPath, "1234.dem")); !os.IsNotExist(err) {
+		t.Fatalf("expected no demo to be left after failed retrieval, got %v", err)
+	}
+}
+
+func TestRetrieveFileSkipsReplayOfAnotherMatch(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	localDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(localDir, "1234.dem"), []byte(demoOfMatch(4321)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testDemoBz2)
+	}))
+	defer server.Close()
+
+	sources, cache, err := NewReplaySources(ReplaySourcesConfig{
+		Order:     []string{"local", "mirror"},
+		MirrorURL: server.URL + "/{match_id}.dem.bz2",
+		LocalDir:  localDir,
+	})
+	if err != nil {
+		t.Fatalf("NewReplaySources returned error: %v", err)
+	}
+	s := NewValveService(sources, cache, nil, 0, slog.Default())
+
+	filename, err := s.RetrieveFile(context.Background(), testMatch)
+	if err != nil {
+		t.Fatalf("RetrieveFile returned error: %v", err)
+	}
+	assertFileContent(t, filename, testDemo)
+
+	// Without another source the replay of the wrong match is not handed to the parser
+	server.Close()
+	_, err = s.RetrieveFile(context.Background(), testMatch)
+	var unavailable ReplayUnavailableError
+	if !errors.As(err, &unavailable) || len(unavailable.errors) != 2 || !errors.As(unavailable.errors[0], &ReplayMatchMismatchError{}) {
+		t.Fatalf("expected ReplayUnavailableError with the mismatch of the local replay, got %v", err)
+	}
+	if unavailable.StatusCode() != http.StatusBadGateway {
+		t.Fatalf("expected status 502, got %d", unavailable.StatusCode())
+	}
+}
+
+// demoOfMatch returns a demo without packets whose CDemoFileInfo only holds the match ID
+func demoOfMatch(matchID uint64) string {
+	fileInfo, err := proto.Marshal(&dotaproto.CDemoFileInfo{
+		GameInfo: &dotaproto.CGameInfo{Dota: &dotaproto.CGameInfo_CDotaGameInfo{MatchId: proto.Uint64(matchID)}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// The header is followed by the offset of CDemoFileInfo and 4 unused bytes
+	demo := []byte("PBDEMS2\x00")
+	demo = binary.LittleEndian.AppendUint32(demo, uint32(len(demo)+8))
+	demo = append(demo, make([]byte, 4)...)
+	demo = binary.AppendUvarint(demo, uint64(dotaproto.EDemoCommands_DEM_FileInfo))
+	demo = binary.AppendUvarint(demo, 0)
+	demo = binary.AppendUvarint(demo, uint64(len(fileInfo)))
+	return string(append(demo, fileInfo...))
+}
+
+func sourceNames(sources []ReplaySource) []string {
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = source.Name()
+	}
+	return names
+}
+
+func assertFileContent(t *testing.T, filename string, want string) {
+	t.Helper()
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("cannot read %s: %v", filename, err)
+	}
+	if string(got) != want {
+		t.Fatalf("expected %s to contain %q, got %q", filename, want, got)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsedReplays(t *testing.T) {
+	cache := NewCacheReplaySource(t.TempDir(), 2*int64(len(testDemoBz2)))
+	now := time.Now()
+	for i, name := range []string{"1.dem.bz2", "2.dem.bz2", "3.dem.bz2", "4.dem.bz2.part"} {
+		filename := filepath.Join(cache.dir, name)
+		if err := os.WriteFile(filename, testDemoBz2, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modified := now.Add(time.Duration(i-4) * time.Hour)
+		if err := os.Chtimes(filename, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reading the oldest replay makes the second one the least recently used
+	replay, err := cache.Open(context.Background(), dtos.Match{ID: 1})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	_ = replay.Close()
+
+	if err := cache.Evict(); err != nil {
+		t.Fatalf("Evict returned error: %v", err)
+	}
+	for name, kept := range map[string]bool{"1.dem.bz2": true, "2.dem.bz2": false, "3.dem.bz2": true, "4.dem.bz2.part": true} {
+		if _, err := os.Stat(filepath.Join(cache.dir, name)); (err == nil) != kept {
+			t.Errorf("expected %s to be kept %v, got %v", name, kept, err)
+		}
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	cache := NewCacheReplaySource(t.TempDir(), 0)
+	partial, _, err := cache.Create(testMatch, true)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	_ = partial.Close()
+	cached := filepath.Join(cache.dir, "1111.dem.bz2")
+	if err := os.Mkdir(demosPath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range []string{cached, filepath.Join(demosPath, "1234.dem")} {
+		if err := os.WriteFile(filename, []byte(testDemo), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewValveService([]ReplaySource{cache}, cache, nil, 0, slog.Default())
+	if err := s.RemoveTempFiles(); err != nil {
+		t.Fatalf("RemoveTempFiles returned error: %v", err)
+	}
+	for _, filename := range []string{partial.Name(), filepath.Join(demosPath, "1234.dem")} {
+		if _, err := os.Stat(filename); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %v", filename, err)
+		}
+	}
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("expected the cached replay to be kept, got %v", err)
+	}
+}
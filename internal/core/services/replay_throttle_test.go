@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloaderLimitsConcurrentDownloadsPerTarget(t *testing.T) {
+	payload := testPayload()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+
+	config := testDownloadConfig
+	config.TargetConcurrency = 1
+	d := NewDownloader(config)
+
+	first, err := d.Open(context.Background(), "replay111", server.URL)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	// Another cluster is not limited by the first one
+	other, err := d.Open(context.Background(), "replay222", server.URL)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	_ = other.Close()
+
+	opened := make(chan io.ReadCloser)
+	go func() {
+		second, err := d.Open(context.Background(), "replay111", server.URL)
+		if err != nil {
+			t.Errorf("Open returned error: %v", err)
+		}
+		opened <- second
+	}()
+
+	select {
+	case <-opened:
+		t.Fatal("second download from the same cluster started while the first one was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_ = first.Close()
+	select {
+	case second := <-opened:
+		_ = second.Close()
+	case <-time.After(time.Second):
+		t.Fatal("second download did not start after the first one finished")
+	}
+}
+
+func TestDownloaderCapsBandwidthAndReportsThroughput(t *testing.T) {
+	payload := testPayload()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+
+	config := testDownloadConfig
+	config.BandwidthLimit = int64(len(payload)) * 2
+	d := NewDownloader(config)
+
+	startTime := time.Now()
+	assertDownload(t, d, server.URL, payload)
+	// Half a second for the payload at twice its size per second, less the initial burst
+	if elapsed := time.Since(startTime); elapsed < 300*time.Millisecond {
+		t.Fatalf("expected the download to be throttled to about 500ms, took %v", elapsed)
+	}
+
+	stats := d.Stats()
+	if len(stats) != 1 || stats[0].Target != "test" || stats[0].Bytes != int64(len(payload)) || stats[0].Active != 0 {
+		t.Fatalf("expected stats of one finished download, got %+v", stats)
+	}
+	if stats[0].BytesPerSecond != float64(len(payload))/throughputWindow {
+		t.Fatalf("expected throughput averaged over %d seconds, got %v", throughputWindow, stats[0].BytesPerSecond)
+	}
+}
+
+func TestDownloaderDoesNotTreatThrottlingAsStall(t *testing.T) {
+	payload := testPayload()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+
+	// Every read waits a tenth of a second for the bandwidth limit, twice the stall timeout
+	config := testDownloadConfig
+	config.StallTimeout = 50 * time.Millisecond
+	config.BandwidthLimit = int64(len(payload))
+
+	assertDownload(t, NewDownloader(config), server.URL, payload)
+	if requests.Load() != 1 {
+		t.Fatalf("expected a single request, got %d", requests.Load())
+	}
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	dotaproto "github.com/dotabuff/manta/dota"
+	"github.com/sicdex/go-steam-ws"
+	steamproto "github.com/sicdex/go-steam-ws/protocol"
+	steampb "github.com/sicdex/go-steam-ws/protocol/protobuf"
+	"github.com/sicdex/go-steam-ws/protocol/steamlang"
+)
+
+// States of a pooled Steam account
+const (
+	SteamAccountConnecting        = "connecting"
+	SteamAccountAwaitingGuardCode = "awaiting_guard_code"
+	SteamAccountReady             = "ready"
+	SteamAccountQuarantined       = "quarantined"
+)
+
+const (
+	// steamHealthCheckInterval is how often a ready account requests a match to check its GC session
+	steamHealthCheckInterval = 1 * time.Hour
+	steamHealthCheckMatchID  = 239
+
+	// An account that failed is reconnected after a delay doubling with every consecutive failure
+	steamQuarantineBaseDelay = 30 * time.Second
+	steamQuarantineMaxDelay  = 10 * time.Minute
+	// An account is quarantined once this many requests in a row time out, a single timeout may be a slow GC
+	steamQuarantineTimeouts = 3
+)
+
+var (
+	errSteamDisconnected  = errors.New("disconnected from Steam")
+	errSteamPoolClosed    = errors.New("steam account pool is closed")
+	errSteamTokenRejected = errors.New("steam rejected the refresh token")
+)
+
+// steamSession is a Steam client logged in to an account with a Dota GC session
+type steamSession interface {
+	RequestMatchDetails(ctx context.Context, matchID uint64) (*dotaproto.CMsgGCMatchDetailsResponse, error)
+	Disconnect()
+}
+
+// steamConnector logs in to an account, asking guard for Steam Guard codes until ctx is done.
+// onDisconnected is called when the session drops.
+type steamConnector func(ctx context.Context, loginInfo *steam.LogOnDetails, guard steamGuard, onDisconnected func()) (steamSession, error)
+
+// steamTokenStore keeps the refresh tokens of accounts between restarts
+type steamTokenStore interface {
+	RefreshToken(username string) (string, error)
+	SaveRefreshToken(username, token string) error
+	ForgetRefreshToken(username string) error
+}
+
+// dotaSession is a steamSession of a Steam WebSocket client
+type dotaSession struct {
+	steamClient *steam.Client
+	dotaClient  *dotaGCClient
+}
+
+// connectDotaSession returns a connector logging in with the stored refresh token of the account,
+// or with its password if there is none or Steam rejects it. Tokens of password logins are stored.
+func connectDotaSession(tokens steamTokenStore, authenticator *steamAuthenticator, logger *slog.Logger) steamConnector {
+	return func(ctx context.Context, loginInfo *steam.LogOnDetails, guard steamGuard, onDisconnected func()) (steamSession, error) {
+		logger := logger.With(steamAccountLogKey, loginInfo.Username)
+		refreshToken, err := tokens.RefreshToken(loginInfo.Username)
+		if err != nil {
+			logger.Error("Cannot load stored Steam session", "error", err)
+		}
+
+		if refreshToken != "" {
+			sc, dc, err := initDotaClient(loginInfo, refreshToken, onDisconnected, logger)
+			if err == nil {
+				return &dotaSession{steamClient: sc, dotaClient: dc}, nil
+			}
+			if !errors.Is(err, errSteamTokenRejected) {
+				return nil, err
+			}
+			logger.Warn("Stored Steam session was rejected, logging in with the password", "error", err)
+			if err := tokens.ForgetRefreshToken(loginInfo.Username); err != nil {
+				logger.Error("Cannot delete stored Steam session", "error", err)
+			}
+		}
+
+		// The password login waits for Steam Guard before connecting, so it is not bound by the connect timeout
+		refreshToken, err = authenticator.LogOn(ctx, loginInfo.Username, loginInfo.Password, guard)
+		if err != nil {
+			return nil, fmt.Errorf("steam credential authentication failed: %w", err)
+		}
+		if err := tokens.SaveRefreshToken(loginInfo.Username, refreshToken); err != nil {
+			logger.Error("Cannot store Steam session", "error", err)
+		}
+		sc, dc, err := initDotaClient(loginInfo, refreshToken, onDisconnected, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &dotaSession{steamClient: sc, dotaClient: dc}, nil
+	}
+}
+
+func (s *dotaSession) RequestMatchDetails(ctx context.Context, matchID uint64) (*dotaproto.CMsgGCMatchDetailsResponse, error) {
+	return s.dotaClient.RequestMatchDetails(ctx, matchID)
+}
+
+// Disconnect logs off before disconnecting, so Steam does not keep the account in game
+func (s *dotaSession) Disconnect() {
+	s.dotaClient.SetPlaying(false)
+
+	if s.steamClient.Connected() {
+		s.steamClient.Social.SetPersonaState(steamlang.EPersonaState_Offline)
+		s.steamClient.Write(steamproto.NewClientMsgProtobuf(steamlang.EMsg_ClientLogOff, new(steampb.CMsgClientLogOff)))
+		time.Sleep(250 * time.Millisecond)
+	}
+	s.steamClient.Disconnect()
+}
+
+// steamAccount keeps one account of the pool logged in, its state is guarded by the pool lock
+type steamAccount struct {
+	pool         *GoSteamService
+	loginInfo    *steam.LogOnDetails
+	sharedSecret string
+	// logger names the account on every line
+	logger *slog.Logger
+	// ctx is canceled when the account is removed from the pool or the pool is closed
+	ctx       context.Context
+	stop      context.CancelFunc
+	unhealthy chan struct{}
+	// guardCodes passes a submitted email code to a login awaiting it
+	guardCodes chan string
+
+	priority   int
+	state      string
+	session    steamSession
+	generation int
+	inFlight   int
+	requests   int64
+	failures   int
+	// timeouts counts the requests of the session that timed out since the last answer
+	timeouts  int
+	lastError string
+	lastUsed  time.Time
+	since     time.Time
+	retryAt   time.Time
+}
+
+// run logs in and reconnects the account after it is quarantined, until it is stopped
+func (a *steamAccount) run() {
+	for {
+		generation := a.setConnecting()
+		session, err := a.pool.connect(a.ctx, a.loginInfo, a, func() {
+			a.disconnected(generation)
+		})
+		if err != nil {
+			a.logger.Error("Steam account could not log in", "error", err)
+			a.quarantine(generation, err)
+		} else {
+			a.logger.Info("Steam account is ready")
+			a.setReady(generation, session)
+			stopped := a.serve(generation, session)
+			a.clearSession(generation)
+			session.Disconnect()
+			if stopped {
+				return
+			}
+		}
+
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-time.After(a.retryDelay()):
+		}
+	}
+}
+
+// serve health checks the session until it is quarantined, returning true if the account was stopped instead
+func (a *steamAccount) serve(generation int, session steamSession) bool {
+	ticker := time.NewTicker(steamHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return true
+		case <-a.unhealthy:
+			return false
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), steamRequestTimeout)
+			_, err := session.RequestMatchDetails(ctx, steamHealthCheckMatchID)
+			cancel()
+			switch {
+			case err == nil:
+				a.requestAnswered(generation)
+			case errors.Is(err, context.DeadlineExceeded):
+				a.logger.Warn("Steam account health check timed out", "error", err)
+				a.requestTimedOut(generation, fmt.Errorf("health check: %w", err))
+			default:
+				a.logger.Warn("Steam account failed health check", "error", err)
+				a.quarantine(generation, fmt.Errorf("health check: %w", err))
+			}
+		}
+	}
+}
+
+func (a *steamAccount) setConnecting() int {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	a.generation++
+	a.setState(SteamAccountConnecting)
+	return a.generation
+}
+
+func (a *steamAccount) setReady(generation int, session steamSession) {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	if generation != a.generation || a.state != SteamAccountConnecting {
+		// Quarantined while logging in, serve notices the pending quarantine
+		return
+	}
+	a.session = session
+	a.failures = 0
+	a.timeouts = 0
+	a.setState(SteamAccountReady)
+}
+
+// quarantine stops dispatching requests to the session of the given generation and reconnects it later.
+// Errors of sessions that were already replaced are ignored.
+func (a *steamAccount) quarantine(generation int, err error) {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	a.quarantineLocked(generation, err)
+}
+
+func (a *steamAccount) quarantineLocked(generation int, err error) {
+	if generation != a.generation || a.state == SteamAccountQuarantined {
+		return
+	}
+	a.failures++
+	a.lastError = err.Error()
+	a.retryAt = time.Now().Add(a.quarantineDelay())
+	a.setState(SteamAccountQuarantined)
+
+	select {
+	case a.unhealthy <- struct{}{}:
+	default:
+	}
+}
+
+// requestAnswered resets the timeouts of the session of the given generation
+func (a *steamAccount) requestAnswered(generation int) {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	if generation == a.generation {
+		a.timeouts = 0
+	}
+}
+
+// requestTimedOut counts a request of the session of the given generation that timed out and quarantines
+// the session once steamQuarantineTimeouts requests in a row timed out, returning true if it did
+func (a *steamAccount) requestTimedOut(generation int, err error) bool {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	if generation != a.generation || a.state != SteamAccountReady {
+		return false
+	}
+	a.timeouts++
+	if a.timeouts < steamQuarantineTimeouts {
+		return false
+	}
+	a.quarantineLocked(generation, fmt.Errorf("%d requests in a row timed out: %w", a.timeouts, err))
+	return true
+}
+
+// disconnected quarantines a ready session that dropped, failed logins report their own error
+func (a *steamAccount) disconnected(generation int) {
+	a.pool.mu.Lock()
+	ready := generation == a.generation && a.state == SteamAccountReady
+	a.pool.mu.Unlock()
+
+	if ready {
+		a.quarantine(generation, errSteamDisconnected)
+	}
+}
+
+func (a *steamAccount) clearSession(generation int) {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	if generation == a.generation {
+		a.session = nil
+	}
+	// A quarantine signal of this session is not meant for the next one
+	select {
+	case <-a.unhealthy:
+	default:
+	}
+}
+
+func (a *steamAccount) retryDelay() time.Duration {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+	return time.Until(a.retryAt)
+}
+
+// quarantineDelay must be called with the pool lock held
+func (a *steamAccount) quarantineDelay() time.Duration {
+	return backoffDelay(a.pool.quarantineDelay, steamQuarantineMaxDelay, a.failures)
+}
+
+// setState must be called with the pool lock held, it wakes requests waiting for a ready account
+func (a *steamAccount) setState(state string) {
+	a.state = state
+	a.since = time.Now()
+	if state != SteamAccountQuarantined {
+		a.retryAt = time.Time{}
+	}
+	close(a.pool.changed)
+	a.pool.changed = make(chan struct{})
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	steamAuthURL = "https://api.steampowered.com/IAuthenticationService"
+
+	// steamAuthRequestTimeout bounds each request to the authentication API
+	steamAuthRequestTimeout = 15 * time.Second
+	// steamAuthConfirmationTimeout is how long a login waits for a Steam Guard code or confirmation
+	steamAuthConfirmationTimeout = 30 * time.Minute
+)
+
+// Steam Guard confirmations of EAuthSessionGuardType
+const (
+	steamGuardNone               = 1
+	steamGuardEmailCode          = 2
+	steamGuardDeviceCode         = 3
+	steamGuardDeviceConfirmation = 4
+)
+
+// EResults of the authentication API
+const (
+	steamResultOK                    = 1
+	steamResultInvalidPassword       = 5
+	steamResultInvalidLoginAuthCode  = 65
+	steamResultTwoFactorCodeMismatch = 88
+)
+
+var errSteamGuardSecretMissing = errors.New("account requires a mobile authenticator code but has no shared secret")
+
+// steamGuard supplies Steam Guard codes while an account logs in
+type steamGuard interface {
+	// DeviceCode generates a code of the mobile authenticator, false if the account has no shared secret
+	DeviceCode() (string, bool)
+	// EmailCode waits for a code sent to the email of the account
+	EmailCode(ctx context.Context) (string, error)
+}
+
+// SteamAuthError is a request to the authentication API that Steam refused
+type SteamAuthError struct {
+	Method string
+	Result int
+}
+
+func (e SteamAuthError) Error() string {
+	if e.Result == steamResultInvalidPassword {
+		return fmt.Sprintf("steam %s: invalid password", e.Method)
+	}
+	return fmt.Sprintf("steam %s failed with EResult %d", e.Method, e.Result)
+}
+
+// steamAuthenticator logs in with a password through the IAuthenticationService web API,
+// returning a refresh token the Steam client logs on with
+type steamAuthenticator struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newSteamAuthenticator(baseURL string) *steamAuthenticator {
+	return &steamAuthenticator{baseURL: baseURL, client: &http.Client{Timeout: steamAuthRequestTimeout}}
+}
+
+// steamAuthSession is a started login waiting for its confirmations
+type steamAuthSession struct {
+	clientID      uint64
+	requestID     []byte
+	steamID       uint64
+	interval      time.Duration
+	confirmations []int
+}
+
+// LogOn logs in, supplying the Steam Guard codes the account needs, and waits until the login is confirmed
+func (a *steamAuthenticator) LogOn(ctx context.Context, username, password string, guard steamGuard) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, steamAuthConfirmationTimeout)
+	defer cancel()
+
+	encryptedPassword, timestamp, err := a.encryptPassword(ctx, username, password)
+	if err != nil {
+		return "", err
+	}
+	session, err := a.beginAuthSession(ctx, username, encryptedPassword, timestamp)
+	if err != nil {
+		return "", err
+	}
+	if err := a.confirm(ctx, session, guard); err != nil {
+		return "", err
+	}
+	return a.pollAuthSession(ctx, session)
+}
+
+func (a *steamAuthenticator) encryptPassword(ctx context.Context, username, password string) (string, uint64, error) {
+	var request []byte
+	request = appendProtoString(request, 1, username)
+	response, err := a.call(ctx, http.MethodGet, "GetPasswordRSAPublicKey", request)
+	if err != nil {
+		return "", 0, err
+	}
+
+	fields, err := parseProtoFields(response)
+	if err != nil {
+		return "", 0, err
+	}
+	modulus, okModulus := new(big.Int).SetString(string(fields.bytes(1)), 16)
+	exponent, okExponent := new(big.Int).SetString(string(fields.bytes(2)), 16)
+	if !okModulus || !okExponent || !exponent.IsInt64() {
+		return "", 0, errors.New("steam returned an invalid password key")
+	}
+
+	key := &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, key, []byte(password))
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), fields.varint(3), nil
+}
+
+func (a *steamAuthenticator) beginAuthSession(ctx context.Context, username, encryptedPassword string, timestamp uint64) (steamAuthSession, error) {
+	deviceName := "go-glyph"
+	if hostname, err := os.Hostname(); err == nil {
+		deviceName += " " + hostname
+	}
+	// The refresh token is for the Steam client platform, as it logs on to a CM server
+	var deviceDetails []byte
+	deviceDetails = appendProtoString(deviceDetails, 1, deviceName)
+	deviceDetails = appendProtoVarint(deviceDetails, 2, 1)
+
+	var request []byte
+	request = appendProtoString(request, 1, deviceName)
+	request = appendProtoString(request, 2, username)
+	request = appendProtoString(request, 3, encryptedPassword)
+	request = appendProtoVarint(request, 4, timestamp)
+	request = appendProtoVarint(request, 5, 1)
+	request = appendProtoVarint(request, 6, 1)
+	request = appendProtoVarint(request, 7, 1)
+	request = appendProtoString(request, 8, "Client")
+	request = protowire.AppendTag(request, 9, protowire.BytesType)
+	request = protowire.AppendBytes(request, deviceDetails)
+
+	response, err := a.call(ctx, http.MethodPost, "BeginAuthSessionViaCredentials", request)
+	if err != nil {
+		return steamAuthSession{}, err
+	}
+	fields, err := parseProtoFields(response)
+	if err != nil {
+		return steamAuthSession{}, err
+	}
+
+	session := steamAuthSession{
+		clientID:  fields.varint(1),
+		requestID: fields.bytes(2),
+		steamID:   fields.varint(5),
+		interval:  5 * time.Second,
+	}
+	if interval := math.Float32frombits(uint32(fields.varint(3))); interval > 0 {
+		session.interval = time.Duration(float64(interval) * float64(time.Second))
+	}
+	for _, confirmation := range fields.all(4) {
+		confirmationFields, err := parseProtoFields(confirmation.bytes)
+		if err != nil {
+			return steamAuthSession{}, err
+		}
+		session.confirmations = append(session.confirmations, int(confirmationFields.varint(1)))
+	}
+	return session, nil
+}
+
+// confirm submits a Steam Guard code if the login needs one, the mobile authenticator is preferred over email.
+// A login waiting for confirmation in the mobile app is confirmed while polling.
+func (a *steamAuthenticator) confirm(ctx context.Context, session steamAuthSession, guard steamGuard) error {
+	allowed := make(map[int]bool)
+	for _, confirmation := range session.confirmations {
+		allowed[confirmation] = true
+	}
+
+	switch {
+	case len(allowed) == 0 || allowed[steamGuardNone]:
+		return nil
+
+	case allowed[steamGuardDeviceCode]:
+		if code, ok := guard.DeviceCode(); ok {
+			return a.submitGuardCode(ctx, session, code, steamGuardDeviceCode)
+		}
+		if !allowed[steamGuardDeviceConfirmation] && !allowed[steamGuardEmailCode] {
+			return errSteamGuardSecretMissing
+		}
+	}
+
+	if allowed[steamGuardEmailCode] {
+		// Codes are asked for again until one is accepted or the login times out
+		for {
+			code, err := guard.EmailCode(ctx)
+			if err != nil {
+				return err
+			}
+			err = a.submitGuardCode(ctx, session, code, steamGuardEmailCode)
+			var authErr SteamAuthError
+			if !errors.As(err, &authErr) ||
+				(authErr.Result != steamResultInvalidLoginAuthCode && authErr.Result != steamResultTwoFactorCodeMismatch) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *steamAuthenticator) submitGuardCode(ctx context.Context, session steamAuthSession, code string, codeType int) error {
+	var request []byte
+	request = appendProtoVarint(request, 1, session.clientID)
+	request = protowire.AppendTag(request, 2, protowire.Fixed64Type)
+	request = protowire.AppendFixed64(request, session.steamID)
+	request = appendProtoString(request, 3, code)
+	request = appendProtoVarint(request, 4, uint64(codeType))
+
+	_, err := a.call(ctx, http.MethodPost, "UpdateAuthSessionWithSteamGuardCode", request)
+	return err
+}
+
+// pollAuthSession waits for the refresh token, which Steam issues once the login is confirmed
+func (a *steamAuthenticator) pollAuthSession(ctx context.Context, session steamAuthSession) (string, error) {
+	ticker := time.NewTicker(session.interval)
+	defer ticker.Stop()
+
+	for {
+		var request []byte
+		request = appendProtoVarint(request, 1, session.clientID)
+		request = protowire.AppendTag(request, 2, protowire.BytesType)
+		request = protowire.AppendBytes(request, session.requestID)
+
+		response, err := a.call(ctx, http.MethodPost, "PollAuthSessionStatus", request)
+		if err != nil {
+			return "", err
+		}
+		fields, err := parseProtoFields(response)
+		if err != nil {
+			return "", err
+		}
+		if refreshToken := string(fields.bytes(3)); refreshToken != "" {
+			return refreshToken, nil
+		}
+		if newClientID := fields.varint(1); newClientID != 0 {
+			session.clientID = newClientID
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("steam login was not confirmed: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// call sends a protobuf request to a method of the authentication API and returns the protobuf response
+func (a *steamAuthenticator) call(ctx context.Context, method, name string, body []byte) ([]byte, error) {
+	endpoint := a.baseURL + "/" + name + "/v1"
+	values := url.Values{"input_protobuf_encoded": {base64.StdEncoding.EncodeToString(body)}}
+
+	var request *http.Request
+	var err error
+	if method == http.MethodGet {
+		request, err = http.NewRequestWithContext(ctx, method, endpoint+"?"+values.Encode(), nil)
+	} else {
+		request, err = http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBufferString(values.Encode()))
+		if request != nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := a.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("steam %s: %w", name, err)
+	}
+	defer response.Body.Close()
+
+	if result := response.Header.Get("X-Eresult"); result != "" && result != strconv.Itoa(steamResultOK) {
+		code, _ := strconv.Atoi(result)
+		return nil, SteamAuthError{Method: name, Result: code}
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("steam %s: %s", name, response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// protoValue is a field of a protobuf message, varint and fixed values are both held in number
+type protoValue struct {
+	number uint64
+	bytes  []byte
+}
+
+type protoFields map[protowire.Number][]protoValue
+
+// parseProtoFields decodes the fields of a protobuf message without its schema
+func parseProtoFields(b []byte) (protoFields, error) {
+	fields := make(protoFields)
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var value protoValue
+		switch wireType {
+		case protowire.VarintType:
+			value.number, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			value.number = uint64(v)
+		case protowire.Fixed64Type:
+			value.number, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			value.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(number, wireType, b)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		fields[number] = append(fields[number], value)
+	}
+	return fields, nil
+}
+
+func (f protoFields) all(number protowire.Number) []protoValue {
+	return f[number]
+}
+
+func (f protoFields) varint(number protowire.Number) uint64 {
+	if values := f[number]; len(values) > 0 {
+		return values[len(values)-1].number
+	}
+	return 0
+}
+
+func (f protoFields) bytes(number protowire.Number) []byte {
+	if values := f[number]; len(values) > 0 {
+		return values[len(values)-1].bytes
+	}
+	return nil
+}
+
+func appendProtoString(b []byte, number protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendProtoVarint(b []byte, number protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, number, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"go-glyph/internal/core/dtos"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// States of the Steam circuit breaker
+const (
+	SteamCircuitClosed   = "closed"
+	SteamCircuitOpen     = "open"
+	SteamCircuitHalfOpen = "half_open"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitBaseDelay        = 5 * time.Second
+	defaultCircuitMaxDelay         = 5 * time.Minute
+	// circuitProbeRetryAfter is what requests are told while a half open circuit waits for its probe
+	circuitProbeRetryAfter = time.Second
+)
+
+// Results of a lookup allowed by the circuit breaker
+const (
+	circuitSucceeded = iota
+	circuitFailed
+	// circuitAbandoned is a lookup its caller gave up on before the GC answered, it tells nothing about the GC
+	circuitAbandoned
+)
+
+// CircuitBreakerConfig configures the circuit breaker of the GC path, zero values use the defaults
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed lookups opening the circuit
+	FailureThreshold int
+	// BaseDelay is how long the circuit first stays open, it doubles every time the probe fails up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// circuitBreaker stops sending lookups to the GC after consecutive failures. Once open, it lets a single
+// probe through after a jittered delay, closing again if the probe succeeds.
+type circuitBreaker struct {
+	threshold int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	now       func() time.Time
+	logger    *slog.Logger
+
+	mu          sync.Mutex
+	state       string
+	since       time.Time
+	failures    int
+	opened      int // consecutive opens since the circuit was last closed
+	openUntil   time.Time
+	probing     bool
+	transitions map[string]int64
+	rejected    int64
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig, logger *slog.Logger) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold:   config.FailureThreshold,
+		baseDelay:   config.BaseDelay,
+		maxDelay:    config.MaxDelay,
+		now:         time.Now,
+		logger:      logger,
+		state:       SteamCircuitClosed,
+		transitions: make(map[string]int64),
+	}
+	if b.threshold <= 0 {
+		b.threshold = defaultCircuitFailureThreshold
+	}
+	if b.baseDelay <= 0 {
+		b.baseDelay = defaultCircuitBaseDelay
+	}
+	if b.maxDelay <= 0 {
+		b.maxDelay = defaultCircuitMaxDelay
+	}
+	b.since = b.now()
+	return b
+}
+
+// Allow tells whether a lookup may go to the GC, or how long the caller should wait before trying again.
+// The result of an allowed lookup must be reported to done.
+func (b *circuitBreaker) Allow() (done func(result int), retryAfter time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.state == SteamCircuitOpen {
+		if now.Before(b.openUntil) {
+			b.rejected++
+			return nil, b.openUntil.Sub(now), false
+		}
+		b.setState(SteamCircuitHalfOpen)
+	}
+	if b.state == SteamCircuitHalfOpen {
+		if b.probing {
+			b.rejected++
+			return nil, circuitProbeRetryAfter, false
+		}
+		b.probing = true
+		return b.probeDone, 0, true
+	}
+	return b.lookupDone, 0, true
+}
+
+func (b *circuitBreaker) lookupDone(result int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Lookups allowed before the circuit opened do not count anymore
+	if b.state != SteamCircuitClosed || result == circuitAbandoned {
+		return
+	}
+	if result == circuitSucceeded {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *circuitBreaker) probeDone(result int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An abandoned probe lets the next lookup probe instead
+	b.probing = false
+	if result == circuitAbandoned {
+		return
+	}
+	if result == circuitFailed {
+		b.open()
+		return
+	}
+	b.failures = 0
+	b.opened = 0
+	b.setState(SteamCircuitClosed)
+	b.logger.Info("Steam circuit breaker closed, the GC answered the probe")
+}
+
+// open must be called with the lock held
+func (b *circuitBreaker) open() {
+	b.opened++
+	delay := backoffDelay(b.baseDelay, b.maxDelay, b.opened)
+	b.openUntil = b.now().Add(delay)
+	b.setState(SteamCircuitOpen)
+	b.logger.Warn("Steam circuit breaker opened", "delay", delay.Round(time.Millisecond), "failures", b.failures)
+}
+
+// setState must be called with the lock held
+func (b *circuitBreaker) setState(state string) {
+	if b.state != state {
+		b.logger.Debug("Steam circuit breaker changed state", "from", b.state, "to", state)
+	}
+	b.state = state
+	b.since = b.now()
+	b.transitions[state]++
+}
+
+// RetryAfter is how long the circuit stays open, zero if it is not open
+func (b *circuitBreaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == SteamCircuitOpen {
+		return max(b.openUntil.Sub(b.now()), 0)
+	}
+	return 0
+}
+
+func (b *circuitBreaker) Stats() dtos.SteamCircuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := dtos.SteamCircuit{
+		State:       b.state,
+		Since:       b.since,
+		Failures:    b.failures,
+		Rejected:    b.rejected,
+		Transitions: make(map[string]int64, len(b.transitions)),
+	}
+	for state, count := range b.transitions {
+		stats.Transitions[state] = count
+	}
+	if b.state == SteamCircuitOpen {
+		retryAt := b.openUntil
+		stats.RetryAt = &retryAt
+	}
+	return stats
+}
+
+// backoffDelay doubles base for every attempt after the first up to max, keeping a random half of it
+// so clients retrying together spread out
+func backoffDelay(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
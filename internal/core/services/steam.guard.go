@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const steamGuardCodeAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// steamGuardCode generates the mobile authenticator code of a base64 shared secret valid at the given time.
+// Codes are TOTP with 30 second steps, written in Steam's alphabet instead of digits.
+func steamGuardCode(sharedSecret string, at time.Time) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(sharedSecret)
+	if err != nil {
+		return "", fmt.Errorf("invalid Steam Guard shared secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	code := make([]byte, 5)
+	for i := range code {
+		code[i] = steamGuardCodeAlphabet[value%uint32(len(steamGuardCodeAlphabet))]
+		value /= uint32(len(steamGuardCodeAlphabet))
+	}
+	return string(code), nil
+}
+
+// DeviceCode generates a code of the mobile authenticator of the account
+func (a *steamAccount) DeviceCode() (string, bool) {
+	if a.sharedSecret == "" {
+		return "", false
+	}
+	code, err := steamGuardCode(a.sharedSecret, time.Now())
+	if err != nil {
+		a.logger.Error("Cannot generate Steam Guard code", "error", err)
+		return "", false
+	}
+	return code, true
+}
+
+// EmailCode waits until a code sent to the email of the account is submitted through the admin API
+func (a *steamAccount) EmailCode(ctx context.Context) (string, error) {
+	a.setAwaitingGuardCode(true)
+	defer a.setAwaitingGuardCode(false)
+
+	a.logger.Warn("Steam account waits for the Steam Guard code sent to its email",
+		"submit_to", "POST /api/admin/steam/accounts/"+a.loginInfo.Username+"/guard-code")
+	select {
+	case code := <-a.guardCodes:
+		return code, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("no Steam Guard code was submitted: %w", ctx.Err())
+	}
+}
+
+func (a *steamAccount) setAwaitingGuardCode(awaiting bool) {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+
+	switch {
+	case awaiting && a.state == SteamAccountConnecting:
+		a.setState(SteamAccountAwaitingGuardCode)
+	case !awaiting && a.state == SteamAccountAwaitingGuardCode:
+		a.setState(SteamAccountConnecting)
+	}
+	// A code submitted just before the wait ended is not meant for the next one
+	if !awaiting {
+		select {
+		case <-a.guardCodes:
+		default:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	steamAccountsDesc = prometheus.NewDesc("glyph_steam_accounts",
+		"Steam accounts of the pool by state, ready accounts have a Dota GC session.", []string{"state"}, nil)
+	steamInFlightDesc = prometheus.NewDesc("glyph_steam_requests_in_flight",
+		"Match detail requests waiting for the GC.", nil, nil)
+	steamCircuitStateDesc = prometheus.NewDesc("glyph_steam_circuit_state",
+		"1 for the current state of the GC circuit breaker, 0 for the others.", []string{"state"}, nil)
+	steamCircuitFailuresDesc = prometheus.NewDesc("glyph_steam_circuit_failures",
+		"Consecutive failed lookups counted by the closed circuit breaker.", nil, nil)
+	steamCircuitTransitionsDesc = prometheus.NewDesc("glyph_steam_circuit_transitions_total",
+		"Times the circuit breaker entered each state.", []string{"state"}, nil)
+	steamCircuitRejectedDesc = prometheus.NewDesc("glyph_steam_circuit_rejected_total",
+		"Lookups failed fast by the open circuit breaker.", nil, nil)
+)
+
+var (
+	steamAccountStates = []string{SteamAccountConnecting, SteamAccountAwaitingGuardCode, SteamAccountReady, SteamAccountQuarantined}
+	steamCircuitStates = []string{SteamCircuitClosed, SteamCircuitOpen, SteamCircuitHalfOpen}
+)
+
+// Describe and Collect expose the accounts and the circuit breaker of the pool to Prometheus, read on every scrape
+func (s *GoSteamService) Describe(ch chan<- *prometheus.Desc) {
+	ch <- steamAccountsDesc
+	ch <- steamInFlightDesc
+	ch <- steamCircuitStateDesc
+	ch <- steamCircuitFailuresDesc
+	ch <- steamCircuitTransitionsDesc
+	ch <- steamCircuitRejectedDesc
+}
+
+func (s *GoSteamService) Collect(ch chan<- prometheus.Metric) {
+	states := make(map[string]int)
+	inFlight := 0
+	for _, account := range s.AccountHealth() {
+		states[account.State]++
+		inFlight += account.InFlight
+	}
+	for _, state := range steamAccountStates {
+		ch <- prometheus.MustNewConstMetric(steamAccountsDesc, prometheus.GaugeValue, float64(states[state]), state)
+	}
+	ch <- prometheus.MustNewConstMetric(steamInFlightDesc, prometheus.GaugeValue, float64(inFlight))
+
+	circuit := s.breaker.Stats()
+	for _, state := range steamCircuitStates {
+		current := 0.0
+		if circuit.State == state {
+			current = 1
+		}
+		ch <- prometheus.MustNewConstMetric(steamCircuitStateDesc, prometheus.GaugeValue, current, state)
+		ch <- prometheus.MustNewConstMetric(steamCircuitTransitionsDesc, prometheus.CounterValue, float64(circuit.Transitions[state]), state)
+	}
+	ch <- prometheus.MustNewConstMetric(steamCircuitFailuresDesc, prometheus.GaugeValue, float64(circuit.Failures))
+	ch <- prometheus.MustNewConstMetric(steamCircuitRejectedDesc, prometheus.CounterValue, float64(circuit.Rejected))
+}
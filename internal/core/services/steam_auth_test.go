@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestSteamGuardCode(t *testing.T) {
+	// The RFC 6238 SHA-1 test key, whose code at 59 seconds is 94287082 in digits
+	secret := base64.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	code, err := steamGuardCode(secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatalf("steamGuardCode returned error: %v", err)
+	}
+	if code != "PV9M4" {
+		t.Fatalf("expected code PV9M4, got %s", code)
+	}
+	if sameStep, _ := steamGuardCode(secret, time.Unix(30, 0)); sameStep != code {
+		t.Fatalf("expected the same code within a 30 second step, got %s", sameStep)
+	}
+	if nextStep, _ := steamGuardCode(secret, time.Unix(60, 0)); nextStep == code {
+		t.Fatal("expected another code in the next step")
+	}
+
+	if _, err := steamGuardCode("not base64!", time.Now()); err == nil {
+		t.Fatal("expected an invalid shared secret to fail")
+	}
+}
+
+// fakeSteamGuard supplies a fixed device code and email codes from a channel
+type fakeSteamGuard struct {
+	deviceCode string
+	emailCodes chan string
+}
+
+func (g *fakeSteamGuard) DeviceCode() (string, bool) {
+	return g.deviceCode, g.deviceCode != ""
+}
+
+func (g *fakeSteamGuard) EmailCode(ctx context.Context) (string, error) {
+	select {
+	case code := <-g.emailCodes:
+		return code, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fakeSteamAuthServer implements the IAuthenticationService methods of a password login
+type fakeSteamAuthServer struct {
+	key           *rsa.PrivateKey
+	password      string
+	confirmations []int
+	acceptedCode  string
+	// polls is how often the session is polled before it is confirmed
+	polls int
+
+	mu        sync.Mutex
+	codes     []string
+	codeTypes []int
+	confirmed bool
+}
+
+func newFakeSteamAuthServer(t *testing.T, server *fakeSteamAuthServer) *steamAuthenticator {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	server.key = key
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return newSteamAuthenticator(httpServer.URL + "/IAuthenticationService")
+}
+
+func (s *fakeSteamAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoded, err := base64.StdEncoding.DecodeString(r.FormValue("input_protobuf_encoded"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request, err := parseProtoFields(encoded)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var response []byte
+	switch strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/IAuthenticationService/"), "/v1") {
+	case "GetPasswordRSAPublicKey":
+		response = appendProtoString(response, 1, s.key.N.Text(16))
+		response = appendProtoString(response, 2, strconv.FormatInt(int64(s.key.E), 16))
+		response = appendProtoVarint(response, 3, 1234)
+
+	case "BeginAuthSessionViaCredentials":
+		encrypted, _ := base64.StdEncoding.DecodeString(string(request.bytes(3)))
+		password, err := rsa.DecryptPKCS1v15(nil, s.key, encrypted)
+		if err != nil || string(password) != s.password || request.varint(4) != 1234 {
+			w.Header().Set("X-Eresult", strconv.Itoa(steamResultInvalidPassword))
+			return
+		}
+		response = appendProtoVarint(response, 1, 42)
+		response = appendProtoString(response, 2, "request")
+		response = protowire.AppendTag(response, 3, protowire.Fixed32Type)
+		response = protowire.AppendFixed32(response, math.Float32bits(0.01))
+		for _, confirmation := range s.confirmations {
+			var message []byte
+			message = appendProtoVarint(message, 1, uint64(confirmation))
+			response = protowire.AppendTag(response, 4, protowire.BytesType)
+			response = protowire.AppendBytes(response, message)
+		}
+		response = appendProtoVarint(response, 5, 76561197960287930)
+
+	case "UpdateAuthSessionWithSteamGuardCode":
+		code := string(request.bytes(3))
+		s.codes = append(s.codes, code)
+		s.codeTypes = append(s.codeTypes, int(request.varint(4)))
+		if request.varint(1) != 42 || request.varint(2) != 76561197960287930 || code != s.acceptedCode {
+			w.Header().Set("X-Eresult", strconv.Itoa(steamResultInvalidLoginAuthCode))
+			return
+		}
+		s.confirmed = true
+
+	case "PollAuthSessionStatus":
+		if string(request.bytes(2)) != "request" {
+			w.Header().Set("X-Eresult", "2")
+			return
+		}
+		if s.polls > 0 {
+			s.polls--
+			break
+		}
+		if len(s.confirmations) > 0 && !s.confirmed {
+			break
+		}
+		response = appendProtoString(response, 3, "refresh-token")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("X-Eresult", strconv.Itoa(steamResultOK))
+	_, _ = w.Write(response)
+}
+
+func TestSteamAuthenticatorAsksForEmailCodesUntilAccepted(t *testing.T) {
+	server := &fakeSteamAuthServer{password: "hunter2", confirmations: []int{steamGuardEmailCode}, acceptedCode: "VALID", polls: 2}
+	authenticator := newFakeSteamAuthServer(t, server)
+
+	guard := &fakeSteamGuard{emailCodes: make(chan string, 2)}
+	guard.emailCodes <- "WRONG"
+	guard.emailCodes <- "VALID"
+
+	token, err := authenticator.LogOn(context.Background(), "glyph", "hunter2", guard)
+	if err != nil {
+		t.Fatalf("LogOn returned error: %v", err)
+	}
+	if token != "refresh-token" {
+		t.Fatalf("expected the refresh token, got %q", token)
+	}
+	if fmt.Sprint(server.codes) != "[WRONG VALID]" || fmt.Sprint(server.codeTypes) != "[2 2]" {
+		t.Fatalf("expected both email codes to be submitted, got %v of types %v", server.codes, server.codeTypes)
+	}
+}
+
+func TestSteamAuthenticatorPrefersDeviceCode(t *testing.T) {
+	server := &fakeSteamAuthServer{password: "hunter2", confirmations: []int{steamGuardEmailCode, steamGuardDeviceCode}, acceptedCode: "DEV1C"}
+	authenticator := newFakeSteamAuthServer(t, server)
+
+	token, err := authenticator.LogOn(context.Background(), "glyph", "hunter2", &fakeSteamGuard{deviceCode: "DEV1C"})
+	if err != nil || token != "refresh-token" {
+		t.Fatalf("expected the refresh token, got %q (%v)", token, err)
+	}
+	if fmt.Sprint(server.codeTypes) != "[3]" {
+		t.Fatalf("expected a single device code, got types %v", server.codeTypes)
+	}
+}
+
+func TestSteamAuthenticatorFails(t *testing.T) {
+	t.Run("invalid password", func(t *testing.T) {
+		authenticator := newFakeSteamAuthServer(t, &fakeSteamAuthServer{password: "hunter2"})
+
+		_, err := authenticator.LogOn(context.Background(), "glyph", "wrong", &fakeSteamGuard{})
+		var authErr SteamAuthError
+		if !errors.As(err, &authErr) || authErr.Result != steamResultInvalidPassword {
+			t.Fatalf("expected an invalid password error, got %v", err)
+		}
+	})
+
+	t.Run("device code without shared secret", func(t *testing.T) {
+		authenticator := newFakeSteamAuthServer(t, &fakeSteamAuthServer{password: "hunter2", confirmations: []int{steamGuardDeviceCode}})
+
+		_, err := authenticator.LogOn(context.Background(), "glyph", "hunter2", &fakeSteamGuard{})
+		if !errors.Is(err, errSteamGuardSecretMissing) {
+			t.Fatalf("expected a missing shared secret error, got %v", err)
+		}
+	})
+
+	t.Run("no email code", func(t *testing.T) {
+		authenticator := newFakeSteamAuthServer(t, &fakeSteamAuthServer{password: "hunter2", confirmations: []int{steamGuardEmailCode}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := authenticator.LogOn(ctx, "glyph", "hunter2", &fakeSteamGuard{emailCodes: make(chan string)})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the login to give up with its context, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testCircuitBreaker is a breaker on a clock moved by hand
+func testCircuitBreaker(threshold int) (*circuitBreaker, *time.Time) {
+	now := time.Unix(1000, 0)
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: threshold, BaseDelay: 10 * time.Second, MaxDelay: 40 * time.Second}, slog.Default())
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func allowLookup(t *testing.T, b *circuitBreaker) func(failed bool) {
+	t.Helper()
+	done, retryAfter, ok := b.Allow()
+	if !ok {
+		t.Fatalf("expected the lookup to be allowed, retry after %v in state %s", retryAfter, b.Stats().State)
+	}
+	return func(failed bool) {
+		if failed {
+			done(circuitFailed)
+		} else {
+			done(circuitSucceeded)
+		}
+	}
+}
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	b, now := testCircuitBreaker(3)
+
+	// A success resets the consecutive failures
+	allowLookup(t, b)(true)
+	allowLookup(t, b)(true)
+	allowLookup(t, b)(false)
+	for i := 0; i < 3; i++ {
+		allowLookup(t, b)(true)
+	}
+	if state := b.Stats().State; state != SteamCircuitOpen {
+		t.Fatalf("expected the circuit to open after 3 failures, got %s", state)
+	}
+
+	_, retryAfter, ok := b.Allow()
+	if ok || retryAfter < 5*time.Second || retryAfter > 10*time.Second {
+		t.Fatalf("expected lookups to fail fast for 5 to 10 seconds, got %v (allowed %v)", retryAfter, ok)
+	}
+
+	// After the delay a single probe is let through, it fails and the delay doubles
+	*now = now.Add(10 * time.Second)
+	probe := allowLookup(t, b)
+	if _, _, ok := b.Allow(); ok {
+		t.Fatal("expected a single probe while half open")
+	}
+	probe(true)
+	stats := b.Stats()
+	if stats.State != SteamCircuitOpen || stats.RetryAt == nil || stats.RetryAt.Sub(*now) < 10*time.Second {
+		t.Fatalf("expected the circuit to open again for 10 to 20 seconds, got %+v", stats)
+	}
+
+	// A successful probe closes the circuit
+	*now = now.Add(20 * time.Second)
+	allowLookup(t, b)(false)
+	stats = b.Stats()
+	if stats.State != SteamCircuitClosed || stats.Failures != 0 {
+		t.Fatalf("expected the circuit to close, got %+v", stats)
+	}
+	if stats.Transitions[SteamCircuitOpen] != 2 || stats.Transitions[SteamCircuitHalfOpen] != 2 ||
+		stats.Transitions[SteamCircuitClosed] != 1 || stats.Rejected != 2 {
+		t.Fatalf("unexpected transitions %+v", stats)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if delay := backoffDelay(time.Second, 5*time.Second, attempt); delay < want/2 || delay > want {
+				t.Fatalf("expected attempt %d to wait between %v and %v, got %v", attempt, want/2, want, delay)
+			}
+		}
+	}
+}
+
+func TestGoSteamServiceFailsFastWhileCircuitIsOpen(t *testing.T) {
+	var lookups atomic.Int32
+	s, _ := fakeSteamPool(t, []string{"broken"}, func(username string, ctx context.Context) error {
+		lookups.Add(1)
+		return errDotaNotReady
+	})
+	s.breaker = newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, BaseDelay: time.Minute}, slog.Default())
+	waitForReadyAccounts(t, s, 1)
+
+	_, err := s.GetMatchDetails(context.Background(), 1234)
+	var unavailable SteamUnavailableError
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter <= 0 {
+		t.Fatalf("expected Steam to be unavailable with a retry delay, got %v", err)
+	}
+
+	// The open circuit answers without touching the accounts
+	tried := lookups.Load()
+	_, err = s.GetMatchDetails(context.Background(), 1234)
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter < 30*time.Second {
+		t.Fatalf("expected the open circuit to fail fast, got %v", err)
+	}
+	if lookups.Load() != tried {
+		t.Fatal("expected no lookup while the circuit is open")
+	}
+}
+
+func TestCircuitBreakerIgnoresAbandonedLookups(t *testing.T) {
+	b, now := testCircuitBreaker(1)
+	done, _, _ := b.Allow()
+	done(circuitAbandoned)
+	if stats := b.Stats(); stats.State != SteamCircuitClosed || stats.Failures != 0 {
+		t.Fatalf("expected an abandoned lookup not to count, got %+v", stats)
+	}
+
+	allowLookup(t, b)(true)
+	*now = now.Add(10 * time.Second)
+
+	// An abandoned probe lets the next lookup probe instead, without closing the circuit
+	probe, _, _ := b.Allow()
+	probe(circuitAbandoned)
+	if state := b.Stats().State; state != SteamCircuitHalfOpen {
+		t.Fatalf("expected the circuit to stay half open, got %s", state)
+	}
+	allowLookup(t, b)(false)
+	if state := b.Stats().State; state != SteamCircuitClosed {
+		t.Fatalf("expected the next probe to close the circuit, got %s", state)
+	}
+}
+
+func TestGoSteamServiceCollectsMetrics(t *testing.T) {
+	s, _ := fakeSteamPool(t, []string{"first", "second"}, func(username string, ctx context.Context) error {
+		return nil
+	})
+	waitForReadyAccounts(t, s, 2)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(s)
+	expected := `
+# HELP glyph_steam_accounts Steam accounts of the pool by state, ready accounts have a Dota GC session.
+# TYPE glyph_steam_accounts gauge
+glyph_steam_accounts{state="awaiting_guard_code"} 0
+glyph_steam_accounts{state="connecting"} 0
+glyph_steam_accounts{state="quarantined"} 0
+glyph_steam_accounts{state="ready"} 2
+# HELP glyph_steam_circuit_state 1 for the current state of the GC circuit breaker, 0 for the others.
+# TYPE glyph_steam_circuit_state gauge
+glyph_steam_circuit_state{state="closed"} 1
+glyph_steam_circuit_state{state="half_open"} 0
+glyph_steam_circuit_state{state="open"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "glyph_steam_accounts", "glyph_steam_circuit_state"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,166 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"errors"
+	"go-glyph/internal/core/fakesteam"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sicdex/go-steam-ws"
+)
+
+// startFakeCM serves the match fixtures from a fake CM the Steam client connects to instead of Steam.
+// The client dials wss://<endpoint>/cmsocket/, so the default transport trusts the certificate of the fake.
+func startFakeCM(t *testing.T) *fakesteam.Server {
+	t.Helper()
+	gc, err := fakesteam.LoadGC("../fakesteam/testdata/matches.json")
+	if err != nil {
+		t.Fatalf("LoadGC returned error: %v", err)
+	}
+	server := fakesteam.NewTLSServer(gc)
+	t.Cleanup(server.Close)
+
+	transport := http.DefaultTransport.(*http.Transport)
+	tlsConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	t.Cleanup(func() { transport.TLSClientConfig = tlsConfig })
+
+	listServers := steamCMServers
+	steamCMServers = func() ([]steam.CMServer, error) {
+		return []steam.CMServer{{Endpoint: server.Endpoint(), Type: "websockets"}}, nil
+	}
+	t.Cleanup(func() { steamCMServers = listServers })
+	return server
+}
+
+// memorySteamTokenStore keeps refresh tokens in memory
+type memorySteamTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (s *memorySteamTokenStore) RefreshToken(username string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[username], nil
+}
+
+func (s *memorySteamTokenStore) SaveRefreshToken(username, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[username] = token
+	return nil
+}
+
+func (s *memorySteamTokenStore) ForgetRefreshToken(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, username)
+	return nil
+}
+
+func TestIntegrationInitDotaClient(t *testing.T) {
+	server := startFakeCM(t)
+
+	disconnected := make(chan struct{}, 1)
+	sc, dc, err := initDotaClient(&steam.LogOnDetails{Username: "glyph"}, "refresh-token", func() {
+		disconnected <- struct{}{}
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("initDotaClient returned error: %v", err)
+	}
+	t.Cleanup(sc.Disconnect)
+
+	ctx, cancel := context.WithTimeout(context.Background(), steamRequestTimeout)
+	defer cancel()
+	response, err := dc.RequestMatchDetails(ctx, 7500000001)
+	if err != nil {
+		t.Fatalf("RequestMatchDetails returned error: %v", err)
+	}
+	if response.GetMatch().GetCluster() != 236 || response.GetMatch().GetReplaySalt() != 1183751 {
+		t.Fatalf("expected the fixture of the match, got %v", response.GetMatch())
+	}
+	if logons := server.Logons(); len(logons) != 1 || logons[0].AccessToken != "refresh-token" {
+		t.Fatalf("expected a logon with the refresh token, got %+v", logons)
+	}
+
+	server.Disconnect()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dropped connection was not reported")
+	}
+}
+
+func TestIntegrationInitDotaClientRejectedToken(t *testing.T) {
+	server := startFakeCM(t)
+	server.RequireRefreshToken("glyph", "valid")
+
+	_, _, err := initDotaClient(&steam.LogOnDetails{Username: "glyph"}, "expired", nil, slog.Default())
+	if !errors.Is(err, errSteamTokenRejected) {
+		t.Fatalf("expected the token to be rejected, got %v", err)
+	}
+}
+
+func TestIntegrationPoolLogsInWithPasswordAndReconnects(t *testing.T) {
+	server := startFakeCM(t)
+	server.RequireRefreshToken("glyph", "refresh-token")
+	authenticator := newFakeSteamAuthServer(t, &fakeSteamAuthServer{password: "hunter2"})
+	// The stored token expired, the account logs in with its password and stores the new token
+	tokens := &memorySteamTokenStore{tokens: map[string]string{"glyph": "expired"}}
+
+	s := newGoSteamService([]SteamAccountConfig{{Username: "glyph", Password: "hunter2"}},
+		connectDotaSession(tokens, authenticator, slog.Default()), 100*time.Millisecond, slog.Default())
+	t.Cleanup(s.Close)
+	waitForReadyAccountsWithin(t, s, 1, 10*time.Second)
+
+	match, err := s.GetMatchDetails(context.Background(), 7500000002)
+	if err != nil || match.Cluster != 183 || match.ReplaySalt != 942016 {
+		t.Fatalf("expected the fixture of the match, got %+v (%v)", match, err)
+	}
+	if token, _ := tokens.RefreshToken("glyph"); token != "refresh-token" {
+		t.Fatalf("expected the new refresh token to be stored, got %q", token)
+	}
+
+	// A dropped connection quarantines the account, which logs in again with the stored token
+	server.Disconnect()
+	deadline := time.Now().Add(10 * time.Second)
+	for len(server.Logons()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the account to log in again, got logons %+v", server.Logons())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitForReadyAccountsWithin(t, s, 1, 10*time.Second)
+	if _, err := s.GetMatchDetails(context.Background(), 7500000001); err != nil {
+		t.Fatalf("GetMatchDetails after reconnecting returned error: %v", err)
+	}
+}
+
+func waitForReadyAccountsWithin(t *testing.T, s *GoSteamService, want int, timeout time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		ready := 0
+		for _, account := range s.AccountHealth() {
+			if account.State == SteamAccountReady {
+				ready++
+			}
+		}
+		if ready == want {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected %d ready accounts, got %+v", want, s.AccountHealth())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/models"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type SteamSessionServiceRepository interface {
+	GetSteamSession(username string) (session models.SteamSession, found bool, err error)
+	GetSteamSessions() ([]models.SteamSession, error)
+	SaveSteamSession(session *models.SteamSession) error
+	DeleteSteamSessions(usernames ...string) (int64, error)
+}
+
+// SteamSessionService stores the refresh tokens of Steam accounts encrypted, so restarts reuse them
+// instead of logging in with the password
+type SteamSessionService struct {
+	SteamSessionServiceRepository SteamSessionServiceRepository
+	aead                          cipher.AEAD
+	logger                        *slog.Logger
+}
+
+// NewSteamSessionService encrypts tokens with a key derived from the secret.
+// Tokens are neither stored nor reused without a secret, sessions can still be listed and revoked.
+func NewSteamSessionService(steamSessionServiceRepository SteamSessionServiceRepository, secret string, logger *slog.Logger) *SteamSessionService {
+	service := &SteamSessionService{SteamSessionServiceRepository: steamSessionServiceRepository, logger: logger}
+	if secret != "" {
+		key := sha256.Sum256([]byte(secret))
+		// A 32 byte key is always valid for AES-256 in GCM mode
+		block, _ := aes.NewCipher(key[:])
+		service.aead, _ = cipher.NewGCM(block)
+	}
+	return service
+}
+
+// Enabled tells whether tokens are stored and reused
+func (s *SteamSessionService) Enabled() bool {
+	return s.aead != nil
+}
+
+// RefreshToken returns the stored token of the account, empty if there is none that can be used.
+// Expired tokens and tokens encrypted with another key are deleted.
+func (s *SteamSessionService) RefreshToken(username string) (string, error) {
+	if !s.Enabled() {
+		return "", nil
+	}
+
+	session, found, err := s.SteamSessionServiceRepository.GetSteamSession(username)
+	if err != nil {
+		return "", RepositoryError{err}
+	}
+	if !found {
+		return "", nil
+	}
+
+	if !session.ExpiresAt.IsZero() && time.Now().After(session.ExpiresAt) {
+		s.logger.Info("Stored Steam session expired", steamAccountLogKey, username)
+		return "", s.ForgetRefreshToken(username)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(session.RefreshToken) < nonceSize {
+		return "", s.ForgetRefreshToken(username)
+	}
+	token, err := s.aead.Open(nil, session.RefreshToken[:nonceSize], session.RefreshToken[nonceSize:], []byte(username))
+	if err != nil {
+		s.logger.Warn("Stored Steam session cannot be decrypted, STEAM_SESSION_KEY changed?", steamAccountLogKey, username)
+		return "", s.ForgetRefreshToken(username)
+	}
+	return string(token), nil
+}
+
+// SaveRefreshToken encrypts and stores the token of the account, replacing the previous one
+func (s *SteamSessionService) SaveRefreshToken(username, token string) error {
+	if !s.Enabled() || token == "" {
+		return nil
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	session := models.SteamSession{
+		Username: username,
+		// The username is authenticated, so a token cannot be moved to another account
+		RefreshToken: s.aead.Seal(nonce, nonce, []byte(token), []byte(username)),
+		ExpiresAt:    refreshTokenExpiry(token),
+	}
+	if existing, found, err := s.SteamSessionServiceRepository.GetSteamSession(username); err == nil && found {
+		session.CreatedAt = existing.CreatedAt
+	}
+	if err := s.SteamSessionServiceRepository.SaveSteamSession(&session); err != nil {
+		return RepositoryError{err}
+	}
+	return nil
+}
+
+// ForgetRefreshToken deletes the token of the account, so it logs in with its password next time
+func (s *SteamSessionService) ForgetRefreshToken(username string) error {
+	if _, err := s.SteamSessionServiceRepository.DeleteSteamSessions(username); err != nil {
+		return RepositoryError{err}
+	}
+	return nil
+}
+
+// GetSessions lists the stored sessions
+func (s *SteamSessionService) GetSessions() ([]dtos.SteamSession, error) {
+	sessions, err := s.SteamSessionServiceRepository.GetSteamSessions()
+	if err != nil {
+		return nil, RepositoryError{err}
+	}
+
+	now := time.Now()
+	result := make([]dtos.SteamSession, len(sessions))
+	for i, session := range sessions {
+		result[i] = dtos.SteamSession{
+			Username:  session.Username,
+			ExpiresAt: session.ExpiresAt,
+			Expired:   !session.ExpiresAt.IsZero() && now.After(session.ExpiresAt),
+			CreatedAt: session.CreatedAt,
+			UpdatedAt: session.UpdatedAt,
+		}
+	}
+	return result, nil
+}
+
+// RevokeSessions deletes the stored sessions of the accounts, or all of them if none is given
+func (s *SteamSessionService) RevokeSessions(usernames ...string) (int64, error) {
+	deleted, err := s.SteamSessionServiceRepository.DeleteSteamSessions(usernames...)
+	if err != nil {
+		return 0, RepositoryError{err}
+	}
+	return deleted, nil
+}
+
+// refreshTokenExpiry reads the expiry of a Steam refresh token, which is a JWT. It is zero if the token is not one.
+func refreshTokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
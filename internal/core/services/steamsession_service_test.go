@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"go-glyph/internal/core/models"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// memorySteamSessionRepository keeps sessions in a map
+type memorySteamSessionRepository struct {
+	sessions map[string]models.SteamSession
+}
+
+func newMemorySteamSessionRepository() *memorySteamSessionRepository {
+	return &memorySteamSessionRepository{sessions: make(map[string]models.SteamSession)}
+}
+
+func (r *memorySteamSessionRepository) GetSteamSession(username string) (models.SteamSession, bool, error) {
+	session, found := r.sessions[username]
+	return session, found, nil
+}
+
+func (r *memorySteamSessionRepository) GetSteamSessions() ([]models.SteamSession, error) {
+	var sessions []models.SteamSession
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *memorySteamSessionRepository) SaveSteamSession(session *models.SteamSession) error {
+	r.sessions[session.Username] = *session
+	return nil
+}
+
+func (r *memorySteamSessionRepository) DeleteSteamSessions(usernames ...string) (int64, error) {
+	if len(usernames) == 0 {
+		deleted := len(r.sessions)
+		clear(r.sessions)
+		return int64(deleted), nil
+	}
+	var deleted int64
+	for _, username := range usernames {
+		if _, found := r.sessions[username]; found {
+			delete(r.sessions, username)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// testRefreshToken builds a JWT shaped token expiring at exp
+func testRefreshToken(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"7656","exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJFZERTQSJ9." + payload + ".signature"
+}
+
+func TestSteamSessionServiceStoresEncryptedTokens(t *testing.T) {
+	repository := newMemorySteamSessionRepository()
+	s := NewSteamSessionService(repository, "secret", slog.Default())
+	token := testRefreshToken(time.Now().Add(time.Hour))
+
+	if err := s.SaveRefreshToken("bot1", token); err != nil {
+		t.Fatalf("SaveRefreshToken returned error: %v", err)
+	}
+	stored := repository.sessions["bot1"]
+	if bytes.Contains(stored.RefreshToken, []byte(token)) || stored.ExpiresAt.Unix() != time.Now().Add(time.Hour).Unix() {
+		t.Fatalf("expected an encrypted token with its expiry, got %+v", stored)
+	}
+
+	if got, err := s.RefreshToken("bot1"); err != nil || got != token {
+		t.Fatalf("expected the stored token, got %q (%v)", got, err)
+	}
+	if got, err := s.RefreshToken("bot2"); err != nil || got != "" {
+		t.Fatalf("expected no token for another account, got %q (%v)", got, err)
+	}
+
+	// A token cannot be read with another key or moved to another account
+	if got, _ := NewSteamSessionService(repository, "other", slog.Default()).RefreshToken("bot1"); got != "" {
+		t.Fatalf("expected no token with another key, got %q", got)
+	}
+	if _, found := repository.sessions["bot1"]; found {
+		t.Fatal("expected a token that cannot be decrypted to be deleted")
+	}
+	if err := s.SaveRefreshToken("bot1", token); err != nil {
+		t.Fatalf("SaveRefreshToken returned error: %v", err)
+	}
+	repository.sessions["bot2"] = models.SteamSession{Username: "bot2", RefreshToken: repository.sessions["bot1"].RefreshToken}
+	if got, _ := s.RefreshToken("bot2"); got != "" {
+		t.Fatalf("expected a token copied to another account to be rejected, got %q", got)
+	}
+}
+
+func TestSteamSessionServiceForgetsExpiredTokens(t *testing.T) {
+	repository := newMemorySteamSessionRepository()
+	s := NewSteamSessionService(repository, "secret", slog.Default())
+
+	if err := s.SaveRefreshToken("bot1", testRefreshToken(time.Now().Add(-time.Minute))); err != nil {
+		t.Fatalf("SaveRefreshToken returned error: %v", err)
+	}
+	sessions, err := s.GetSessions()
+	if err != nil || len(sessions) != 1 || !sessions[0].Expired {
+		t.Fatalf("expected one expired session, got %+v (%v)", sessions, err)
+	}
+
+	if got, err := s.RefreshToken("bot1"); err != nil || got != "" {
+		t.Fatalf("expected no token once expired, got %q (%v)", got, err)
+	}
+	if len(repository.sessions) != 0 {
+		t.Fatal("expected the expired token to be deleted")
+	}
+}
+
+func TestSteamSessionServiceWithoutKey(t *testing.T) {
+	repository := newMemorySteamSessionRepository()
+	s := NewSteamSessionService(repository, "", slog.Default())
+
+	if err := s.SaveRefreshToken("bot1", testRefreshToken(time.Now().Add(time.Hour))); err != nil || len(repository.sessions) != 0 {
+		t.Fatalf("expected tokens not to be stored without a key, got %d (%v)", len(repository.sessions), err)
+	}
+
+	// Sessions stored before can still be revoked
+	repository.sessions["bot1"] = models.SteamSession{Username: "bot1"}
+	repository.sessions["bot2"] = models.SteamSession{Username: "bot2"}
+	if revoked, err := s.RevokeSessions("bot1", "bot3"); err != nil || revoked != 1 {
+		t.Fatalf("expected one revoked session, got %d (%v)", revoked, err)
+	}
+	if revoked, err := s.RevokeSessions(); err != nil || revoked != 1 || len(repository.sessions) != 0 {
+		t.Fatalf("expected the remaining session to be revoked, got %d (%v)", revoked, err)
+	}
+}
@@ -2,15 +2,22 @@ package services
 
 import (
 	"bufio"
+	"bytes"
 	"compress/bzip2"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/gofiber/fiber/v2"
 	"go-glyph/internal/core/dtos"
+	"go-glyph/internal/core/extractors"
+	"go-glyph/internal/core/logging"
+	"go-glyph/internal/core/metrics"
+	"go-glyph/internal/core/pbzip2"
+	"go-glyph/internal/core/tracing"
 	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"strings"
+	"path/filepath"
+	"runtime"
 	"time"
 )
 
@@ -20,49 +27,91 @@ const (
 )
 
 type ValveService struct {
-	client http.Client
+	sources    []ReplaySource
+	cache      *CacheReplaySource
+	downloader *Downloader
+	// decompressWorkers is the number of cores bzip2 replays are decompressed on
+	decompressWorkers int
+	logger            *slog.Logger
 }
 
-func NewValveService() *ValveService {
-	return &ValveService{client: http.Client{}}
+// NewValveService creates a service retrieving replays from the sources in order.
+// Replays from other sources are stored in the cache unless it is nil.
+// The downloader is the one used by the sources, its stats are reported by DownloadStats.
+// Replays are decompressed by compress/bzip2 if decompressWorkers is 0, by that many workers in parallel
+// if it is positive and by a worker per core if it is negative.
+func NewValveService(sources []ReplaySource, cache *CacheReplaySource, downloader *Downloader, decompressWorkers int, logger *slog.Logger) *ValveService {
+	return &ValveService{sources: sources, cache: cache, downloader: downloader, decompressWorkers: decompressWorkers, logger: logger}
 }
 
-func (s ValveService) RetrieveFile(match dtos.Match) error {
-	if match.Cluster == 0 {
-		return UserFacingError{Code: fiber.StatusNotFound, Message: "Match id is invalid"}
+// DownloadStats returns the current downloads and throughput per Valve cluster and mirror
+func (s ValveService) DownloadStats() []dtos.DownloadStats {
+	if s.downloader == nil {
+		return []dtos.DownloadStats{}
 	}
+	return s.downloader.Stats()
+}
 
-	startTime := time.Now()
-
-	url := fmt.Sprintf(baseReplayURL, match.Cluster, match.ID, match.ReplaySalt)
-	response, err := s.client.Get(url)
-	if err != nil {
-		return GETError{url: url, error: err}
-	}
-	if response.StatusCode != 200 {
-		body, err := io.ReadAll(response.Body)
+// RetrieveFile retrieves and decompresses the replay of the match from the first source that has it,
+// returning the path of the demo. Once ctx is done the retrieval stops and the partial demo is removed.
+func (s ValveService) RetrieveFile(ctx context.Context, match dtos.Match) (string, error) {
+	ctx = logging.WithMatchID(ctx, match.ID)
+	if _, err := os.Stat(demosPath); os.IsNotExist(err) {
+		err := os.Mkdir(demosPath, os.ModePerm)
 		if err != nil {
-			log.Printf("HTTP body read error to %s with status code %d", url, response.StatusCode)
-			return ReadResponseBodyError{err}
+			return "", FolderCreationError{foldername: demosPath, error: err}
 		}
+	}
+	filename := fmt.Sprintf("%s/%d.dem", demosPath, match.ID)
 
-		bodyStr := string(body)
-		if strings.Contains(bodyStr, "Error: 2010") {
-			log.Printf("HTTP error to %s with status code %d and body: %s", url, response.StatusCode, bodyStr)
-			return UserFacingError{Code: fiber.StatusNotFound, Message: "Match is too new or too old :("}
+	var sourceErrors []ReplaySourceError
+	for _, source := range s.sources {
+		startTime := time.Now()
+
+		err := s.retrieveFrom(ctx, source, match, filename)
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			s.logger.InfoContext(ctx, "Retrieval of replay stopped", "error", ctxErr)
+			return "", ctxErr
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "Cannot retrieve replay", "source", source.Name(), "error", err)
+			sourceErrors = append(sourceErrors, ReplaySourceError{source: source.Name(), error: err})
+			continue
 		}
 
-		return HTTPError{url: url, statusCode: response.StatusCode, response: bodyStr}
+		// Log the time it took to download and decompress
+		duration := time.Since(startTime)
+		s.logger.InfoContext(ctx, "Retrieved and decompressed replay", "file", filename, "source", source.Name(), "duration", duration)
+
+		// Decompression completed
+		return filename, nil
 	}
-	defer response.Body.Close()
 
-	if _, err := os.Stat(demosPath); os.IsNotExist(err) {
-		err := os.Mkdir(demosPath, os.ModePerm)
-		if err != nil {
-			return FolderCreationError{foldername: demosPath, error: err}
-		}
+	return "", ReplayUnavailableError{errors: sourceErrors}
+}
+
+// retrieveFrom writes the decompressed replay from the source to filename
+func (s ValveService) retrieveFrom(ctx context.Context, source ReplaySource, match dtos.Match, filename string) (err error) {
+	ctx, span := tracing.Start(ctx, "replay.Retrieve", tracing.MatchIDKey.Int(match.ID),
+		tracing.ReplaySourceKey.String(source.Name()), tracing.ReplayClusterKey.Int(match.Cluster))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	// Stops the reads of the source, before it and the files are closed
+	ctx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+
+	start := time.Now()
+	replay, err := source.Open(ctx, match)
+	if err != nil {
+		return err
 	}
-	filename := fmt.Sprintf("%s/%d.dem", demosPath, match.ID)
+	defer replay.Close()
+	// The download and the decompression overlap, reads of the source are timed apart
+	timedReplay := &timedReader{r: replay, elapsed: time.Since(start)}
+	defer func() {
+		span.SetAttributes(tracing.ReplayBytesKey.Int64(timedReplay.n))
+	}()
 
 	// Check if file exists, and if it does, remove it to ensure a fresh download.
 	if _, err := os.Stat(filename); err == nil {
@@ -76,27 +125,137 @@ func (s ValveService) RetrieveFile(match dtos.Match) error {
 	if err != nil {
 		return FileCreationError{filename: filename, error: err}
 	}
-	defer file.Close()
+	defer func() {
+		_ = file.Close()
+		if err != nil {
+			_ = os.Remove(filename)
+		}
+	}()
 
-	bufferedWriter := bufio.NewWriter(file)
-	defer bufferedWriter.Flush()
+	// Local replays have no download to abort, their reads stop with ctx instead
+	bufferedReader := bufio.NewReader(newContextReader(ctx, timedReplay))
+	magic, _ := bufferedReader.Peek(len(bzip2Magic))
+	compressed := bytes.Equal(magic, bzip2Magic)
+	span.SetAttributes(tracing.CompressedKey.Bool(compressed))
+
+	var reader io.Reader = bufferedReader
+
+	// Keep the replay as it was retrieved, so the cache takes as little space as possible.
+	// A replay that cannot be cached is still parsed.
+	if s.cache != nil && source != ReplaySource(s.cache) {
+		cacheFile, commit, cacheErr := s.cache.Create(match, compressed)
+		if cacheErr != nil {
+			s.logger.WarnContext(ctx, "Cannot cache replay", "error", cacheErr)
+		} else {
+			defer func() {
+				_ = cacheFile.Close()
+				if err == nil {
+					cacheErr := commit()
+					if cacheErr == nil {
+						if evictErr := s.cache.Evict(); evictErr != nil {
+							s.logger.WarnContext(ctx, "Cannot evict cached replays", "error", evictErr)
+						}
+						return
+					}
+					s.logger.WarnContext(ctx, "Cannot cache replay", "error", cacheErr)
+				}
+				_ = os.Remove(cacheFile.Name())
+			}()
+			reader = io.TeeReader(reader, cacheFile)
+		}
+	}
 
 	// Create a bzip2 reader to decompress the content
-	reader := bzip2.NewReader(response.Body)
+	if compressed {
+		reader = s.decompress(reader)
+		if closer, ok := reader.(io.Closer); ok {
+			// The decompressor reads ahead, a read in progress ends with ctx and is waited for,
+			// so the cache and the source are not used once they are closed
+			defer func() {
+				stopReading()
+				_ = closer.Close()
+			}()
+		}
+	}
 
-	// Copy the decompressed content to the file
-	_, err = io.Copy(bufferedWriter, reader)
-	if err != nil {
-		_ = bufferedWriter.Flush()
-		_ = file.Close()
-		_ = os.Remove(filename)
+	// Copy the decompressed content to the file.
+	// The bzip2 reader only ends without error once the end of stream marker and checksums are read,
+	// so a replay cut short is never handed to the parser.
+	bufferedWriter := bufio.NewWriter(file)
+	if _, err := io.Copy(bufferedWriter, reader); err != nil {
+		if compressed && errors.Is(err, io.ErrUnexpectedEOF) {
+			return IncompleteReplayError{err}
+		}
+		return CopyError{err}
+	}
+	if err := bufferedWriter.Flush(); err != nil {
 		return CopyError{err}
 	}
 
-	// Log the time it took to download and decompress
-	duration := time.Since(startTime)
-	log.Printf("Downloaded and decompressed file %s in %v", filename, duration)
+	// A source serving the demo of another match is not trusted, the next source is tried instead
+	if err := checkDemoMatchID(file, match.ID); err != nil {
+		return err
+	}
 
-	// Decompression completed
+	metrics.DownloadDuration.WithLabelValues(source.Name()).Observe(timedReplay.elapsed.Seconds())
+	if compressed {
+		metrics.DecompressDuration.Observe((time.Since(start) - timedReplay.elapsed).Seconds())
+	}
 	return nil
 }
+
+// checkDemoMatchID makes sure the demo written to file records the given match in its CDemoFileInfo
+func checkDemoMatchID(file *os.File, matchID int) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return DemoMatchIDError{filename: file.Name(), error: err}
+	}
+	demoMatchID, err := extractors.ReadDemoMatchID(file)
+	if err != nil {
+		return DemoMatchIDError{filename: file.Name(), error: err}
+	}
+	if demoMatchID != matchID {
+		return ReplayMatchMismatchError{matchID: matchID, demoMatchID: demoMatchID}
+	}
+	return nil
+}
+
+// decompress returns a bzip2 reader, decompressing on several cores if enabled
+func (s ValveService) decompress(r io.Reader) io.Reader {
+	switch {
+	case s.decompressWorkers > 0:
+		return pbzip2.NewReader(r, s.decompressWorkers)
+	case s.decompressWorkers < 0:
+		return pbzip2.NewReader(r, runtime.GOMAXPROCS(0))
+	default:
+		return bzip2.NewReader(r)
+	}
+}
+
+// RemoveFile removes a demo retrieved by RetrieveFile
+func (s ValveService) RemoveFile(filename string) error {
+	if err := os.Remove(filename); err != nil {
+		return RemoveFileError{filename: filename, error: err}
+	}
+	return nil
+}
+
+// RemoveTempFiles removes the demos left in the demos directory and the unfinished replays of the cache.
+// It must only be called once no replay is being retrieved or parsed.
+func (s ValveService) RemoveTempFiles() error {
+	patterns := []string{filepath.Join(demosPath, "*.dem")}
+	if s.cache != nil {
+		patterns = append(patterns, filepath.Join(s.cache.dir, "*.part"))
+	}
+
+	var errs []error
+	for _, pattern := range patterns {
+		// Glob only fails on malformed patterns
+		filenames, _ := filepath.Glob(pattern)
+		for _, filename := range filenames {
+			if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, RemoveFileError{filename: filename, error: err})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
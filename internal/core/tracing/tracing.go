@@ -0,0 +1,105 @@
+// Package tracing holds the OpenTelemetry tracer of the parse pipeline and the exporters its spans are sent to
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "go-glyph"
+
+// Exporters spans can be sent to
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// DefaultOTLPEndpoint is the traces URL of a collector running next to the server
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// Attributes of the spans of the pipeline
+const (
+	MatchIDKey       = attribute.Key("glyph.match_id")
+	SteamAccountKey  = attribute.Key("glyph.steam.account")
+	SteamAttemptKey  = attribute.Key("glyph.steam.attempt")
+	ReplaySourceKey  = attribute.Key("glyph.replay.source")
+	ReplayClusterKey = attribute.Key("glyph.replay.cluster")
+	ReplayBytesKey   = attribute.Key("glyph.replay.bytes")
+	CompressedKey    = attribute.Key("glyph.replay.compressed")
+)
+
+// Tracer returns the tracer of the server. Its spans are dropped until Setup installs the tracer provider,
+// which is looked up on every call so tests can install their own.
+func Tracer() trace.Tracer {
+	return otel.Tracer(serviceName)
+}
+
+// Start starts a span of the pipeline as a child of the span of ctx
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Setup installs the tracer provider exporting spans to the exporter and the W3C trace context propagator.
+// Spans are created even without exporter, so trace IDs still tie responses to their log lines.
+// The returned function flushes the spans not exported yet and stops the exporter.
+func Setup(exporter, otlpEndpoint string) (func(context.Context) error, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	}
+
+	switch exporter {
+	case "", ExporterNone:
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(spanExporter))
+	case ExporterOTLP:
+		if otlpEndpoint == "" {
+			otlpEndpoint = DefaultOTLPEndpoint
+		}
+		// The exporter connects lazily, a collector that is down only loses the spans
+		spanExporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(otlpEndpoint))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(spanExporter))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected %s, %s or %s", exporter, ExporterNone, ExporterStdout, ExporterOTLP)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// End records err on the span unless it is nil and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID returns the ID of the trace of ctx, empty if ctx has no span
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
@@ -1,15 +1,24 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"go-glyph/configuration"
 	"go-glyph/internal/core/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 	"log"
+	"log/slog"
+	"time"
 )
 
-func ConnectDB(config *configuration.EnvConfigModel) *gorm.DB {
+// slowQueryThreshold is the duration above which queries are logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// ConnectDB connects to the database and applies the migrations. Queries are logged without their values,
+// which include the stored Steam sessions.
+func ConnectDB(config *configuration.EnvConfigModel, logger *slog.Logger) *gorm.DB {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
 		config.DBHost,
 		config.DBUserName,
@@ -19,7 +28,19 @@ func ConnectDB(config *configuration.EnvConfigModel) *gorm.DB {
 		config.SSLMode,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Every query is logged at debug level, failed and slow ones otherwise
+	logLevel := gormlogger.Warn
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		logLevel = gormlogger.Info
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: gormlogger.NewSlogLogger(logger, gormlogger.Config{
+			SlowThreshold:             slowQueryThreshold,
+			LogLevel:                  logLevel,
+			IgnoreRecordNotFoundError: true,
+			ParameterizedQueries:      true,
+		}),
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to the Database!\n", err.Error())
 	}
@@ -29,12 +50,27 @@ func ConnectDB(config *configuration.EnvConfigModel) *gorm.DB {
 
 	err = db.AutoMigrate(
 		&models.Glyph{},
+		&models.Match{},
+		&models.MatchPlayer{},
+		&models.DraftPick{},
+		&models.Objective{},
+		&models.Ward{},
+		&models.SteamSession{},
 	)
 	if err != nil {
 		log.Fatal("Migration Failed:\n", err.Error())
 	}
 
-	log.Println("Successfully connected to the database")
+	logger.Info("Successfully connected to the database")
 
 	return db
 }
+
+// CloseDB closes the connection pool of the database
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -29,8 +29,3 @@ func (r *GlyphRepository) GlyphsExist(matchID int) (bool, error) {
 
 	return count > 0, nil
 }
-
-func (r *GlyphRepository) CreateGlyphs(newGlyphs []models.Glyph) error {
-	record := r.db.Create(newGlyphs)
-	return record.Error
-}
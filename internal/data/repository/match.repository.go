@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"go-glyph/internal/core/models"
+	"gorm.io/gorm"
+)
+
+type MatchRepository struct {
+	db *gorm.DB
+}
+
+func NewMatchRepository(db *gorm.DB) *MatchRepository {
+	return &MatchRepository{db: db}
+}
+
+func (r *MatchRepository) GetMatch(matchID int) (models.Match, error) {
+	var match models.Match
+	record := r.db.
+		Preload("Players", func(db *gorm.DB) *gorm.DB { return db.Order("player_slot") }).
+		Preload("Draft", func(db *gorm.DB) *gorm.DB { return db.Order("\"order\"") }).
+		Preload("Objectives", func(db *gorm.DB) *gorm.DB { return db.Order("minute, second") }).
+		Preload("Wards", func(db *gorm.DB) *gorm.DB { return db.Order("minute, second") }).
+		Where("match_id = ?", matchID).
+		First(&match)
+	return match, record.Error
+}
+
+func (r *MatchRepository) GetPlayerWards(userSteamID string, wardType string) ([]models.Ward, error) {
+	var wards []models.Ward
+	query := r.db.Where("user_steam_id = ?", userSteamID)
+	if wardType != "" {
+		query = query.Where("type = ?", wardType)
+	}
+	record := query.Find(&wards)
+	return wards, record.Error
+}
+
+func (r *MatchRepository) MatchExists(matchID int) (bool, error) {
+	var count int64
+	result := r.db.Model(&models.Match{}).Where("match_id = ?", matchID).Count(&count)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// CreateMatch saves the match and its glyphs in one transaction, so a match is never stored without its glyphs
+func (r *MatchRepository) CreateMatch(newMatch *models.Match, glyphs []models.Glyph) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(glyphs) > 0 {
+			if err := tx.Create(glyphs).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(newMatch).Error
+	})
+}
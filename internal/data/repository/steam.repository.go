@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"go-glyph/internal/core/models"
+	"gorm.io/gorm"
+)
+
+type SteamSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSteamSessionRepository(db *gorm.DB) *SteamSessionRepository {
+	return &SteamSessionRepository{db: db}
+}
+
+// GetSteamSession returns the session of the account, found is false if there is none
+func (r *SteamSessionRepository) GetSteamSession(username string) (session models.SteamSession, found bool, err error) {
+	record := r.db.Where("username = ?", username).Limit(1).Find(&session)
+	return session, record.RowsAffected > 0, record.Error
+}
+
+func (r *SteamSessionRepository) GetSteamSessions() ([]models.SteamSession, error) {
+	var sessions []models.SteamSession
+	record := r.db.Order("username").Find(&sessions)
+	return sessions, record.Error
+}
+
+// SaveSteamSession creates or replaces the session of the account
+func (r *SteamSessionRepository) SaveSteamSession(session *models.SteamSession) error {
+	record := r.db.Save(session)
+	return record.Error
+}
+
+// DeleteSteamSessions deletes the sessions of the accounts, or every session if none is given
+func (r *SteamSessionRepository) DeleteSteamSessions(usernames ...string) (int64, error) {
+	query := r.db.Where("1 = 1")
+	if len(usernames) > 0 {
+		query = r.db.Where("username IN ?", usernames)
+	}
+	record := query.Delete(&models.SteamSession{})
+	return record.RowsAffected, record.Error
+}
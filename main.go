@@ -1,9 +1,9 @@
 package main
 
 import (
-	"go-glyph/configuration"
-	"go-glyph/internal/api/app"
+	"go-glyph/internal/cli"
 	"log"
+	"os"
 )
 
 // @title           Glyph Dota 2 REST API
@@ -11,10 +11,13 @@ import (
 // @description     Go Glyph REST API
 
 // @host      localhost:8000
+
+// @securityDefinitions.apikey	AdminToken
+// @in							header
+// @name						Authorization
+// @description				"Bearer" followed by the ADMIN_TOKEN
 func main() {
-	err := configuration.LoadConfig(".env")
-	if err != nil {
-		log.Fatalln("Failed to load environment variables!", err.Error())
+	if err := cli.Run(os.Args[1:]); err != nil {
+		log.Fatalln(err)
 	}
-	app.Run(&configuration.EnvConfig)
 }
@@ -1,5 +1,5 @@
 #!/bin/bash
 
 export GOLANG_PROTOBUF_REGISTRATION_CONFLICT=warn
-go run main.go
+go run main.go "$@"
 exit $?